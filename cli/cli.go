@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/logging"
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/services"
+)
+
+// 命令行的输入输出，测试时可以替换
+var (
+	Stdin  io.Reader = os.Stdin
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// 退出码
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: gin-auth-project [--config FILE] [--set KEY=VALUE ...] <command> [arguments]
+
+Commands:
+  serve                                        Start the HTTP server (default)
+  migrate up | down N | status                 Manage database migrations
+  user create [flags]                          Create a user (use --role admin to bootstrap the first administrator)
+  user set-password [--password-stdin] <user>  Set a user's password and sign out all sessions
+  user set-role <user> <role>                  Change a user's primary role
+  user deactivate <user>                       Deactivate a user and sign out all sessions
+  token issue [flags] <user>                   Issue a personal access token for a user
+  config print [--redacted]                    Print the effective configuration as YAML
+
+Global flags:
+  --config FILE      YAML configuration file (default: $CONFIG_FILE)
+  --set KEY=VALUE    Override a setting, e.g. --set SERVER_PORT=9090 (repeatable)
+
+Settings are applied in this order: defaults, config file, environment, --set.
+
+<user> may be a user ID, username or email address.
+Run "gin-auth-project <command> -h" for the flags of a command.
+`
+
+// 用法错误，打印提示后以退出码2结束
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// 执行命令行，返回进程退出码
+func Run(args []string) int {
+	global := newFlagSet("gin-auth-project")
+	global.Usage = func() {}
+	configFile := global.String("config", "", "YAML configuration file")
+	overrides := settingsFlag{}
+	global.Var(overrides, "set", "override a setting (KEY=VALUE)")
+	if err := parseFlags(global, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(Stdout, usage)
+		}
+		return report(err)
+	}
+	args = global.Args()
+
+	if err := config.Init(config.Options{File: *configFile, Overrides: overrides}); err != nil {
+		return report(err)
+	}
+	logging.Init(config.Current())
+
+	if len(args) == 0 {
+		return report(runServe())
+	}
+
+	var err error
+	switch args[0] {
+	case "serve":
+		err = runServe()
+	case "migrate":
+		err = runMigrate(args[1:])
+	case "user":
+		err = runUser(args[1:])
+	case "token":
+		err = runToken(args[1:])
+	case "config":
+		err = runConfig(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(Stdout, usage)
+		return exitOK
+	default:
+		err = usagef("unknown command %q", args[0])
+	}
+	return report(err)
+}
+
+// 输出错误并转换为退出码
+func report(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(Stderr, "error: %s\n\n%s", usageErr.message, usage)
+		return exitUsage
+	}
+
+	fmt.Fprintf(Stderr, "error: %v\n", err)
+	return exitError
+}
+
+// 子命令的参数解析器，解析错误由调用方统一处理
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(Stderr)
+	return flags
+}
+
+// 解析子命令参数，参数错误转换为用法错误
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usagef("%s: %v", flags.Name(), err)
+	}
+	return nil
+}
+
+// 管理命令等待数据库和Redis就绪的最长时间
+const connectTimeout = 10 * time.Second
+
+// 连接数据库和缓存，管理命令不执行迁移
+func connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := database.Connect(ctx); err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	if err := database.InitCache(ctx); err != nil {
+		return fmt.Errorf("connect to cache: %w", err)
+	}
+	return nil
+}
+
+// 用户管理服务，与管理接口共用唯一性检查、密码哈希、角色校验和缓存清理
+func userService() *services.UserService {
+	return services.NewUserService(repository.NewUserRepository(database.DB), repository.NewTokenStore(database.DB))
+}
+
+// 认证服务，签发个人访问令牌时与接口共用令牌生成和事件
+func authService() *services.AuthService {
+	return services.NewAuthService(repository.NewUserRepository(database.DB), repository.NewTokenStore(database.DB))
+}
+
+// 按ID、用户名或邮箱查找用户
+// 纯数字的标识先按ID查找，找不到时再按用户名或邮箱查找（用户名可以是纯数字）
+func findUser(users *services.UserService, ident string) (*models.User, error) {
+	ctx := context.Background()
+	if id, err := strconv.ParseUint(ident, 10, 32); err == nil {
+		user, err := users.Get(ctx, uint(id))
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, services.ErrUserNotFound) {
+			return nil, err
+		}
+	}
+
+	user, err := users.FindByLogin(ctx, ident)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return nil, fmt.Errorf("user %q not found", ident)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// 从标准输入读取一行作为密码，避免密码出现在命令历史和进程列表中
+func readPassword() (string, error) {
+	line, err := bufio.NewReader(Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"gin-auth-project/config"
+)
+
+// 可重复的 --set KEY=VALUE 参数
+type settingsFlag map[string]string
+
+func (f settingsFlag) String() string {
+	return ""
+}
+
+func (f settingsFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", value)
+	}
+	f[key] = val
+	return nil
+}
+
+// 配置命令：config print [--redacted]
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return usagef("config requires a subcommand")
+	}
+	if args[0] != "print" {
+		return usagef("unknown config subcommand %q", args[0])
+	}
+
+	flags := newFlagSet("config print")
+	redacted := flags.Bool("redacted", false, "hide passwords and secrets")
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return usagef("config print takes no arguments")
+	}
+	return config.Current().Print(Stdout, *redacted)
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"gin-auth-project/database"
+)
+
+// 数据库迁移命令：migrate up | migrate down N | migrate status
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return usagef("migrate requires a subcommand")
+	}
+
+	steps := 0
+	switch args[0] {
+	case "up", "status":
+		if len(args) != 1 {
+			return usagef("migrate %s takes no arguments", args[0])
+		}
+	case "down":
+		if len(args) != 2 {
+			return usagef("migrate down requires the number of migrations to revert")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return usagef("migrate down: N must be a positive integer")
+		}
+		steps = n
+	default:
+		return usagef("unknown migrate subcommand %q", args[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := database.Connect(ctx); err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(database.DB)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "Applied %d migration(s)\n", applied)
+	case "down":
+		reverted, err := database.MigrateDown(database.DB, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "Reverted %d migration(s)\n", reverted)
+	case "status":
+		statuses, err := database.MigrationStatuses(database.DB)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(Stdout, "%04d  %-40s  %s\n", status.Version, status.Name, applied)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/logging"
+	"gin-auth-project/utils"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 配置文件变化后等待的时间，合并编辑器保存或ConfigMap更新时产生的多个事件
+const reloadDebounce = 200 * time.Millisecond
+
+// 重新加载配置：校验通过并且签名密钥加载成功后才替换当前配置，否则记录错误并保留当前配置
+func reloadConfig(trigger string) error {
+	next, changed, restart, err := config.Reload()
+	if err == nil {
+		// 即使路径未变也重新读取密钥文件，替换文件内容后发送SIGHUP即可轮换密钥
+		err = utils.UseSigningKeys(next.JWTSigningKeys)
+	}
+	if err != nil {
+		slog.Error("Configuration reload rejected", "trigger", trigger, "error", err)
+		return err
+	}
+
+	config.Store(next)
+	logging.SetLevel(next.LogLevel)
+	if len(restart) > 0 {
+		slog.Warn("Configuration changes require a restart", "settings", restart)
+	}
+	slog.Info("Configuration reloaded", "trigger", trigger, "changed", changed)
+	return nil
+}
+
+// 监听SIGHUP和配置文件的变化
+type configWatcher struct {
+	hup   chan os.Signal
+	path  string
+	files *fsnotify.Watcher
+}
+
+// 开始接收SIGHUP并监听配置文件；监听失败时仍可通过SIGHUP重新加载
+func watchConfig() *configWatcher {
+	w := &configWatcher{hup: make(chan os.Signal, 1)}
+	signal.Notify(w.hup, syscall.SIGHUP)
+
+	path := config.File()
+	if path == "" {
+		return w
+	}
+	files, err := fsnotify.NewWatcher()
+	if err == nil {
+		// 监听所在目录而不是文件本身，编辑器替换文件和Kubernetes更新ConfigMap时文件会被重新创建
+		err = files.Add(filepath.Dir(path))
+	}
+	if err != nil {
+		slog.Warn("Failed to watch config file, use SIGHUP to reload", "path", path, "error", err)
+		if files != nil {
+			files.Close()
+		}
+		return w
+	}
+	w.path, w.files = filepath.Clean(path), files
+	return w
+}
+
+// 处理重新加载，直到ctx结束
+func (w *configWatcher) run(ctx context.Context) {
+	var (
+		events   <-chan fsnotify.Event
+		errs     <-chan error
+		debounce <-chan time.Time
+	)
+	if w.files != nil {
+		events, errs = w.files.Events, w.files.Errors
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.hup:
+			reloadConfig("SIGHUP")
+		case event := <-events:
+			if w.affects(event) {
+				debounce = time.After(reloadDebounce)
+			}
+		case err := <-errs:
+			slog.Warn("Config file watcher failed", "error", err)
+		case <-debounce:
+			debounce = nil
+			reloadConfig("file")
+		}
+	}
+}
+
+// 配置文件本身或Kubernetes挂载ConfigMap时的 ..data 链接发生变化
+func (w *configWatcher) affects(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	return name == w.path || filepath.Base(name) == "..data"
+}
+
+func (w *configWatcher) Close() {
+	signal.Stop(w.hup)
+	if w.files != nil {
+		w.files.Close()
+	}
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/mailer"
+	"gin-auth-project/metrics"
+	"gin-auth-project/routes"
+	"gin-auth-project/tracing"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 读取请求头的超时时间，防止慢速连接占用服务器资源
+const readHeaderTimeout = 10 * time.Second
+
+// 启动HTTP服务，收到SIGHUP时重新加载配置；收到SIGINT或SIGTERM后停止接收新请求，等待进行中的请求完成后依次关闭各依赖
+func runServe() error {
+	cfg := config.Current()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 加载JWT签名密钥
+	if err := utils.InitSigningKeys(); err != nil {
+		return fmt.Errorf("load JWT signing keys: %w", err)
+	}
+
+	// 初始化链路追踪，退出前导出剩余的span
+	shutdownTracing, err := tracing.Init(cfg)
+	if err != nil {
+		return fmt.Errorf("init tracing: %w", err)
+	}
+
+	// 设置Gin模式
+	gin.SetMode(cfg.ServerMode)
+
+	// 连接数据库和缓存，未就绪时在启动超时内重试；未配置Redis时使用进程内缓存
+	startCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.StartupTimeoutSeconds)*time.Second)
+	err = database.InitDatabase(startCtx)
+	if err == nil {
+		err = database.InitCache(startCtx)
+	}
+	cancel()
+	if err != nil {
+		closeDependencies(shutdownTracing)
+		return fmt.Errorf("start dependencies: %w", err)
+	}
+	defer closeDependencies(shutdownTracing)
+
+	// 采集数据库和Redis的指标
+	if cfg.MetricsEnabled {
+		if err := metrics.InstrumentDB(database.DB); err != nil {
+			return fmt.Errorf("instrument database: %w", err)
+		}
+		if database.RedisClient != nil {
+			metrics.InstrumentRedis(database.RedisClient)
+		}
+	}
+
+	// 为请求中的数据库语句和Redis命令记录span
+	if err := tracing.InstrumentDB(database.DB); err != nil {
+		return fmt.Errorf("instrument database: %w", err)
+	}
+	if database.RedisClient != nil {
+		tracing.InstrumentRedis(database.RedisClient)
+	}
+
+	// 初始化邮件发送
+	mailer.Init()
+
+	// 设置路由
+	server := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           routes.SetupRoutes(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	// 收到SIGHUP或配置文件变化时重新加载可在运行中修改的配置
+	watcher := watchConfig()
+	defer watcher.Close()
+	go watcher.run(ctx)
+
+	// 启动服务器
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "port", cfg.ServerPort, "mode", cfg.ServerMode)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("start server: %w", err)
+	case <-ctx.Done():
+	}
+
+	// 再次收到信号时不再等待，直接退出
+	stop()
+	timeout := time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second
+	slog.Info("Shutting down server", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// 超时后强制关闭剩余的连接
+		server.Close()
+		return fmt.Errorf("shutdown server: %w", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// 导出剩余的span后关闭Redis和数据库连接池
+func closeDependencies(shutdownTracing func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+	if err := database.Close(); err != nil {
+		slog.Warn("Failed to close connections", "error", err)
+	}
+}
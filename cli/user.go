@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+)
+
+// 密码最短长度，与注册接口一致
+const minPasswordLength = 6
+
+// 用户管理命令
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return usagef("user requires a subcommand")
+	}
+
+	switch args[0] {
+	case "create":
+		return runUserCreate(args[1:])
+	case "set-password":
+		return runUserSetPassword(args[1:])
+	case "set-role":
+		return runUserSetRole(args[1:])
+	case "deactivate":
+		return runUserDeactivate(args[1:])
+	}
+	return usagef("unknown user subcommand %q", args[0])
+}
+
+// 创建用户，未通过标准输入提供密码时生成随机密码并输出一次
+func runUserCreate(args []string) error {
+	flags := newFlagSet("user create")
+	username := flags.String("username", "", "username (3-20 characters, required)")
+	email := flags.String("email", "", "email address (required)")
+	role := flags.String("role", string(models.RoleUser), "primary role")
+	verified := flags.Bool("verified", false, "mark the email address as verified")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from standard input instead of generating one")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		return usagef("user create takes no positional arguments")
+	}
+	if len(*username) < 3 || len(*username) > 20 {
+		return usagef("user create: --username must be 3-20 characters")
+	}
+	if _, err := mail.ParseAddress(*email); err != nil {
+		return usagef("user create: --email must be a valid email address")
+	}
+
+	password, generated, err := newPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	if err := connect(); err != nil {
+		return err
+	}
+	user, err := userService().Create(context.Background(), services.CreateUserInput{
+		Username:      *username,
+		Email:         *email,
+		Password:      password,
+		Role:          models.Role(*role),
+		EmailVerified: *verified,
+	})
+	if err != nil {
+		return userError(err, "create user", models.Role(*role))
+	}
+
+	fmt.Fprintf(Stdout, "Created user %d (%s) with role %s\n", user.ID, user.Username, user.Role)
+	if generated {
+		fmt.Fprintf(Stdout, "Generated password: %s\n", password)
+	}
+	return nil
+}
+
+// 设置用户密码，并让全部已有会话和令牌失效
+func runUserSetPassword(args []string) error {
+	flags := newFlagSet("user set-password")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from standard input instead of generating one")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usagef("user set-password requires exactly one user")
+	}
+
+	password, generated, err := newPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	if err := connect(); err != nil {
+		return err
+	}
+	users := userService()
+	user, err := findUser(users, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// 与重置密码一致，password_changed_at之前签发的访问令牌一律失效，全部会话被吊销
+	result, err := users.Update(context.Background(), user, services.UpdateUserInput{Password: password})
+	if err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+
+	fmt.Fprintf(Stdout, "Password updated for user %d (%s), %d session(s) revoked\n", user.ID, user.Username, result.SessionsRevoked)
+	if generated {
+		fmt.Fprintf(Stdout, "Generated password: %s\n", password)
+	}
+	return nil
+}
+
+// 修改用户的主角色
+func runUserSetRole(args []string) error {
+	flags := newFlagSet("user set-role")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return usagef("user set-role requires a user and a role")
+	}
+
+	if err := connect(); err != nil {
+		return err
+	}
+	users := userService()
+	user, err := findUser(users, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	role := models.Role(flags.Arg(1))
+	if _, err := users.Update(context.Background(), user, services.UpdateUserInput{Role: role}); err != nil {
+		return userError(err, "update role", role)
+	}
+
+	fmt.Fprintf(Stdout, "User %d (%s) now has role %s\n", user.ID, user.Username, role)
+	return nil
+}
+
+// 停用用户，并吊销全部会话
+func runUserDeactivate(args []string) error {
+	flags := newFlagSet("user deactivate")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usagef("user deactivate requires exactly one user")
+	}
+
+	if err := connect(); err != nil {
+		return err
+	}
+	users := userService()
+	user, err := findUser(users, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	revoked, err := users.Deactivate(context.Background(), user)
+	if err != nil {
+		return fmt.Errorf("deactivate user: %w", err)
+	}
+
+	fmt.Fprintf(Stdout, "Deactivated user %d (%s), %d session(s) revoked\n", user.ID, user.Username, revoked)
+	return nil
+}
+
+// 从标准输入读取密码，或生成随机密码
+func newPassword(fromStdin bool) (password string, generated bool, err error) {
+	if !fromStdin {
+		password, err = utils.GenerateOpaqueToken()
+		return password, true, err
+	}
+
+	password, err = readPassword()
+	if err != nil {
+		return "", false, fmt.Errorf("read password: %w", err)
+	}
+	if len(password) < minPasswordLength {
+		return "", false, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return password, false, nil
+}
+
+// 转换用户服务的错误，唯一性和角色校验失败时直接输出原因
+func userError(err error, action string, role models.Role) error {
+	switch {
+	case errors.Is(err, services.ErrUsernameTaken), errors.Is(err, services.ErrEmailTaken):
+		return err
+	case errors.Is(err, services.ErrUnknownRole):
+		return fmt.Errorf("role %q does not exist", role)
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
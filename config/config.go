@@ -2,73 +2,220 @@ package config
 
 import (
 	"log"
-	"os"
-	"strconv"
+	"sync/atomic"
 
 	"github.com/joho/godotenv"
 )
 
+// 配置项的值按以下顺序覆盖：默认值 < 配置文件 < 环境变量（含.env） < 命令行 --set。
+// 每个字段的env标签是环境变量名，配置文件中使用其小写形式（如 db_host），也可以按下划线嵌套；
+// 带secret标签的字段可以通过 <名称>_FILE 从文件读取，输出配置时会被隐藏；
+// 带reload标签的字段可以在运行中通过SIGHUP或修改配置文件重新加载，其余字段需要重启。
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBSSLMode  string
-
-	RedisHost     string
-	RedisPort     string
-	RedisPassword string
-	RedisDB       int
-
-	JWTSecret      string
-	JWTExpireHours int
-
-	ServerPort string
-	ServerMode string
-}
+	// 数据库驱动：postgres，或用于本地开发和测试的sqlite
+	DBDriver   string `env:"DB_DRIVER"`
+	SQLitePath string `env:"SQLITE_PATH"`
 
-var AppConfig *Config
+	DBHost     string `env:"DB_HOST"`
+	DBPort     string `env:"DB_PORT"`
+	DBUser     string `env:"DB_USER"`
+	DBPassword string `env:"DB_PASSWORD" secret:"true"`
+	DBName     string `env:"DB_NAME"`
+	DBSSLMode  string `env:"DB_SSL_MODE"`
 
-func Init() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("No .env file found, using default values")
-	}
+	// 启动服务时是否自动执行数据库迁移，多实例部署可关闭后由发布流程执行 migrate up
+	DBAutoMigrate bool `env:"DB_AUTO_MIGRATE"`
+	// 超过该时长的SQL以warn级别记录，为0时不记录慢查询
+	DBSlowQueryMS int `env:"DB_SLOW_QUERY_MS"`
 
-	AppConfig = &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBName:     getEnv("DB_NAME", "gin_auth_db"),
-		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
+	// RedisHost为空时使用进程内缓存，只适用于单实例部署
+	RedisHost     string `env:"REDIS_HOST,allowempty"`
+	RedisPort     string `env:"REDIS_PORT"`
+	RedisPassword string `env:"REDIS_PASSWORD" secret:"true"`
+	RedisDB       int    `env:"REDIS_DB"`
+	// Redis查询令牌黑名单失败时是否放行令牌，默认视为已吊销
+	RevocationFailOpen bool `env:"REVOCATION_FAIL_OPEN" reload:"true"`
 
-		RedisHost:     getEnv("REDIS_HOST", "localhost"),
-		RedisPort:     getEnv("REDIS_PORT", "6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       getEnvAsInt("REDIS_DB", 0),
+	JWTSecret               string   `env:"JWT_SECRET" secret:"true"`
+	JWTExpireHours          int      `env:"JWT_EXPIRE_HOURS" reload:"true"`
+	RefreshTokenExpireHours int      `env:"REFRESH_TOKEN_EXPIRE_HOURS" reload:"true"`
+	JWTSigningKeys          []string `env:"JWT_SIGNING_KEYS" reload:"true"`
+	JWTAcceptHS256          bool     `env:"JWT_ACCEPT_HS256" reload:"true"`
 
-		JWTSecret:      getEnv("JWT_SECRET", "default_jwt_secret"),
-		JWTExpireHours: getEnvAsInt("JWT_EXPIRE_HOURS", 24),
+	ServerPort string `env:"SERVER_PORT"`
+	ServerMode string `env:"SERVER_MODE"`
 
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		ServerMode: getEnv("SERVER_MODE", "debug"),
-	}
+	// 启动时等待数据库和Redis就绪的最长时间，停止时等待进行中请求完成的最长时间
+	StartupTimeoutSeconds  int `env:"STARTUP_TIMEOUT_SECONDS"`
+	ShutdownTimeoutSeconds int `env:"SHUTDOWN_TIMEOUT_SECONDS"`
+
+	// 日志级别（debug、info、warn、error）和格式（json、text）
+	LogLevel  string `env:"LOG_LEVEL" reload:"true"`
+	LogFormat string `env:"LOG_FORMAT"`
+
+	// 允许跨域访问的来源，包含 * 时允许任意来源
+	CORSAllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" reload:"true"`
+
+	// 信任的反向代理（IP或CIDR），只有来自这些地址的X-Forwarded-For才用于确定客户端IP；默认不信任任何代理
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	// 是否开放/metrics（Prometheus指标）
+	MetricsEnabled bool `env:"METRICS_ENABLED"`
+
+	// 链路追踪：导出方式（none、otlp、stdout、file）、OTLP地址、文件路径和采样比例
+	TracingExporter     string  `env:"TRACING_EXPORTER"`
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+	TracingFile         string  `env:"TRACING_FILE"`
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO"`
+	TracingServiceName  string  `env:"TRACING_SERVICE_NAME"`
+
+	MFAIssuer       string `env:"MFA_ISSUER"`
+	RequireAdminMFA bool   `env:"REQUIRE_ADMIN_MFA" reload:"true"`
+
+	LoginMaxFailures          int `env:"LOGIN_MAX_FAILURES" reload:"true"`
+	LoginIPMaxFailures        int `env:"LOGIN_IP_MAX_FAILURES" reload:"true"`
+	LoginFailureWindowMinutes int `env:"LOGIN_FAILURE_WINDOW_MINUTES" reload:"true"`
+	LoginLockoutMinutes       int `env:"LOGIN_LOCKOUT_MINUTES" reload:"true"`
+
+	RateLimitEnabled       bool `env:"RATE_LIMIT_ENABLED" reload:"true"`
+	RateLimitAuthPerMinute int  `env:"RATE_LIMIT_AUTH_PER_MINUTE" reload:"true"`
+	RateLimitAPIPerMinute  int  `env:"RATE_LIMIT_API_PER_MINUTE" reload:"true"`
+
+	WebAuthnRPID          string   `env:"WEBAUTHN_RP_ID"`
+	WebAuthnRPDisplayName string   `env:"WEBAUTHN_RP_DISPLAY_NAME"`
+	WebAuthnRPOrigins     []string `env:"WEBAUTHN_RP_ORIGINS"`
+
+	AppBaseURL                   string `env:"APP_BASE_URL"`
+	OIDCIssuer                   string `env:"OIDC_ISSUER"`
+	EmailVerificationRequired    bool   `env:"EMAIL_VERIFICATION_REQUIRED"`
+	EmailVerificationExpireHours int    `env:"EMAIL_VERIFICATION_EXPIRE_HOURS"`
+	PasswordResetExpireMinutes   int    `env:"PASSWORD_RESET_EXPIRE_MINUTES"`
+	// 前端的重置密码页面，重置邮件中的链接为该地址加上 token 参数；为空时使用 APP_BASE_URL/reset-password
+	PasswordResetURL string `env:"PASSWORD_RESET_URL"`
+
+	MailTransport string `env:"MAIL_TRANSPORT"`
+	MailFrom      string `env:"MAIL_FROM"`
+	MailOutboxDir string `env:"MAIL_OUTBOX_DIR"`
+	SMTPHost      string `env:"SMTP_HOST"`
+	SMTPPort      int    `env:"SMTP_PORT"`
+	SMTPUsername  string `env:"SMTP_USERNAME"`
+	SMTPPassword  string `env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// 当前生效的配置快照，重新加载时整体替换；读取方不能修改返回的配置
+var current atomic.Pointer[Config]
+
+// 当前生效的配置；同一次处理中多次读取时应只调用一次，保证读到同一个快照
+func Current() *Config {
+	return current.Load()
+}
+
+// 替换当前配置
+func Store(cfg *Config) {
+	current.Store(cfg)
+}
+
+// 加载配置的来源
+type Options struct {
+	// 配置文件路径（YAML），为空时使用环境变量CONFIG_FILE，都未设置时不读取配置文件
+	File string
+	// 命令行覆盖的配置项，键为环境变量名或配置文件中的名称
+	Overrides map[string]string
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// 加载并校验配置，成功后替换当前配置；重新加载时使用相同的来源
+func Init(opts Options) error {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using default values")
+	}
+
+	cfg, err := Load(opts)
+	if err != nil {
+		return err
 	}
-	return defaultValue
+	options = opts
+	Store(cfg)
+	return nil
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+// 各配置项的默认值，适用于本地开发；release模式下不安全的默认值会被拒绝
+func Defaults() *Config {
+	return &Config{
+		DBDriver:   "postgres",
+		SQLitePath: "gin_auth.db",
+
+		DBHost:     "localhost",
+		DBPort:     "5432",
+		DBUser:     "postgres",
+		DBPassword: "",
+		DBName:     "gin_auth_db",
+		DBSSLMode:  "disable",
+
+		DBAutoMigrate: true,
+		DBSlowQueryMS: 200,
+
+		RedisHost:     "localhost",
+		RedisPort:     "6379",
+		RedisPassword: "",
+		RedisDB:       0,
+
+		RevocationFailOpen: false,
+
+		JWTSecret:               "default_jwt_secret",
+		JWTExpireHours:          1,
+		RefreshTokenExpireHours: 720,
+		JWTSigningKeys:          nil,
+		JWTAcceptHS256:          false,
+
+		ServerPort: "8080",
+		ServerMode: "debug",
+
+		StartupTimeoutSeconds:  60,
+		ShutdownTimeoutSeconds: 20,
+
+		LogLevel:  "info",
+		LogFormat: "json",
+
+		CORSAllowedOrigins: []string{"*"},
+		TrustedProxies:     nil,
+
+		MetricsEnabled: true,
+
+		TracingExporter:     "none",
+		TracingOTLPEndpoint: "",
+		TracingFile:         "traces.jsonl",
+		TracingSampleRatio:  1,
+		TracingServiceName:  "gin-auth-project",
+
+		MFAIssuer:       "Gin Auth Project",
+		RequireAdminMFA: false,
+
+		LoginMaxFailures:          5,
+		LoginIPMaxFailures:        50,
+		LoginFailureWindowMinutes: 15,
+		LoginLockoutMinutes:       15,
+
+		RateLimitEnabled:       true,
+		RateLimitAuthPerMinute: 10,
+		RateLimitAPIPerMinute:  300,
+
+		WebAuthnRPID:          "localhost",
+		WebAuthnRPDisplayName: "Gin Auth Project",
+		WebAuthnRPOrigins:     []string{"http://localhost:8080"},
+
+		AppBaseURL:                   "http://localhost:8080",
+		OIDCIssuer:                   "http://localhost:8080",
+		EmailVerificationRequired:    false,
+		EmailVerificationExpireHours: 24,
+		PasswordResetExpireMinutes:   60,
+		PasswordResetURL:             "",
+
+		MailTransport: "file",
+		MailFrom:      "no-reply@localhost",
+		MailOutboxDir: "mail_outbox",
+		SMTPHost:      "localhost",
+		SMTPPort:      587,
+		SMTPUsername:  "",
+		SMTPPassword:  "",
 	}
-	return defaultValue
 }
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置项的来源，数值越大优先级越高
+type source int
+
+const (
+	sourceDefault source = iota
+	sourceFile
+	sourceEnv
+	sourceFlag
+)
+
+func (s source) String() string {
+	switch s {
+	case sourceFile:
+		return "config file"
+	case sourceEnv:
+		return "environment"
+	case sourceFlag:
+		return "--set"
+	default:
+		return "default"
+	}
+}
+
+// 按优先级合并各来源的配置项
+type loader struct {
+	file      map[string]string
+	fileName  string
+	overrides map[string]string
+	used      map[string]bool
+}
+
+// 按默认值、配置文件、环境变量和命令行覆盖的顺序加载配置并校验
+func Load(opts Options) (*Config, error) {
+	l := &loader{overrides: make(map[string]string), used: make(map[string]bool)}
+
+	l.fileName = opts.File
+	if l.fileName == "" {
+		l.fileName = os.Getenv("CONFIG_FILE")
+	}
+	if l.fileName != "" {
+		file, err := readFile(l.fileName)
+		if err != nil {
+			return nil, err
+		}
+		l.file = file
+	}
+	for key, value := range opts.Overrides {
+		l.overrides[normalizeKey(key)] = value
+	}
+
+	cfg := Defaults()
+	var errs []error
+	forEachSetting(cfg, func(s setting) {
+		if err := l.apply(s); err != nil {
+			errs = append(errs, err)
+		}
+	})
+	errs = append(errs, l.unknownKeys()...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 配置结构中的一个字段
+type setting struct {
+	key        string
+	secret     bool
+	reloadable bool
+	allowEmpty bool
+	index      []int
+	value      reflect.Value
+}
+
+// 按声明顺序遍历带env标签的字段
+func forEachSetting(cfg *Config, fn func(setting)) {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		key, flags, _ := strings.Cut(tag, ",")
+		fn(setting{
+			key:        key,
+			secret:     field.Tag.Get("secret") == "true",
+			reloadable: field.Tag.Get("reload") == "true",
+			allowEmpty: flags == "allowempty",
+			index:      field.Index,
+			value:      v.Field(i),
+		})
+	}
+}
+
+// 查找配置项在优先级最高的来源中的值；环境变量为空时视为未设置，除非该项允许为空
+func (l *loader) lookup(key string, allowEmpty bool) (string, source) {
+	l.used[key] = true
+	if value, ok := l.overrides[key]; ok {
+		return value, sourceFlag
+	}
+	if value, ok := os.LookupEnv(key); ok && (value != "" || allowEmpty) {
+		return value, sourceEnv
+	}
+	if value, ok := l.file[key]; ok {
+		return value, sourceFile
+	}
+	return "", sourceDefault
+}
+
+// 读取配置项并写入字段
+func (l *loader) apply(s setting) error {
+	raw, from := l.lookup(s.key, s.allowEmpty)
+
+	// 敏感配置项可以从文件读取（如挂载的Kubernetes Secret），与直接设置的值按来源优先级比较
+	if s.secret {
+		path, fileFrom := l.lookup(s.key+"_FILE", false)
+		if fileFrom != sourceDefault && fileFrom == from {
+			return fmt.Errorf("%s and %s_FILE are both set in %s", s.key, s.key, from)
+		}
+		if fileFrom > from {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("%s_FILE: %w", s.key, err)
+			}
+			raw, from = strings.TrimRight(string(data), "\r\n"), fileFrom
+		}
+	}
+
+	if from == sourceDefault {
+		return nil
+	}
+	if err := setValue(s.value, raw); err != nil {
+		return fmt.Errorf("%s (from %s): %w", s.key, from, err)
+	}
+	return nil
+}
+
+// 配置文件和命令行中无法识别的配置项，通常是拼写错误
+func (l *loader) unknownKeys() []error {
+	var errs []error
+	for _, key := range sortedKeys(l.file) {
+		if !l.used[key] {
+			errs = append(errs, fmt.Errorf("unknown setting %q in %s", strings.ToLower(key), l.fileName))
+		}
+	}
+	for _, key := range sortedKeys(l.overrides) {
+		if !l.used[key] {
+			errs = append(errs, fmt.Errorf("unknown setting %q in --set", key))
+		}
+	}
+	return errs
+}
+
+func setValue(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		field.SetInt(int64(value))
+	case reflect.Float64:
+		value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		field.SetFloat(value)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		field.SetBool(value)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(splitList(raw)))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// 逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 配置文件和命令行中的名称统一为环境变量形式，如 db_host -> DB_HOST
+func normalizeKey(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(strings.TrimSpace(key)))
+}
+
+// 读取YAML配置文件，嵌套的键用下划线连接（db: {host: x} 等同于 db_host: x），列表转换为逗号分隔的字符串
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, "", doc); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, node map[string]interface{}) error {
+	for name, value := range node {
+		key := normalizeKey(name)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(values, key, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, ok := item.(map[string]interface{}); ok {
+					return fmt.Errorf("%s: list items must be scalars", strings.ToLower(key))
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 隐藏敏感配置项时使用的占位值
+const redactedValue = "[REDACTED]"
+
+// 以YAML输出生效的配置，格式与配置文件相同；redacted为true时隐藏已设置的敏感配置项
+func (c *Config) Print(w io.Writer, redacted bool) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	var err error
+	forEachSetting(c, func(s setting) {
+		value := &yaml.Node{}
+		if s.secret && redacted && s.value.String() != "" {
+			value.SetString(redactedValue)
+		} else if encodeErr := value.Encode(s.value.Interface()); encodeErr != nil && err == nil {
+			err = encodeErr
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: strings.ToLower(s.key)},
+			value,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"reflect"
+)
+
+// 启动时加载配置使用的来源，重新加载时使用相同的来源
+var options Options
+
+// 配置文件路径，未使用配置文件时为空
+func File() string {
+	if options.File != "" {
+		return options.File
+	}
+	return os.Getenv("CONFIG_FILE")
+}
+
+// 重新读取并校验配置，合并后的快照同样需要通过校验，返回新的配置快照，调用方在应用成功后通过Store替换当前配置。
+// 只有可重新加载的配置项取新值，其余保持不变；changed为已更新的配置项，restart为已修改但需要重启才能生效的配置项
+func Reload() (next *Config, changed, restart []string, err error) {
+	loaded, err := Load(options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cfg := *Current()
+	next = &cfg
+	forEachSetting(next, func(s setting) {
+		value := reflect.ValueOf(loaded).Elem().FieldByIndex(s.index)
+		if reflect.DeepEqual(s.value.Interface(), value.Interface()) {
+			return
+		}
+		if !s.reloadable {
+			restart = append(restart, s.key)
+			return
+		}
+		s.value.Set(value)
+		changed = append(changed, s.key)
+	})
+
+	// 新的配置文件本身有效，不代表与保留的旧值组合后仍然有效（例如去掉JWT_SIGNING_KEYS而JWT_SECRET需要重启才能更新）
+	if err := next.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	return next, changed, restart, nil
+}
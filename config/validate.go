@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// HS256密钥的最小长度（字节），与SHA-256的输出长度一致
+const minJWTSecretLength = 32
+
+// 示例配置中的占位密钥，与未设置等同
+var insecureJWTSecrets = map[string]bool{
+	"default_jwt_secret":       true,
+	"your_jwt_secret_key_here": true,
+}
+
+// 校验配置项的取值；release模式下拒绝不安全的默认值，返回的错误包含所有问题
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s must be one of %v, got %q", key, allowed, value))
+	}
+
+	oneOf("DB_DRIVER", c.DBDriver, "postgres", "sqlite")
+	oneOf("SERVER_MODE", c.ServerMode, "debug", "release", "test")
+	oneOf("LOG_LEVEL", c.LogLevel, "debug", "info", "warn", "error")
+	oneOf("LOG_FORMAT", c.LogFormat, "json", "text")
+	oneOf("TRACING_EXPORTER", c.TracingExporter, "none", "otlp", "stdout", "file")
+	oneOf("MAIL_TRANSPORT", c.MailTransport, "file", "smtp", "memory")
+
+	check(validPort(c.ServerPort), "SERVER_PORT must be a port number, got %q", c.ServerPort)
+	if c.DBDriver == "postgres" {
+		check(validPort(c.DBPort), "DB_PORT must be a port number, got %q", c.DBPort)
+	}
+	if c.RedisHost != "" {
+		check(validPort(c.RedisPort), "REDIS_PORT must be a port number, got %q", c.RedisPort)
+	}
+
+	for _, origin := range c.CORSAllowedOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"CORS_ALLOWED_ORIGINS must contain * or http(s) origins, got %q", origin)
+	}
+
+	if c.PasswordResetURL != "" {
+		check(strings.HasPrefix(c.PasswordResetURL, "http://") || strings.HasPrefix(c.PasswordResetURL, "https://"),
+			"PASSWORD_RESET_URL must be an http(s) URL, got %q", c.PasswordResetURL)
+	}
+	for _, proxy := range c.TrustedProxies {
+		check(validProxy(proxy), "TRUSTED_PROXIES must contain IP addresses or CIDRs, got %q", proxy)
+	}
+
+	check(c.JWTExpireHours > 0, "JWT_EXPIRE_HOURS must be positive")
+	check(c.RefreshTokenExpireHours > 0, "REFRESH_TOKEN_EXPIRE_HOURS must be positive")
+	check(c.StartupTimeoutSeconds > 0, "STARTUP_TIMEOUT_SECONDS must be positive")
+	check(c.ShutdownTimeoutSeconds > 0, "SHUTDOWN_TIMEOUT_SECONDS must be positive")
+	check(c.DBSlowQueryMS >= 0, "DB_SLOW_QUERY_MS must not be negative")
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+	check(c.LoginMaxFailures >= 0, "LOGIN_MAX_FAILURES must not be negative")
+	check(c.LoginIPMaxFailures >= 0, "LOGIN_IP_MAX_FAILURES must not be negative")
+	check(c.LoginFailureWindowMinutes > 0, "LOGIN_FAILURE_WINDOW_MINUTES must be positive")
+	check(c.LoginLockoutMinutes > 0, "LOGIN_LOCKOUT_MINUTES must be positive")
+	if c.RateLimitEnabled {
+		check(c.RateLimitAuthPerMinute > 0, "RATE_LIMIT_AUTH_PER_MINUTE must be positive")
+		check(c.RateLimitAPIPerMinute > 0, "RATE_LIMIT_API_PER_MINUTE must be positive")
+	}
+	check(c.EmailVerificationExpireHours > 0, "EMAIL_VERIFICATION_EXPIRE_HOURS must be positive")
+	check(c.PasswordResetExpireMinutes > 0, "PASSWORD_RESET_EXPIRE_MINUTES must be positive")
+
+	if c.ServerMode == "release" {
+		errs = append(errs, c.insecureDefaults()...)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// 生产环境中不能使用的默认值
+func (c *Config) insecureDefaults() []error {
+	var errs []error
+
+	// 未配置非对称密钥或仍接受HS256时，JWT_SECRET用于签名或验证令牌
+	if len(c.JWTSigningKeys) == 0 || c.JWTAcceptHS256 {
+		if c.JWTSecret == "" || insecureJWTSecrets[c.JWTSecret] {
+			errs = append(errs, errors.New("JWT_SECRET must be set in release mode (or use JWT_SIGNING_KEYS)"))
+		} else if len(c.JWTSecret) < minJWTSecretLength {
+			errs = append(errs, fmt.Errorf("JWT_SECRET must be at least %d bytes in release mode", minJWTSecretLength))
+		}
+	}
+
+	if c.DBDriver == "postgres" && c.DBPassword == "" {
+		errs = append(errs, errors.New("DB_PASSWORD must be set in release mode"))
+	}
+	return errs
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
+
+func validProxy(proxy string) bool {
+	if _, _, err := net.ParseCIDR(proxy); err == nil {
+		return true
+	}
+	return net.ParseIP(proxy) != nil
+}
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"gin-auth-project/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 缓存键不存在，与Redis客户端返回的错误相同
+var ErrCacheMiss = redis.Nil
+
+var errCacheNotInitialized = errors.New("cache is not initialized")
+
+// 缓存后端，语义与对应的Redis命令一致
+type Cache interface {
+	Set(ctx context.Context, key, value string, expiration time.Duration) error
+	// 键不存在时返回ErrCacheMiss
+	Get(ctx context.Context, key string) (string, error)
+	GetDel(ctx context.Context, key string) (string, error)
+	Del(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// 计数器自增，键新建时在同一操作中设置过期时间（expiration为0时不过期），不会留下没有过期时间的计数器
+	Incr(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	Expire(ctx context.Context, key string, expiration time.Duration) error
+	// 键不存在时返回-2，没有过期时间时返回-1
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+var cache Cache
+
+// 初始化缓存：配置了REDIS_HOST时连接Redis，否则使用进程内缓存
+func InitCache(ctx context.Context) error {
+	if config.Current().RedisHost == "" {
+		UseCache(NewMemoryCache())
+		slog.Warn("REDIS_HOST is empty, using in-process cache (single instance only)")
+		return nil
+	}
+	return InitRedis(ctx)
+}
+
+// 替换缓存后端，为nil时关闭缓存
+func UseCache(c Cache) {
+	cache = c
+}
+
+// 是否有可用的缓存后端
+func CacheEnabled() bool {
+	return cache != nil
+}
+
+// 设置缓存
+func SetCache(key string, value interface{}, expiration time.Duration) error {
+	return setCache(context.Background(), key, value, expiration)
+}
+
+func setCache(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if cache == nil {
+		return errCacheNotInitialized
+	}
+	return cache.Set(ctx, key, cacheValue(value), expiration)
+}
+
+// 获取缓存
+func GetCache(key string) (string, error) {
+	return getCache(context.Background(), key)
+}
+
+func getCache(ctx context.Context, key string) (string, error) {
+	if cache == nil {
+		return "", errCacheNotInitialized
+	}
+	return cache.Get(ctx, key)
+}
+
+// 删除缓存
+func DeleteCache(key string) error {
+	return deleteCache(context.Background(), key)
+}
+
+func deleteCache(ctx context.Context, key string) error {
+	if cache == nil {
+		return errCacheNotInitialized
+	}
+	return cache.Del(ctx, key)
+}
+
+// 获取并删除缓存，用于一次性数据
+func TakeCache(key string) (string, error) {
+	if cache == nil {
+		return "", errCacheNotInitialized
+	}
+	return cache.GetDel(context.Background(), key)
+}
+
+// 检查键是否存在
+func ExistsCache(key string) (bool, error) {
+	if cache == nil {
+		return false, errCacheNotInitialized
+	}
+	return cache.Exists(context.Background(), key)
+}
+
+// 计数器自增，首次创建时设置过期时间
+func IncrCache(key string, expiration time.Duration) (int64, error) {
+	return incrCache(context.Background(), key, expiration)
+}
+
+func incrCache(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	if cache == nil {
+		return 0, errCacheNotInitialized
+	}
+	return cache.Incr(ctx, key, expiration)
+}
+
+// 进程内的后备计数器，缓存不可用时使用（只对单个副本生效）
+var fallbackCounters = NewMemoryCache()
+
+// 计数器自增，缓存不可用时回退到进程内计数，用于不能因缓存故障而失效的安全限制
+func IncrCounter(key string, expiration time.Duration) int64 {
+	count, err := IncrCache(key, expiration)
+	if err == nil {
+		return count
+	}
+	slog.Warn("Failed to increment counter in cache, using in-memory fallback", "key", key, "error", err)
+	count, _ = fallbackCounters.Incr(context.Background(), key, expiration)
+	return count
+}
+
+// 设置过期时间
+func ExpireCache(key string, expiration time.Duration) error {
+	if cache == nil {
+		return errCacheNotInitialized
+	}
+	return cache.Expire(context.Background(), key, expiration)
+}
+
+// 获取剩余过期时间，键不存在时返回负数
+func TTLCache(key string) (time.Duration, error) {
+	return ttlCache(context.Background(), key)
+}
+
+func ttlCache(ctx context.Context, key string) (time.Duration, error) {
+	if cache == nil {
+		return 0, errCacheNotInitialized
+	}
+	return cache.TTL(ctx, key)
+}
+
+// 与Redis客户端写入参数时的格式一致
+func cacheValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// 进程内缓存，REDIS_HOST为空时使用，数据不在多个实例之间共享，重启后丢失
+type memoryCache struct {
+	mu        sync.Mutex
+	entries   map[string]memoryCacheEntry
+	lastSweep time.Time
+}
+
+type memoryCacheEntry struct {
+	value     string
+	expiresAt time.Time // 零值表示不过期
+}
+
+func (e memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !e.expiresAt.After(now)
+}
+
+func NewMemoryCache() Cache {
+	return &memoryCache{entries: make(map[string]memoryCacheEntry)}
+}
+
+// 读取未过期的条目，调用方需持有锁
+func (m *memoryCache) lookup(key string, now time.Time) (memoryCacheEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && entry.expired(now) {
+		delete(m.entries, key)
+		return memoryCacheEntry{}, false
+	}
+	return entry, ok
+}
+
+// 定期清理过期的条目，调用方需持有锁
+func (m *memoryCache) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, entry := range m.entries {
+		if entry.expired(now) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *memoryCache) Set(ctx context.Context, key, value string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	entry := memoryCacheEntry{value: value}
+	if expiration > 0 {
+		entry.expiresAt = now.Add(expiration)
+	}
+	m.entries[key] = entry
+	return nil
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key, time.Now())
+	if !ok {
+		return "", ErrCacheMiss
+	}
+	return entry.value, nil
+}
+
+func (m *memoryCache) GetDel(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key, time.Now())
+	if !ok {
+		return "", ErrCacheMiss
+	}
+	delete(m.entries, key)
+	return entry.value, nil
+}
+
+func (m *memoryCache) Del(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *memoryCache) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.lookup(key, time.Now())
+	return ok, nil
+}
+
+func (m *memoryCache) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	// 与INCR一致：保留原有的过期时间，键不存在时从0开始
+	entry, ok := m.lookup(key, now)
+	if !ok && expiration > 0 {
+		entry.expiresAt = now.Add(expiration)
+	}
+	count := int64(0)
+	if entry.value != "" {
+		var err error
+		if count, err = strconv.ParseInt(entry.value, 10, 64); err != nil {
+			return 0, fmt.Errorf("value of %s is not an integer", key)
+		}
+	}
+	count++
+	entry.value = strconv.FormatInt(count, 10)
+	m.entries[key] = entry
+	return count, nil
+}
+
+func (m *memoryCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := m.lookup(key, now)
+	if !ok {
+		return nil
+	}
+	if expiration <= 0 {
+		delete(m.entries, key)
+		return nil
+	}
+	entry.expiresAt = now.Add(expiration)
+	m.entries[key] = entry
+	return nil
+}
+
+func (m *memoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := m.lookup(key, now)
+	switch {
+	case !ok:
+		return -2, nil
+	case entry.expiresAt.IsZero():
+		return -1, nil
+	default:
+		return entry.expiresAt.Sub(now), nil
+	}
+}
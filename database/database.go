@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/logging"
+	"gin-auth-project/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var DB *gorm.DB
+
+// 支持的数据库驱动
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// 连接数据库（未就绪时重试直到ctx结束）、执行迁移并写入内置角色
+func InitDatabase(ctx context.Context) error {
+	if err := retry(ctx, "database", Connect); err != nil {
+		return err
+	}
+
+	slog.Info("Successfully connected to database", "driver", DB.Dialector.Name())
+
+	// 执行数据库迁移，关闭后需要在发布时单独运行 migrate up
+	if config.Current().DBAutoMigrate {
+		applied, err := MigrateUp(DB)
+		if err != nil {
+			return fmt.Errorf("migrate database: %w", err)
+		}
+		slog.Info("Database migration completed", "applied", applied)
+	}
+
+	// 写入权限目录和内置角色
+	if err := SeedRBAC(); err != nil {
+		return fmt.Errorf("seed roles and permissions: %w", err)
+	}
+
+	// 提示通过命令行创建第一个管理员
+	warnIfNoAdmin()
+	return nil
+}
+
+// 依次关闭Redis和数据库连接池，在HTTP服务停止接收请求后调用
+func Close() error {
+	var errs []error
+	if RedisClient != nil {
+		if err := RedisClient.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close redis: %w", err))
+		}
+	}
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("close database: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 只建立数据库连接，不执行迁移和初始化数据；ctx结束时停止等待，连接失败时关闭本次打开的连接池
+func Connect(ctx context.Context) error {
+	cfg := config.Current()
+
+	var db *gorm.DB
+	var err error
+	switch cfg.DBDriver {
+	case DriverPostgres, "":
+		db, err = OpenPostgres(cfg)
+	case DriverSQLite:
+		db, err = OpenSQLite(cfg.SQLitePath)
+	default:
+		err = fmt.Errorf("unsupported database driver %q", cfg.DBDriver)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := ping(ctx, db); err != nil {
+		closeDB(db)
+		return err
+	}
+	DB = db
+	return nil
+}
+
+// 测试数据库连接，单次最长等待5秒
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(pingCtx)
+}
+
+// 关闭连接池，用于连接失败时释放已打开的连接
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// 连接PostgreSQL
+func OpenPostgres(cfg *config.Config) (*gorm.DB, error) {
+	// connect_timeout避免数据库不可达时单次连接长时间阻塞，由调用方决定是否重试
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s connect_timeout=5 TimeZone=Asia/Shanghai",
+		cfg.DBHost,
+		cfg.DBUser,
+		cfg.DBPassword,
+		cfg.DBName,
+		cfg.DBPort,
+		cfg.DBSSLMode,
+	)
+
+	db, err := gorm.Open(postgres.Open(dsn), gormConfig())
+	if err != nil {
+		if db != nil {
+			closeDB(db)
+		}
+		return nil, err
+	}
+	return db, nil
+}
+
+// 打开SQLite数据库文件，path为":memory:"时使用内存数据库，用于本地开发和测试
+func OpenSQLite(path string) (*gorm.DB, error) {
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), gormConfig())
+	if err != nil {
+		if db != nil {
+			closeDB(db)
+		}
+		return nil, err
+	}
+
+	// SQLite同一时间只允许一个写入者，只使用一个连接避免并发写入时返回database is locked；
+	// 内存数据库也只在同一个连接内可见
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// SQL日志输出到slog，超过DB_SLOW_QUERY_MS的语句记录为慢查询
+func gormConfig() *gorm.Config {
+	var slowThreshold time.Duration
+	if cfg := config.Current(); cfg != nil {
+		slowThreshold = time.Duration(cfg.DBSlowQueryMS) * time.Millisecond
+	}
+	// 由Connect使用调用方的ctx测试连接，gorm.Open不再自行ping
+	return &gorm.Config{Logger: logging.NewGormLogger(slowThreshold), DisableAutomaticPing: true}
+}
+
+// 没有管理员时提示使用命令行创建，不再自动写入默认账号
+func warnIfNoAdmin() {
+	var count int64
+	if err := DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&count).Error; err != nil {
+		slog.Error("Failed to count admin users", "error", err)
+		return
+	}
+
+	if count == 0 {
+		slog.Warn("No admin user exists, create one with: gin-auth-project user create --role admin --username <name> --email <email> --verified")
+	}
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// 登录失败计数和锁定标记的键前缀
+const (
+	loginFailurePrefix = "login_fail:"
+	loginLockPrefix    = "login_lock:"
+)
+
+// 登录失败计数和锁定依赖缓存，未初始化时不启用
+func LoginAttemptsEnabled() bool {
+	return CacheEnabled()
+}
+
+// 记录一次登录失败，窗口内达到limit次时锁定lockout时长并清零计数，返回是否触发了锁定
+func RecordLoginFailure(ctx context.Context, key string, limit int, window, lockout time.Duration) (bool, error) {
+	failures, err := incrCache(ctx, loginFailurePrefix+key, window)
+	if err != nil {
+		return false, err
+	}
+	if limit <= 0 || failures < int64(limit) {
+		return false, nil
+	}
+
+	if err := setCache(ctx, loginLockPrefix+key, "locked", lockout); err != nil {
+		return false, err
+	}
+	return true, deleteCache(ctx, loginFailurePrefix+key)
+}
+
+// 当前窗口内的失败次数
+func LoginFailures(ctx context.Context, key string) (int, error) {
+	value, err := getCache(ctx, loginFailurePrefix+key)
+	if errors.Is(err, ErrCacheMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// 清除失败计数
+func ClearLoginFailures(ctx context.Context, key string) error {
+	return deleteCache(ctx, loginFailurePrefix+key)
+}
+
+// 锁定的剩余时间，未锁定时返回0
+func LoginLockRemaining(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := ttlCache(ctx, loginLockPrefix+key)
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// 解除锁定并清除失败计数
+func UnlockLogin(ctx context.Context, key string) error {
+	if err := deleteCache(ctx, loginLockPrefix+key); err != nil {
+		return err
+	}
+	return deleteCache(ctx, loginFailurePrefix+key)
+}
@@ -0,0 +1,342 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 内置的SQL迁移文件，每种数据库一个目录（与GORM的Dialector名称一致），
+// 命名格式为 <版本号>_<名称>.up.sql / <版本号>_<名称>.down.sql
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var embeddedMigrations embed.FS
+
+// 迁移期间持有的Postgres会话级咨询锁，避免多个实例同时启动时重复迁移
+const migrationLockID int64 = 7_264_120_415
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// 一个版本的迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// 迁移状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time // 为nil表示尚未执行
+}
+
+// 指定数据库（postgres或sqlite）内置的全部迁移，按版本号升序
+func Migrations(dialect string) ([]Migration, error) {
+	sub, err := fs.Sub(embeddedMigrations, path.Join("migrations", dialect))
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) == 0 {
+		return nil, fmt.Errorf("no migrations for database %q", dialect)
+	}
+	return migrations, nil
+}
+
+// 从目录读取迁移，每个版本必须同时有up和down文件
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+var addColumnIfNotExistsPattern = regexp.MustCompile(`(?m)^ALTER TABLE (\w+)\s+ADD COLUMN IF NOT EXISTS (\w+)\b([^;]*);`)
+
+// SQLite不支持ADD COLUMN IF NOT EXISTS：执行前检查列是否已存在，已存在时去掉该语句，否则改写为ADD COLUMN
+func expandAddColumnIfNotExists(tx *gorm.DB, sql string) string {
+	if tx.Dialector.Name() != DriverSQLite {
+		return sql
+	}
+
+	return addColumnIfNotExistsPattern.ReplaceAllStringFunc(sql, func(statement string) string {
+		match := addColumnIfNotExistsPattern.FindStringSubmatch(statement)
+		if tx.Migrator().HasColumn(match[1], match[2]) {
+			return ""
+		}
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s%s;", match[1], match[2], match[3])
+	})
+}
+
+// 执行全部未执行的迁移，返回本次执行的数量
+func MigrateUp(db *gorm.DB) (int, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		done, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(expandAddColumnIfNotExists(tx, migration.Up)).Error; err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{
+					Version:   migration.Version,
+					Name:      migration.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// 按执行顺序倒序回滚最近的steps个迁移，返回本次回滚的数量
+func MigrateDown(db *gorm.DB, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, fmt.Errorf("steps must be positive")
+	}
+
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return 0, err
+	}
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	reverted := 0
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		var records []SchemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&records).Error; err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			migration, ok := byVersion[record.Version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is not known to this binary", record.Version, record.Name)
+			}
+
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			slog.Info("Reverted migration", "version", migration.Version, "name", migration.Name)
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// 全部迁移的执行状态，包括数据库中有记录但当前程序不认识的版本
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureMigrationTable(db); err != nil {
+		return nil, err
+	}
+	done, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := done[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range done {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name, AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// 尚未执行的迁移数量和已执行的最新版本，用于就绪检查；不会创建迁移记录表
+func PendingMigrations(ctx context.Context, db *gorm.DB) (int, int64, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return 0, 0, err
+	}
+	done, err := appliedMigrations(db.WithContext(ctx))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pending := 0
+	var version int64
+	for _, migration := range migrations {
+		if _, ok := done[migration.Version]; !ok {
+			pending++
+		}
+	}
+	for applied := range done {
+		if applied > version {
+			version = applied
+		}
+	}
+	return pending, version, nil
+}
+
+// 在同一个数据库连接上持有咨询锁并执行迁移
+// SQLite只用于单进程的本地开发和测试，写事务本身会锁住数据库文件，不需要额外加锁
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if db.Dialector.Name() != "postgres" {
+		if err := ensureMigrationTable(db); err != nil {
+			return err
+		}
+		return fn(db)
+	}
+
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID).Error; err != nil {
+				slog.Error("Failed to release migration lock", "error", err)
+			}
+		}()
+
+		if err := ensureMigrationTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func ensureMigrationTable(db *gorm.DB) error {
+	// SQLite驱动只把声明为DATETIME的列解析为时间
+	timeType := "TIMESTAMPTZ"
+	if db.Dialector.Name() == "sqlite" {
+		timeType = "DATETIME"
+	}
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at ` + timeType + ` NOT NULL
+	)`).Error
+}
+
+func appliedMigrations(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	done := make(map[int64]SchemaMigration, len(records))
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
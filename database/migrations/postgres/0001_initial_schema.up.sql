@@ -0,0 +1,239 @@
+-- 初始表结构，与此前GORM AutoMigrate创建的表一致
+-- 使用IF NOT EXISTS，已由AutoMigrate建表的数据库可以直接执行
+
+CREATE TABLE IF NOT EXISTS users (
+    id                  BIGSERIAL PRIMARY KEY,
+    username            TEXT NOT NULL,
+    email               TEXT NOT NULL,
+    password            TEXT NOT NULL,
+    role                TEXT DEFAULT 'user',
+    is_active           BOOLEAN DEFAULT true,
+    created_at          TIMESTAMPTZ,
+    updated_at          TIMESTAMPTZ,
+    deleted_at          TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+-- 以上为最初由AutoMigrate创建的users表，此后新增的列单独添加，已有的users表同样会补齐
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified BOOLEAN DEFAULT false;
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMPTZ;
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN DEFAULT false;
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS totp_last_step BIGINT DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS user_profiles (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT,
+    first_name TEXT,
+    last_name  TEXT,
+    phone      TEXT,
+    avatar     TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    CONSTRAINT fk_user_profiles_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_profiles_deleted_at ON user_profiles (deleted_at);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        BIGINT NOT NULL,
+    family_id      TEXT NOT NULL,
+    token_hash     TEXT NOT NULL,
+    expires_at     TIMESTAMPTZ,
+    revoked_at     TIMESTAMPTZ,
+    replaced_by_id BIGINT,
+    created_at     TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id           VARCHAR(64) PRIMARY KEY,
+    user_id      BIGINT NOT NULL,
+    device       TEXT,
+    ip           TEXT,
+    user_agent   TEXT,
+    auth_methods TEXT,
+    client_id    TEXT,
+    scope        TEXT,
+    org_id       BIGINT,
+    created_at   TIMESTAMPTZ,
+    last_seen_at TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    code_hash  TEXT NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS web_authn_credentials (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          BIGINT NOT NULL,
+    name             TEXT,
+    credential_id    BYTEA NOT NULL,
+    public_key       BYTEA NOT NULL,
+    attestation_type TEXT,
+    aa_guid          BYTEA,
+    sign_count       BIGINT,
+    flags            SMALLINT,
+    transports       TEXT,
+    created_at       TIMESTAMPTZ,
+    last_used_at     TIMESTAMPTZ,
+    CONSTRAINT fk_users_web_authn_credentials FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_web_authn_credentials_user_id ON web_authn_credentials (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_web_authn_credentials_credential_id ON web_authn_credentials (credential_id);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS o_auth_clients (
+    id                 BIGSERIAL PRIMARY KEY,
+    client_id          VARCHAR(64) NOT NULL,
+    client_secret_hash TEXT,
+    name               TEXT NOT NULL,
+    redirect_uris      TEXT NOT NULL,
+    scopes             TEXT,
+    public             BOOLEAN DEFAULT false,
+    created_at         TIMESTAMPTZ,
+    updated_at         TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_o_auth_clients_client_id ON o_auth_clients (client_id);
+
+CREATE TABLE IF NOT EXISTS o_auth_authorization_codes (
+    id                    BIGSERIAL PRIMARY KEY,
+    code_hash             TEXT NOT NULL,
+    client_id             TEXT NOT NULL,
+    user_id               BIGINT NOT NULL,
+    session_id            VARCHAR(64),
+    redirect_uri          TEXT,
+    scope                 TEXT,
+    nonce                 TEXT,
+    code_challenge        TEXT,
+    code_challenge_method TEXT,
+    expires_at            TIMESTAMPTZ,
+    used_at               TIMESTAMPTZ,
+    grant_session_id      VARCHAR(64),
+    created_at            TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_o_auth_authorization_codes_client_id ON o_auth_authorization_codes (client_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_o_auth_authorization_codes_code_hash ON o_auth_authorization_codes (code_hash);
+
+CREATE TABLE IF NOT EXISTS o_auth_consents (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    client_id  VARCHAR(64) NOT NULL,
+    scope      TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_consent_user_client ON o_auth_consents (user_id, client_id);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL,
+    name         TEXT NOT NULL,
+    token_prefix TEXT,
+    token_hash   TEXT NOT NULL,
+    scopes       TEXT,
+    auth_methods TEXT,
+    org_id       BIGINT,
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id          BIGSERIAL PRIMARY KEY,
+    name        VARCHAR(100) NOT NULL,
+    description TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permissions_name ON permissions (name);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id          BIGSERIAL PRIMARY KEY,
+    name        VARCHAR(50) NOT NULL,
+    description TEXT,
+    is_system   BOOLEAN DEFAULT false,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_name ON roles (name);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id       BIGINT NOT NULL,
+    permission_id BIGINT NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    CONSTRAINT fk_role_permissions_role_definition FOREIGN KEY (role_id) REFERENCES roles (id),
+    CONSTRAINT fk_role_permissions_permission FOREIGN KEY (permission_id) REFERENCES permissions (id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id BIGINT NOT NULL,
+    role_id BIGINT NOT NULL,
+    PRIMARY KEY (user_id, role_id),
+    CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_user_roles_role_definition FOREIGN KEY (role_id) REFERENCES roles (id)
+);
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    slug       VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organizations_slug ON organizations (slug);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    id              BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL,
+    user_id         BIGINT NOT NULL,
+    role            VARCHAR(50) NOT NULL,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    CONSTRAINT fk_memberships_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_membership_org_user ON memberships (organization_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_memberships_user_id ON memberships (user_id);
+
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id              BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL,
+    email           TEXT NOT NULL,
+    role            VARCHAR(50) NOT NULL,
+    token_hash      TEXT NOT NULL,
+    invited_by      BIGINT,
+    expires_at      TIMESTAMPTZ,
+    accepted_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_organization_id ON organization_invitations (organization_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_token_hash ON organization_invitations (token_hash);
@@ -0,0 +1,8 @@
+-- 邮箱验证上线前注册的用户没有收到过验证邮件，email_verified为false；
+-- 将执行第一个版本化迁移之前已存在的用户标记为已验证，避免开启EMAIL_VERIFICATION_REQUIRED后无法登录。
+-- 新建的数据库中第一个迁移先于任何用户执行，不受影响
+
+UPDATE users
+    SET email_verified = true
+    WHERE (email_verified IS NULL OR email_verified = false)
+      AND created_at < (SELECT applied_at FROM schema_migrations WHERE version = 1);
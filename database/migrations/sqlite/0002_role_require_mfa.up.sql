@@ -0,0 +1,4 @@
+-- 角色可以要求拥有该角色的用户使用两步验证，由管理员通过角色管理接口设置
+
+ALTER TABLE roles
+    ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT false;
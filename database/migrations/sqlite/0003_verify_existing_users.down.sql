@@ -0,0 +1,2 @@
+-- 无法区分哪些用户由该迁移标记为已验证，回滚时保留email_verified
+SELECT 1;
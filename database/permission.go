@@ -0,0 +1,208 @@
+package database
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-auth-project/models"
+
+	"gorm.io/gorm"
+)
+
+// 用户权限缓存键前缀，键中包含版本号，角色变更时递增版本号使全部缓存失效
+const (
+	permissionCachePrefix = "permissions:"
+	permissionVersionKey  = "permissions:version"
+	permissionCacheTTL    = 10 * time.Minute
+)
+
+// 写入权限目录和内置角色，管理员角色始终拥有全部权限
+func SeedRBAC() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		permissions := make(map[string]models.Permission)
+		for _, info := range models.PermissionCatalog {
+			permission := models.Permission{Name: info.Name}
+			err := tx.Where(models.Permission{Name: info.Name}).
+				Assign(models.Permission{Description: info.Description}).
+				FirstOrCreate(&permission).Error
+			if err != nil {
+				return err
+			}
+			permissions[info.Name] = permission
+		}
+
+		for _, name := range []models.Role{models.RoleAdmin, models.RoleUser} {
+			var role models.RoleDefinition
+			result := tx.Where("name = ?", name).First(&role)
+			created := false
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				role = models.RoleDefinition{Name: name, Description: "Built-in " + string(name) + " role", IsSystem: true}
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+				created = true
+			} else if result.Error != nil {
+				return result.Error
+			}
+
+			// 普通用户角色的权限只在首次创建时写入，之后可以通过管理接口调整
+			if !created && name != models.RoleAdmin {
+				continue
+			}
+
+			var granted []models.Permission
+			for _, permissionName := range models.DefaultRolePermissions[name] {
+				granted = append(granted, permissions[permissionName])
+			}
+			if err := tx.Model(&role).Association("Permissions").Replace(granted); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 获取用户的全部权限（主角色和附加角色），优先读取缓存
+func UserPermissions(user *models.User) ([]string, error) {
+	// 主角色也是键的一部分，修改主角色后无需等待缓存过期
+	suffix := strconv.Itoa(int(user.ID)) + ":" + string(user.Role)
+	return cachedPermissions(suffix, func() ([]string, error) {
+		return LoadUserPermissions(user)
+	})
+}
+
+// 从数据库查询用户的全部权限
+func LoadUserPermissions(user *models.User) ([]string, error) {
+	var permissions []string
+	err := DB.Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ? OR roles.id IN (?)", user.Role,
+			DB.Table("user_roles").Select("role_id").Where("user_id = ?", user.ID)).
+		Order("permissions.name").
+		Pluck("permissions.name", &permissions).Error
+	return permissions, err
+}
+
+// 用户的主角色或附加角色中是否有要求两步验证的角色，与权限一起缓存并同时失效；
+// 未连接数据库时（只使用内存仓库）没有角色
+func UserRequiresMFA(user *models.User) (bool, error) {
+	if DB == nil {
+		return false, nil
+	}
+	suffix := strconv.Itoa(int(user.ID)) + ":" + string(user.Role) + ":require_mfa"
+	value, err := cachedEntry(suffix, func() (string, error) {
+		required, err := LoadUserRequiresMFA(user)
+		return strconv.FormatBool(required), err
+	})
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// 从数据库查询用户的角色是否要求两步验证
+func LoadUserRequiresMFA(user *models.User) (bool, error) {
+	var count int64
+	err := DB.Model(&models.RoleDefinition{}).
+		Where("require_mfa = ?", true).
+		Where("name = ? OR id IN (?)", user.Role,
+			DB.Table("user_roles").Select("role_id").Where("user_id = ?", user.ID)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 获取用户在组织内的权限（成员角色中组织内有效的权限），不是成员时为空
+func MembershipPermissions(userID, orgID uint) ([]string, error) {
+	suffix := strconv.Itoa(int(userID)) + ":org:" + strconv.Itoa(int(orgID))
+	return cachedPermissions(suffix, func() ([]string, error) {
+		return LoadMembershipPermissions(userID, orgID)
+	})
+}
+
+// 从数据库查询用户在组织内的权限
+func LoadMembershipPermissions(userID, orgID uint) ([]string, error) {
+	var names []string
+	err := DB.Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Joins("JOIN memberships ON memberships.role = roles.name").
+		Where("memberships.user_id = ? AND memberships.organization_id = ?", userID, orgID).
+		Order("permissions.name").
+		Pluck("permissions.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]string, 0, len(names))
+	for _, name := range names {
+		if models.IsOrgScopedPermission(name) {
+			permissions = append(permissions, name)
+		}
+	}
+	return permissions, nil
+}
+
+// 读取权限缓存，未命中时从数据库加载并写入缓存
+func cachedPermissions(suffix string, load func() ([]string, error)) ([]string, error) {
+	value, err := cachedEntry(suffix, func() (string, error) {
+		permissions, err := load()
+		return strings.Join(permissions, " "), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(value), nil
+}
+
+// 读取当前版本的缓存条目，未命中时加载并写入缓存
+func cachedEntry(suffix string, load func() (string, error)) (string, error) {
+	key := permissionCacheKey(suffix)
+	if key != "" {
+		if cached, err := GetCache(key); err == nil {
+			return cached, nil
+		}
+	}
+
+	value, err := load()
+	if err != nil {
+		return "", err
+	}
+
+	if key != "" {
+		if err := SetCache(key, value, permissionCacheTTL); err != nil {
+			slog.Warn("Failed to cache permissions", "key", suffix, "error", err)
+		}
+	}
+	return value, nil
+}
+
+// 使所有用户的权限缓存失效，在角色、角色权限、用户角色或组织成员变更后调用
+func InvalidatePermissions() {
+	if !CacheEnabled() {
+		return
+	}
+	if _, err := IncrCache(permissionVersionKey, 0); err != nil {
+		slog.Error("Failed to invalidate permission cache", "error", err)
+	}
+}
+
+// 当前版本的缓存键，缓存不可用时返回空字符串（直接查询数据库）
+func permissionCacheKey(suffix string) string {
+	if !CacheEnabled() {
+		return ""
+	}
+
+	version, err := GetCache(permissionVersionKey)
+	if errors.Is(err, ErrCacheMiss) {
+		version = "0"
+	} else if err != nil {
+		return ""
+	}
+	return permissionCachePrefix + version + ":" + suffix
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 限流计数键前缀
+const rateLimitPrefix = "ratelimit:"
+
+// 访问Redis限流计数的超时时间，超时后回退到进程内限流
+const rateLimitTimeout = 200 * time.Millisecond
+
+// 限流判断结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // 被拒绝时距离下一次允许的时间
+	Reset      time.Duration // 配额完全恢复的时间
+}
+
+// GCRA（通用信元速率算法）：每个键只保存理论到达时间（TAT），
+// 每period允许limit个请求并允许突发用完全部配额。使用Redis服务器时间，多个副本之间不受时钟偏差影响
+var rateLimitScript = redis.NewScript(`
+local key = KEYS[1]
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local tat = tonumber(redis.call('GET', key))
+if tat == nil or tat < now then
+	tat = now
+end
+
+if tat - now > tolerance then
+	return {0, tat - now - tolerance, tat - now}
+end
+
+local new_tat = tat + interval
+redis.call('SET', key, new_tat, 'PX', new_tat - now)
+return {1, 0, new_tat - now}
+`)
+
+// 是否已经在使用进程内限流，用于只在状态切换时记录日志
+var rateLimitDegraded atomic.Bool
+
+// 判断一次请求是否允许，Redis不可用时回退到进程内限流（此时限制只对单个副本生效）
+func AllowRate(key string, limit int, period time.Duration) RateLimitResult {
+	interval, tolerance := gcraParams(limit, period)
+
+	if RedisClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), rateLimitTimeout)
+		defer cancel()
+
+		values, err := rateLimitScript.Run(ctx, RedisClient, []string{rateLimitPrefix + key},
+			interval.Milliseconds(), tolerance.Milliseconds()).Int64Slice()
+		if err == nil && len(values) == 3 {
+			if rateLimitDegraded.CompareAndSwap(true, false) {
+				slog.Info("Redis rate limiting restored")
+			}
+			return newRateLimitResult(values[0] == 1, limit, interval,
+				time.Duration(values[1])*time.Millisecond, time.Duration(values[2])*time.Millisecond)
+		}
+		if rateLimitDegraded.CompareAndSwap(false, true) {
+			slog.Warn("Redis rate limiting unavailable, using in-memory fallback", "error", err)
+		}
+	}
+
+	return localRateLimiter.allow(key, limit, interval, tolerance)
+}
+
+// 每个请求占用的时间间隔，以及允许的突发容量（用完全部配额）
+func gcraParams(limit int, period time.Duration) (time.Duration, time.Duration) {
+	if limit < 1 {
+		limit = 1
+	}
+	interval := period / time.Duration(limit)
+	return interval, period - interval
+}
+
+func newRateLimitResult(allowed bool, limit int, interval, retryAfter, reset time.Duration) RateLimitResult {
+	result := RateLimitResult{Allowed: allowed, Limit: limit, RetryAfter: retryAfter, Reset: reset}
+	if allowed && interval > 0 {
+		// 已占用的配额向上取整
+		used := int((reset + interval - 1) / interval)
+		result.Remaining = limit - used
+		if result.Remaining < 0 {
+			result.Remaining = 0
+		}
+	}
+	return result
+}
+
+// 进程内限流，Redis不可用时作为回退
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+var localRateLimiter = &memoryRateLimiter{tats: make(map[string]time.Time)}
+
+func (m *memoryRateLimiter) allow(key string, limit int, interval, tolerance time.Duration) RateLimitResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	tat, ok := m.tats[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+
+	if tat.Sub(now) > tolerance {
+		return newRateLimitResult(false, limit, interval, tat.Sub(now)-tolerance, tat.Sub(now))
+	}
+
+	newTAT := tat.Add(interval)
+	m.tats[key] = newTAT
+	return newRateLimitResult(true, limit, interval, 0, newTAT.Sub(now))
+}
+
+// 定期清理配额已经完全恢复的键
+func (m *memoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, tat := range m.tats {
+		if tat.Before(now) {
+			delete(m.tats, key)
+		}
+	}
+}
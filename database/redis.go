@@ -2,8 +2,9 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"gin-auth-project/config"
@@ -13,54 +14,87 @@ import (
 
 var RedisClient *redis.Client
 
-func InitRedis() {
-	cfg := config.AppConfig
+var errRedisNotInitialized = errors.New("redis client is not initialized")
 
-	RedisClient = redis.NewClient(&redis.Options{
+// 连接Redis，未就绪时重试直到ctx结束
+func InitRedis(ctx context.Context) error {
+	cfg := config.Current()
+
+	client := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
 		Password: cfg.RedisPassword,
 		DB:       cfg.RedisDB,
 	})
 
 	// 测试连接
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := RedisClient.Ping(ctx).Result()
+	err := retry(ctx, "redis", func(ctx context.Context) error {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return client.Ping(pingCtx).Err()
+	})
 	if err != nil {
-		log.Fatal("Failed to connect to Redis:", err)
+		client.Close()
+		return err
+	}
+
+	UseRedis(client)
+	slog.Info("Successfully connected to Redis", "addr", client.Options().Addr)
+	return nil
+}
+
+// 使用Redis作为缓存，同时用于限流和令牌黑名单；为nil时关闭缓存
+func UseRedis(client *redis.Client) {
+	RedisClient = client
+	if client == nil {
+		UseCache(nil)
+		return
 	}
+	UseCache(redisCache{client: client})
+}
 
-	log.Println("Successfully connected to Redis")
+// 基于Redis的缓存后端
+type redisCache struct {
+	client *redis.Client
 }
 
-// 设置缓存
-func SetCache(key string, value interface{}, expiration time.Duration) error {
-	ctx := context.Background()
-	return RedisClient.Set(ctx, key, value, expiration).Err()
+func (r redisCache) Set(ctx context.Context, key, value string, expiration time.Duration) error {
+	return r.client.Set(ctx, key, value, expiration).Err()
 }
 
-// 获取缓存
-func GetCache(key string) (string, error) {
-	ctx := context.Background()
-	return RedisClient.Get(ctx, key).Result()
+func (r redisCache) Get(ctx context.Context, key string) (string, error) {
+	return r.client.Get(ctx, key).Result()
 }
 
-// 删除缓存
-func DeleteCache(key string) error {
-	ctx := context.Background()
-	return RedisClient.Del(ctx, key).Err()
+func (r redisCache) GetDel(ctx context.Context, key string) (string, error) {
+	return r.client.GetDel(ctx, key).Result()
 }
 
-// 检查键是否存在
-func ExistsCache(key string) (bool, error) {
-	ctx := context.Background()
-	result, err := RedisClient.Exists(ctx, key).Result()
+func (r redisCache) Del(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r redisCache) Exists(ctx context.Context, key string) (bool, error) {
+	result, err := r.client.Exists(ctx, key).Result()
 	return result > 0, err
 }
 
-// 设置过期时间
-func ExpireCache(key string, expiration time.Duration) error {
-	ctx := context.Background()
-	return RedisClient.Expire(ctx, key, expiration).Err()
+// INCR和设置过期时间在Lua脚本中原子执行，进程在两步之间退出时不会留下永不过期的计数器
+var incrScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (r redisCache) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return incrScript.Run(ctx, r.client, []string{key}, expiration.Milliseconds()).Int64()
+}
+
+func (r redisCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return r.client.Expire(ctx, key, expiration).Err()
+}
+
+func (r redisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return r.client.TTL(ctx, key).Result()
 }
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// 启动时重试连接依赖的退避时间
+const (
+	retryInitialDelay = 500 * time.Millisecond
+	retryMaxDelay     = 10 * time.Second
+)
+
+// 依赖可能比应用晚启动（如容器同时启动时的数据库或Redis），按指数退避重试直到成功或ctx结束
+func retry(ctx context.Context, dependency string, connect func(ctx context.Context) error) error {
+	delay := retryInitialDelay
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			return nil
+		}
+
+		slog.Warn("Dependency not ready, retrying", "dependency", dependency, "attempt", attempt, "retry_in", delay.String(), "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s not ready after %d attempts: %w", dependency, attempt, err)
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
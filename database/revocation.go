@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gin-auth-project/config"
+)
+
+// 令牌黑名单键前缀
+const blacklistPrefix = "blacklist:"
+
+// 访问Redis黑名单的超时时间，避免Redis故障时拖慢每个请求
+const revocationTimeout = 500 * time.Millisecond
+
+// 进程内黑名单，Redis不可用时作为回退
+type memoryBlacklist struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+	// 尚未成功写入Redis的条目，Redis恢复后补写
+	pending map[string]time.Time
+}
+
+var localBlacklist = &memoryBlacklist{
+	entries: make(map[string]time.Time),
+	pending: make(map[string]time.Time),
+}
+
+func (m *memoryBlacklist) add(key string, expiresAt time.Time, synced bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range m.entries {
+		if !exp.After(now) {
+			delete(m.entries, k)
+			delete(m.pending, k)
+		}
+	}
+
+	m.entries[key] = expiresAt
+	if !synced {
+		m.pending[key] = expiresAt
+	}
+}
+
+func (m *memoryBlacklist) contains(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	exp, ok := m.entries[key]
+	return ok && exp.After(time.Now())
+}
+
+// ResetLocalRevocations 清空进程内黑名单，用于模拟新启动的实例
+func ResetLocalRevocations() {
+	localBlacklist.mu.Lock()
+	defer localBlacklist.mu.Unlock()
+
+	localBlacklist.entries = make(map[string]time.Time)
+	localBlacklist.pending = make(map[string]time.Time)
+}
+
+// 取出待补写的条目
+func (m *memoryBlacklist) takePending() map[string]time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.pending) == 0 {
+		return nil
+	}
+	pending := m.pending
+	m.pending = make(map[string]time.Time)
+	return pending
+}
+
+// RevokeToken 将令牌加入黑名单，条目在令牌过期时自动失效
+// Redis写入失败时令牌仍在本进程内被吊销，返回的错误仅用于记录
+func RevokeToken(tokenID string, expiresAt time.Time) error {
+	if tokenID == "" || !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	key := blacklistPrefix + tokenID
+	err := setBlacklistEntry(key, expiresAt)
+	localBlacklist.add(key, expiresAt, err == nil)
+	if err != nil {
+		slog.Warn("Failed to write token blacklist to Redis, using in-memory fallback", "error", err)
+		return err
+	}
+
+	flushPendingRevocations()
+	return nil
+}
+
+// IsTokenRevoked 检查令牌是否已被吊销
+// Redis查询失败时视为已吊销，设置REVOCATION_FAIL_OPEN后只检查进程内黑名单
+func IsTokenRevoked(tokenID string) bool {
+	if tokenID == "" {
+		return false
+	}
+
+	key := blacklistPrefix + tokenID
+	if localBlacklist.contains(key) {
+		return true
+	}
+
+	if RedisClient == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationTimeout)
+	defer cancel()
+
+	count, err := RedisClient.Exists(ctx, key).Result()
+	if err != nil {
+		// 其他实例的吊销只记录在Redis中，默认失败关闭，避免接受已登出的令牌
+		failOpen := config.Current().RevocationFailOpen
+		slog.Warn("Failed to check token blacklist in Redis", "error", err, "fail_open", failOpen)
+		return !failOpen
+	}
+
+	flushPendingRevocations()
+	return count > 0
+}
+
+func setBlacklistEntry(key string, expiresAt time.Time) error {
+	if RedisClient == nil {
+		return errRedisNotInitialized
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationTimeout)
+	defer cancel()
+
+	return RedisClient.Set(ctx, key, "revoked", ttl).Err()
+}
+
+// Redis恢复后补写故障期间只记录在本进程的吊销
+func flushPendingRevocations() {
+	pending := localBlacklist.takePending()
+
+	var err error
+	for key, expiresAt := range pending {
+		if err == nil {
+			err = setBlacklistEntry(key, expiresAt)
+			if err == nil {
+				continue
+			}
+		}
+		localBlacklist.add(key, expiresAt, false)
+	}
+}
@@ -0,0 +1,58 @@
+// Package events 在认证流程的关键节点发布事件（登录、注册、令牌签发和吊销），
+// 指标等模块通过Subscribe订阅，发布方不依赖任何订阅者
+package events
+
+import "sync"
+
+type Type string
+
+const (
+	LoginSucceeded Type = "login_succeeded"
+	LoginFailed    Type = "login_failed"
+	LoginBlocked   Type = "login_blocked" // 账号或IP处于锁定状态，未校验凭据
+	AccountLocked  Type = "account_locked"
+	IPLocked       Type = "ip_locked"
+	UserRegistered Type = "user_registered"
+	TokenIssued    Type = "token_issued"
+	TokenRevoked   Type = "token_revoked"
+)
+
+// 令牌类型
+const (
+	TokenAccess   = "access"
+	TokenRefresh  = "refresh"
+	TokenPersonal = "personal_access"
+	TokenSession  = "session"
+)
+
+type Event struct {
+	Type Type
+	// 令牌类型，仅用于TokenIssued和TokenRevoked
+	Token string
+	// 涉及的数量，为0时按1计
+	Count int
+}
+
+var (
+	mu        sync.RWMutex
+	listeners []func(Event)
+)
+
+// 注册订阅者，订阅者在发布方的goroutine中同步调用，不能阻塞
+func Subscribe(listener func(Event)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, listener)
+}
+
+// 发布事件
+func Publish(event Event) {
+	if event.Count == 0 {
+		event.Count = 1
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccessTokenHandler struct {
+	auth  *services.AuthService
+	users *services.UserService
+}
+
+func NewAccessTokenHandler(auth *services.AuthService, users *services.UserService) *AccessTokenHandler {
+	return &AccessTokenHandler{auth: auth, users: users}
+}
+
+// 个人访问令牌的默认有效期（天）
+const defaultAccessTokenDays = 30
+
+// 创建个人访问令牌，明文令牌只在创建时返回一次
+func (h *AccessTokenHandler) CreateMyToken(c *gin.Context) {
+	var req models.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if utils.ContainsScope(req.Scopes, models.AccessTokenScopeAdmin) && !hasPrivilegedPermission(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only users with administrative permissions can create tokens with the admin scope"})
+		return
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAccessTokenDays
+	}
+
+	token, raw, err := h.auth.CreateAccessToken(c.Request.Context(), user, services.CreateAccessTokenInput{
+		Name:        req.Name,
+		Scopes:      req.Scopes,
+		AuthMethods: middleware.GetCurrentClaims(c).AuthMethods,
+		OrgID:       middleware.GetCurrentOrgID(c),
+		ExpiresAt:   time.Now().AddDate(0, 0, days),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Access token created successfully, it will not be shown again",
+		"token":        raw,
+		"access_token": token.ToResponse(),
+	})
+}
+
+// 获取当前用户的有效个人访问令牌
+func (h *AccessTokenHandler) ListMyTokens(c *gin.Context) {
+	h.list(c, middleware.GetCurrentUserID(c))
+}
+
+// 吊销当前用户的个人访问令牌
+func (h *AccessTokenHandler) RevokeMyToken(c *gin.Context) {
+	h.revoke(c, middleware.GetCurrentUserID(c))
+}
+
+// 获取指定用户的有效个人访问令牌（仅管理员）
+func (h *AccessTokenHandler) ListUserTokens(c *gin.Context) {
+	userID, ok := parseAccessibleUserIDParam(h.users, c, models.PermissionUsersManageSessions, false)
+	if !ok {
+		return
+	}
+	h.list(c, userID)
+}
+
+// 吊销指定用户的个人访问令牌（仅管理员）
+func (h *AccessTokenHandler) RevokeUserToken(c *gin.Context) {
+	userID, ok := parseAccessibleUserIDParam(h.users, c, models.PermissionUsersManageSessions, true)
+	if !ok {
+		return
+	}
+	h.revoke(c, userID)
+}
+
+func (h *AccessTokenHandler) list(c *gin.Context, userID uint) {
+	tokens, err := h.auth.AccessTokens(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
+		return
+	}
+
+	responses := make([]models.PersonalAccessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		responses = append(responses, token.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": responses})
+}
+
+func (h *AccessTokenHandler) revoke(c *gin.Context, userID uint) {
+	tokenID, err := strconv.ParseUint(c.Param("tid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := h.auth.RevokePersonalAccessToken(c.Request.Context(), userID, uint(tokenID)); err != nil {
+		if errors.Is(err, services.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access token revoked successfully"})
+}
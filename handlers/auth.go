@@ -1,20 +1,27 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
-	"time"
 
-	"gin-auth-project/database"
+	"gin-auth-project/config"
+	"gin-auth-project/middleware"
 	"gin-auth-project/models"
+	"gin-auth-project/services"
 	"gin-auth-project/utils"
 
-	"gin-auth-project/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-type AuthHandler struct{}
+type AuthHandler struct {
+	auth  *services.AuthService
+	users *services.UserService
+}
+
+func NewAuthHandler(auth *services.AuthService, users *services.UserService) *AuthHandler {
+	return &AuthHandler{auth: auth, users: users}
+}
 
 // 用户登录
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -25,43 +32,89 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 查找用户
-	var user models.User
-	if err := database.DB.Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
+	user, err := h.users.FindByLogin(c.Request.Context(), req.Username)
+	if err != nil && !errors.Is(err, services.ErrUserNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	// 账号或IP被锁定时返回与密码错误相同的响应
+	guard := newLoginGuard(c, req.Username, user)
+	if guard.locked() {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	guard.delay(c.Request.Context())
 
-	// 验证密码
-	if !utils.CheckPassword(req.Password, user.Password) {
+	// 验证密码并检查用户是否激活
+	if err := h.auth.Authenticate(user, req.Password); err != nil {
+		if errors.Is(err, services.ErrUserDeactivated) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+			return
+		}
+		guard.fail()
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// 检查用户是否激活
-	if !user.IsActive {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+	// 检查邮箱是否已验证
+	if !requireVerifiedEmail(c, user) {
 		return
 	}
 
-	// 生成JWT令牌
-	token, err := utils.GenerateToken(&user)
+	// 已启用两步验证时只签发待验证令牌，需在验证接口提交验证码后完成登录
+	if user.TOTPEnabled {
+		mfaToken, err := utils.GenerateMFAPendingToken(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
+
+	h.completeLogin(c, user, req.Device, []string{utils.AuthMethodPassword})
+}
+
+// 完成登录：创建会话并返回令牌对
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, device string, authMethods []string) {
+	// 创建登录会话
+	session, err := createSession(h.auth, c, user, device, authMethods)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
 
-	// 将令牌存储到Redis（可选，用于令牌撤销）
-	cacheKey := "token:" + token
-	err = database.SetCache(cacheKey, user.ID, time.Duration(24)*time.Hour)
+	// 生成访问令牌和刷新令牌
+	tokens, err := h.auth.IssueTokens(c.Request.Context(), user, session)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
-		"user":    user.ToResponse(),
-	})
+	// 登录完成后清除账号的失败计数
+	newLoginGuard(c, "", user).succeed()
+
+	response := gin.H{
+		"message":       "Login successful",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"session_id":    session.ID,
+		"user":          user.ToResponse(),
+	}
+
+	// 提示必须使用两步验证的用户尽快启用
+	if !user.TOTPEnabled && mfaRequired(c, user) {
+		response["mfa_enrollment_required"] = true
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // 用户注册
@@ -72,62 +125,32 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// 检查用户名是否已存在
-	var existingUser models.User
-	if err := database.DB.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-		return
-	}
-
-	// 检查邮箱是否已存在
-	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-		return
-	}
-
-	// 加密密码
-	hashedPassword, err := utils.HashPassword(req.Password)
+	// 检查用户名和邮箱是否已存在，加密密码后创建用户
+	newUser, err := h.auth.Register(c.Request.Context(), req.Username, req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
+		respondUserError(c, err, "Failed to create user")
 		return
 	}
 
-	// 创建新用户
-	newUser := models.User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: hashedPassword,
-		Role:     models.RoleUser,
-		IsActive: true,
-	}
-
-	if err := database.DB.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
+	// 发送邮箱验证邮件，发送失败时用户可以重新请求
+	sent := true
+	if err := sendVerificationEmail(newUser); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to send verification email", "user_id", newUser.ID, "error", err)
+		sent = false
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "User registered successfully",
-		"user":    newUser.ToResponse(),
+		"message":                     "User registered successfully",
+		"user":                        newUser.ToResponse(),
+		"verification_email_sent":     sent,
+		"email_verification_required": config.Current().EmailVerificationRequired,
 	})
 }
 
 // 用户登出
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// 从请求头获取令牌
-	authHeader := c.GetHeader("Authorization")
-	if authHeader != "" {
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) == 2 && parts[0] == "Bearer" {
-			token := parts[1]
-			// 将令牌加入黑名单（存储在Redis中）
-			cacheKey := "blacklist:" + token
-			err := database.SetCache(cacheKey, "revoked", time.Duration(24)*time.Hour)
-			if err != nil {
-				return
-			}
-		}
-	}
+	// 将令牌加入黑名单直到令牌本身过期，并吊销当前会话及其刷新令牌
+	h.auth.Logout(c.Request.Context(), middleware.GetCurrentClaims(c), middleware.GetCurrentToken(c))
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
@@ -135,8 +158,20 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 // 获取当前用户信息
 func (h *AuthHandler) GetProfile(c *gin.Context) {
 	user := middleware.GetCurrentUser(c)
+	if err := h.users.LoadRoles(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	permissions, err := h.users.Permissions(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"user": user.ToResponse(),
+		"user":        user.ToResponse(),
+		"permissions": permissions,
 	})
 }
 
@@ -149,77 +184,76 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	}
 
 	user := middleware.GetCurrentUser(c)
-	updates := make(map[string]interface{})
 
-	if req.Email != "" {
-		// 检查邮箱是否已被其他用户使用
-		var existingUser models.User
-		if err := database.DB.Where("email = ? AND id != ?", req.Email, user.ID).First(&existingUser).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-			return
-		}
-		updates["email"] = req.Email
-	}
-
-	if req.Password != "" {
-		hashedPassword, err := utils.HashPassword(req.Password)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
-			return
-		}
-		updates["password"] = hashedPassword
+	// 修改主角色需要users:assign_roles权限
+	if req.Role != "" && req.Role != user.Role && !checkPrimaryRoleChange(h.users, c, req.Role) {
+		return
 	}
 
-	if req.Role != "" {
-		// 只有管理员可以更改角色
-		currentRole := middleware.GetCurrentUserRole(c)
-		if currentRole != models.RoleAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can change roles"})
-			return
-		}
-		updates["role"] = req.Role
+	result, err := h.users.Update(c.Request.Context(), user, services.UpdateUserInput{Email: req.Email, Password: req.Password, Role: req.Role})
+	if err != nil {
+		respondUserError(c, err, "Failed to update user")
+		return
 	}
 
-	if len(updates) > 0 {
-		if err := database.DB.Model(&user).Updates(updates).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-			return
+	if result.EmailChanged {
+		if err := sendVerificationEmail(user); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to send verification email", "user_id", user.ID, "error", err)
 		}
 	}
 
-	// 清除用户缓存
-	cacheKey := "user:" + strconv.Itoa(int(user.ID))
-	err := database.DeleteCache(cacheKey)
-	if err != nil {
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Profile updated successfully",
 		"user":    user.ToResponse(),
 	})
 }
 
-// 刷新令牌
+// 刷新令牌（使用刷新令牌换取新的令牌对，刷新令牌每次使用后轮换）
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	user := middleware.GetCurrentUser(c)
-
-	// 生成新的令牌
-	newToken, err := utils.GenerateToken(user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
 		return
 	}
 
-	// 将新令牌存储到Redis
-	cacheKey := "token:" + newToken
-	err = database.SetCache(cacheKey, user.ID, time.Duration(24)*time.Hour)
+	_, _, tokens, err := h.auth.RefreshTokens(c.Request.Context(), req.RefreshToken)
 	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			slog.WarnContext(c.Request.Context(), "Refresh token reuse detected, token family revoked")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has been revoked"})
+		case errors.Is(err, services.ErrRefreshTokenExpired):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has expired"})
+		case errors.Is(err, services.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		case errors.Is(err, services.ErrUserDeactivated):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate new token"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Token refreshed successfully",
-		"token":   newToken,
+		"message":       "Token refreshed successfully",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 	})
 }
+
+// 用户服务错误对应的响应
+func respondUserError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+	case errors.Is(err, services.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+	case errors.Is(err, services.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role does not exist"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"gin-auth-project/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 就绪检查中每个依赖的超时时间
+const readinessTimeout = 2 * time.Second
+
+// 依赖检查的状态
+const (
+	checkOK       = "ok"
+	checkFailed   = "unavailable"
+	checkDisabled = "disabled" // 未配置该依赖，不影响就绪
+)
+
+type HealthHandler struct{}
+
+// 单个依赖的检查结果；错误详情只记录在日志中，避免向未认证的调用方暴露内部地址
+type dependencyCheck struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Version   int64  `json:"version,omitempty"`
+	Pending   int    `json:"pending,omitempty"`
+}
+
+// 存活检查：只要进程能处理请求就返回200，不检查依赖，避免依赖故障时被反复重启
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": checkOK})
+}
+
+// 就绪检查：并行检查数据库、Redis和迁移版本，任一失败时返回503
+func (h *HealthHandler) Ready(c *gin.Context) {
+	checks := map[string]func(context.Context) dependencyCheck{
+		"database":   checkDatabase,
+		"redis":      checkRedis,
+		"migrations": checkMigrations,
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]dependencyCheck, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) dependencyCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			result := check(ctx)
+			result.LatencyMS = time.Since(start).Milliseconds()
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status, code := checkOK, http.StatusOK
+	for _, result := range results {
+		if result.Status == checkFailed {
+			status, code = checkFailed, http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+func checkDatabase(ctx context.Context) dependencyCheck {
+	if database.DB == nil {
+		return dependencyCheck{Status: checkFailed, Error: "not initialized"}
+	}
+	sqlDB, err := database.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	return checkResult(ctx, "database", err)
+}
+
+func checkRedis(ctx context.Context) dependencyCheck {
+	if database.RedisClient == nil {
+		return dependencyCheck{Status: checkDisabled}
+	}
+	return checkResult(ctx, "redis", database.RedisClient.Ping(ctx).Err())
+}
+
+func checkMigrations(ctx context.Context) dependencyCheck {
+	if database.DB == nil {
+		return dependencyCheck{Status: checkFailed, Error: "not initialized"}
+	}
+	pending, version, err := database.PendingMigrations(ctx, database.DB)
+	if err != nil {
+		return checkResult(ctx, "migrations", err)
+	}
+	if pending > 0 {
+		slog.WarnContext(ctx, "Readiness check failed", "dependency", "migrations", "pending", pending)
+		return dependencyCheck{Status: checkFailed, Error: "pending migrations", Version: version, Pending: pending}
+	}
+	return dependencyCheck{Status: checkOK, Version: version}
+}
+
+func checkResult(ctx context.Context, dependency string, err error) dependencyCheck {
+	if err == nil {
+		return dependencyCheck{Status: checkOK}
+	}
+	slog.WarnContext(ctx, "Readiness check failed", "dependency", dependency, "error", err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return dependencyCheck{Status: checkFailed, Error: "timeout"}
+	}
+	return dependencyCheck{Status: checkFailed, Error: "unreachable"}
+}
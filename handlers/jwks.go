@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 发布签名公钥（JWKS），包含签发密钥和待退役密钥
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	jwks := utils.JWKSet{Keys: []utils.JWK{}}
+	if keys := utils.CurrentSigningKeys(); keys != nil {
+		jwks = keys.JWKS()
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/events"
+	"gin-auth-project/mailer"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 渐进延迟：从第二次失败开始，每多失败一次延迟翻倍
+const (
+	loginDelayBase = 500 * time.Millisecond
+	loginDelayMax  = 8 * time.Second
+)
+
+// 账号被锁定时的通知钩子，默认给用户发送邮件，可以替换为其他通知方式（在后台调用）
+var OnAccountLocked = func(ctx context.Context, user *models.User, ip string, until time.Time) {
+	if err := sendAccountLockedEmail(user, ip, until); err != nil {
+		slog.ErrorContext(ctx, "Failed to send account locked email", "user_id", user.ID, "error", err)
+	}
+}
+
+// 登录防暴力破解：按账号和IP分别统计失败次数，达到阈值后临时锁定
+// 锁定期间的响应与密码错误完全相同，不存在的用户名同样会被计数和锁定，避免泄露用户名是否存在
+type loginGuard struct {
+	ctx        context.Context
+	user       *models.User
+	ip         string
+	accountKey string
+	ipKey      string
+}
+
+// 用户存在时按用户ID计数（用户名和邮箱共用），否则按提交的登录名计数
+func newLoginGuard(c *gin.Context, identifier string, user *models.User) *loginGuard {
+	accountKey := "name:" + strings.ToLower(identifier)
+	if user != nil {
+		accountKey = userLockKey(user.ID)
+	}
+	ip := c.ClientIP()
+	return &loginGuard{ctx: c.Request.Context(), user: user, ip: ip, accountKey: accountKey, ipKey: "ip:" + ip}
+}
+
+func userLockKey(userID uint) string {
+	return "user:" + strconv.Itoa(int(userID))
+}
+
+// 账号或IP是否处于锁定状态
+func (g *loginGuard) locked() bool {
+	if !database.LoginAttemptsEnabled() {
+		return false
+	}
+	for _, key := range []string{g.accountKey, g.ipKey} {
+		remaining, err := database.LoginLockRemaining(g.ctx, key)
+		if err != nil {
+			slog.ErrorContext(g.ctx, "Failed to check login lockout", "key", key, "error", err)
+			continue
+		}
+		if remaining > 0 {
+			events.Publish(events.Event{Type: events.LoginBlocked})
+			tracing.RecordLogin(g.ctx, tracing.LoginBlocked, 0)
+			return true
+		}
+	}
+	return false
+}
+
+// 按账号已有的失败次数延迟校验，客户端断开时提前返回
+func (g *loginGuard) delay(ctx context.Context) {
+	if !database.LoginAttemptsEnabled() {
+		return
+	}
+	failures, err := database.LoginFailures(g.ctx, g.accountKey)
+	if err != nil {
+		return
+	}
+
+	wait := loginDelay(failures)
+	if wait <= 0 {
+		return
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// 失败次数对应的延迟
+func loginDelay(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	wait := loginDelayBase
+	for i := 2; i < failures && wait < loginDelayMax; i++ {
+		wait *= 2
+	}
+	if wait > loginDelayMax {
+		wait = loginDelayMax
+	}
+	return wait
+}
+
+// 记录一次失败，账号或IP达到阈值时锁定
+func (g *loginGuard) fail() {
+	events.Publish(events.Event{Type: events.LoginFailed})
+	tracing.RecordLogin(g.ctx, tracing.LoginFailure, 0)
+	if !database.LoginAttemptsEnabled() {
+		return
+	}
+	cfg := config.Current()
+	window := time.Duration(cfg.LoginFailureWindowMinutes) * time.Minute
+	lockout := time.Duration(cfg.LoginLockoutMinutes) * time.Minute
+
+	locked, err := database.RecordLoginFailure(g.ctx, g.accountKey, cfg.LoginMaxFailures, window, lockout)
+	if err != nil {
+		slog.ErrorContext(g.ctx, "Failed to record login failure", "key", g.accountKey, "error", err)
+	} else if locked {
+		events.Publish(events.Event{Type: events.AccountLocked})
+		tracing.RecordLockout(g.ctx, "account")
+		if g.user != nil {
+			slog.WarnContext(g.ctx, "Account locked after repeated failed logins", "user_id", g.user.ID, "failures", cfg.LoginMaxFailures, "client_ip", g.ip)
+			go OnAccountLocked(context.WithoutCancel(g.ctx), g.user, g.ip, time.Now().Add(lockout))
+		}
+	}
+
+	locked, err = database.RecordLoginFailure(g.ctx, g.ipKey, cfg.LoginIPMaxFailures, window, lockout)
+	if err != nil {
+		slog.ErrorContext(g.ctx, "Failed to record login failure", "key", g.ipKey, "error", err)
+	} else if locked {
+		events.Publish(events.Event{Type: events.IPLocked})
+		tracing.RecordLockout(g.ctx, "ip")
+		slog.WarnContext(g.ctx, "IP locked after repeated failed logins", "client_ip", g.ip, "failures", cfg.LoginIPMaxFailures)
+	}
+}
+
+// 完成登录后清除账号的失败计数；IP计数保留，避免攻击者用自己的账号重置
+func (g *loginGuard) succeed() {
+	events.Publish(events.Event{Type: events.LoginSucceeded})
+	tracing.RecordLogin(g.ctx, tracing.LoginSuccess, g.user.ID)
+	if !database.LoginAttemptsEnabled() {
+		return
+	}
+	if err := database.ClearLoginFailures(g.ctx, g.accountKey); err != nil {
+		slog.ErrorContext(g.ctx, "Failed to clear login failures", "key", g.accountKey, "error", err)
+	}
+}
+
+// 账号锁定的解除时间，未锁定时返回nil
+func accountLockedUntil(ctx context.Context, userID uint) *time.Time {
+	if !database.LoginAttemptsEnabled() {
+		return nil
+	}
+	remaining, err := database.LoginLockRemaining(ctx, userLockKey(userID))
+	if err != nil || remaining <= 0 {
+		return nil
+	}
+	until := time.Now().Add(remaining)
+	return &until
+}
+
+// 解除账号锁定（仅管理员）
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	userID, ok := parseAccessibleUserIDParam(h.users, c, models.PermissionUsersDeactivate, true)
+	if !ok {
+		return
+	}
+
+	user, ok := h.findUser(c, userID)
+	if !ok {
+		return
+	}
+
+	if database.LoginAttemptsEnabled() {
+		if err := database.UnlockLogin(c.Request.Context(), userLockKey(user.ID)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+			return
+		}
+	}
+
+	slog.InfoContext(c.Request.Context(), "Account unlocked", "user_id", user.ID, "unlocked_by", middleware.GetCurrentUserID(c))
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// 通知用户账号已被临时锁定
+func sendAccountLockedEmail(user *models.User, ip string, until time.Time) error {
+	resetLink := strings.TrimRight(config.Current().AppBaseURL, "/") + "/forgot-password"
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Your account was temporarily locked after too many failed sign-in attempts (last attempt from %s).\n"+
+		"You can sign in again after %s.\n\n"+
+		"If this wasn't you, someone may be trying to guess your password. Consider resetting it:\n\n%s\n",
+		user.Username, ip, until.UTC().Format("2006-01-02 15:04 MST"), resetLink)
+
+	return mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been temporarily locked",
+		Body:    body,
+	})
+}
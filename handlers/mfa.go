@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每个待验证令牌允许的验证码尝试次数
+const maxMFAAttempts = 5
+
+// 开始设置TOTP：生成密钥，返回otpauth URI和二维码
+func (h *AuthHandler) SetupTOTP(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	// 密钥在确认前不生效
+	if err := h.users.SetTOTPSecret(c.Request.Context(), user, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	uri := utils.TOTPURI(config.Current().MFAIssuer, user.Username, secret)
+	png, err := utils.QRCodePNG(uri)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// 确认启用TOTP：校验第一个验证码并生成恢复码
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor setup has not been started"})
+		return
+	}
+
+	step, ok := utils.ValidateTOTPCode(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	codes, err := h.users.EnableTOTP(c.Request.Context(), user, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled successfully",
+		"recovery_codes": codes,
+	})
+}
+
+// 关闭TOTP，需要同时提供密码和验证码（或恢复码）
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	var req models.TOTPDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if mfaRequired(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for admin accounts"})
+		return
+	}
+
+	if !utils.CheckPassword(req.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	if _, ok := h.users.VerifySecondFactor(c.Request.Context(), user, req.Code, req.Code); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	if err := h.users.DisableTOTP(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled successfully"})
+}
+
+// 重新生成恢复码，旧的恢复码全部作废
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if _, ok := h.users.VerifySecondFactor(c.Request.Context(), user, req.Code, ""); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	codes, err := h.users.RegenerateRecoveryCodes(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Recovery codes regenerated successfully",
+		"recovery_codes": codes,
+	})
+}
+
+// 登录第二步：提交TOTP验证码或恢复码，换取正式的令牌
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification code or recovery code is required"})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	claims := middleware.GetCurrentClaims(c)
+
+	// 限制每个待验证令牌的尝试次数，防止暴力破解验证码
+	// 缓存不可用时使用进程内计数，与令牌吊销一样不因缓存故障放开限制
+	attempts := database.IncrCounter("mfa_attempts:"+claims.ID, time.Until(claims.ExpiresAt.Time))
+	if attempts > maxMFAAttempts {
+		database.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Too many failed attempts, please log in again"})
+		return
+	}
+
+	// 验证码错误同样计入账号的登录失败次数，锁定期间不再校验
+	guard := newLoginGuard(c, "", user)
+	if guard.locked() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	method, ok := h.users.VerifySecondFactor(c.Request.Context(), user, req.Code, req.RecoveryCode)
+	if !ok {
+		guard.fail()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	// 待验证令牌只能使用一次
+	if err := database.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		slog.WarnContext(c.Request.Context(), "MFA token revoked locally only", "token_id", claims.ID, "error", err)
+	}
+
+	h.completeLogin(c, user, req.Device, []string{utils.AuthMethodPassword, method, utils.AuthMethodMFA})
+}
+
+// 用户是否必须使用两步验证：开启REQUIRE_ADMIN_MFA时拥有管理类权限的用户，或拥有要求两步验证的角色的用户
+func mfaRequired(c *gin.Context, user *models.User) bool {
+	if config.Current().RequireAdminMFA && hasPrivilegedPermission(c, user) {
+		return true
+	}
+	required, err := database.UserRequiresMFA(user)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to check role MFA policy", "user_id", user.ID, "error", err)
+	}
+	return required
+}
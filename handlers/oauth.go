@@ -0,0 +1,631 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthHandler struct {
+	auth  *services.AuthService
+	users *services.UserService
+	oauth *services.OAuthService
+}
+
+func NewOAuthHandler(auth *services.AuthService, users *services.UserService, oauth *services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{auth: auth, users: users, oauth: oauth}
+}
+
+const (
+	// 授权码有效期
+	authorizationCodeTTL = 5 * time.Minute
+	// 授权请求等待用户登录和同意的时间
+	authorizeRequestTTL = 10 * time.Minute
+
+	// 授权页的单点登录Cookie
+	ssoCookieName = "oauth_sso"
+	// 授权页表单的CSRF Cookie
+	csrfCookieName = "oauth_csrf"
+)
+
+//go:embed templates/authorize.html
+var authorizeTemplateSource string
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(authorizeTemplateSource))
+
+// 授权页上展示的scope说明
+var scopeDescriptions = map[string]string{
+	utils.ScopeOpenID:  "Sign you in with your account",
+	utils.ScopeProfile: "Read your username and role",
+	utils.ScopeEmail:   "Read your email address",
+}
+
+// 校验通过的授权请求，保存在缓存中等待用户登录和同意
+type authorizeRequest struct {
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	State         string `json:"state"`
+	Nonce         string `json:"nonce"`
+	CodeChallenge string `json:"code_challenge"`
+	LoginRequired bool   `json:"login_required"`
+	CSRF          string `json:"csrf"`
+}
+
+func (r *authorizeRequest) scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// 授权页模板数据
+type authorizePage struct {
+	Title      string
+	Fatal      bool
+	Error      string
+	ClientName string
+	Scopes     []string
+	RequestID  string
+	CSRF       string
+	Username   string
+}
+
+// OpenID Connect 发现文档
+func (h *OAuthHandler) Discovery(c *gin.Context) {
+	issuer := config.Current().OIDCIssuer
+	base := strings.TrimRight(issuer, "/")
+
+	alg := "HS256"
+	if keys := utils.CurrentSigningKeys(); keys != nil {
+		alg = keys.Active().Method.Alg()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                base + "/oauth/authorize",
+		"token_endpoint":                        base + "/oauth/token",
+		"userinfo_endpoint":                     base + "/oauth/userinfo",
+		"jwks_uri":                              base + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{alg},
+		"scopes_supported":                      utils.SupportedScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "sid",
+			"preferred_username", "role", "email", "email_verified",
+		},
+	})
+}
+
+// 授权端点：校验授权请求，已登录且已同意时直接返回授权码，否则展示登录和授权页
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	query := c.Request.URL.Query()
+
+	// 客户端和回调地址无效时不能重定向，直接展示错误页
+	client, err := h.oauth.FindClient(c.Request.Context(), query.Get("client_id"))
+	if err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "Unknown client")
+		return
+	}
+
+	redirectURI := query.Get("redirect_uri")
+	if !client.AllowsRedirectURI(redirectURI) {
+		renderAuthorizeError(c, http.StatusBadRequest, "Invalid redirect_uri")
+		return
+	}
+
+	state := query.Get("state")
+	fail := func(code, description string) {
+		redirectAuthorizeError(c, redirectURI, state, code, description)
+	}
+
+	if query.Get("response_type") != "code" {
+		fail("unsupported_response_type", "Only the authorization code flow is supported")
+		return
+	}
+
+	scopes := strings.Fields(query.Get("scope"))
+	if !utils.ContainsScope(scopes, utils.ScopeOpenID) {
+		fail("invalid_scope", "The openid scope is required")
+		return
+	}
+	if !client.AllowsScopes(scopes) {
+		fail("invalid_scope", "The client is not allowed to request these scopes")
+		return
+	}
+
+	// 所有客户端都必须使用PKCE
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		fail("invalid_request", "PKCE with code_challenge_method S256 is required")
+		return
+	}
+
+	req := authorizeRequest{
+		ClientID:      client.ClientID,
+		RedirectURI:   redirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         state,
+		Nonce:         query.Get("nonce"),
+		CodeChallenge: query.Get("code_challenge"),
+	}
+
+	prompt := query.Get("prompt")
+	session, user := h.currentSSOSession(c)
+	if prompt == "login" {
+		session, user = nil, nil
+	}
+
+	// 已登录且之前同意过这些scope时无需再次询问
+	if session != nil && prompt != "consent" && h.oauth.HasConsent(c.Request.Context(), user.ID, client.ClientID, scopes) {
+		h.issueAuthorizationCode(c, &req, user, session)
+		return
+	}
+
+	if prompt == "none" {
+		if session == nil {
+			fail("login_required", "The user is not signed in")
+		} else {
+			fail("consent_required", "The user has not granted access to this client")
+		}
+		return
+	}
+
+	req.LoginRequired = session == nil
+	requestID, err := saveAuthorizeRequest(c, &req)
+	if err != nil {
+		renderAuthorizeError(c, http.StatusInternalServerError, "Failed to start authorization")
+		return
+	}
+
+	page := authorizePage{RequestID: requestID, CSRF: req.CSRF}
+	if !req.LoginRequired {
+		page.Username = user.Username
+	}
+	renderAuthorizePage(c, http.StatusOK, client, &req, page)
+}
+
+// 授权页表单提交：登录（如需要）并同意或拒绝授权
+func (h *OAuthHandler) AuthorizeSubmit(c *gin.Context) {
+	requestID := c.PostForm("request_id")
+	req, err := loadAuthorizeRequest(requestID)
+	if err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "The authorization request has expired, please start again")
+		return
+	}
+
+	// 表单中的CSRF令牌必须与Cookie和授权请求一致
+	cookie, _ := c.Cookie(csrfCookieName)
+	if subtle.ConstantTimeCompare([]byte(c.PostForm("csrf")), []byte(req.CSRF)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(cookie), []byte(req.CSRF)) != 1 {
+		renderAuthorizeError(c, http.StatusForbidden, "Invalid authorization request")
+		return
+	}
+
+	client, err := h.oauth.FindClient(c.Request.Context(), req.ClientID)
+	if err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "Unknown client")
+		return
+	}
+
+	if c.PostForm("action") != "approve" {
+		database.DeleteCache("oauth_request:" + requestID)
+		redirectAuthorizeError(c, req.RedirectURI, req.State, "access_denied", "The user denied the request")
+		return
+	}
+
+	session, user := h.currentSSOSession(c)
+	if req.LoginRequired || session == nil {
+		var authMethods []string
+		var message string
+		user, authMethods, message = h.authenticateAuthorizeForm(c)
+		if message != "" {
+			page := authorizePage{RequestID: requestID, CSRF: req.CSRF, Error: message}
+			renderAuthorizePage(c, http.StatusUnauthorized, client, req, page)
+			return
+		}
+
+		session, err = createSession(h.auth, c, user, "", authMethods)
+		if err != nil {
+			renderAuthorizeError(c, http.StatusInternalServerError, "Failed to create session")
+			return
+		}
+		if err := setSSOCookie(c, session); err != nil {
+			slog.WarnContext(c.Request.Context(), "Failed to save SSO session", "session_id", session.ID, "error", err)
+		}
+	}
+
+	if err := h.oauth.SaveConsent(c.Request.Context(), user.ID, client.ClientID, req.scopes()); err != nil {
+		renderAuthorizeError(c, http.StatusInternalServerError, "Failed to save consent")
+		return
+	}
+
+	database.DeleteCache("oauth_request:" + requestID)
+	h.issueAuthorizationCode(c, req, user, session)
+}
+
+// 令牌端点
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		h.exchangeAuthorizationCode(c, client)
+	case "refresh_token":
+		h.refreshOAuthToken(c, client)
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant_type")
+	}
+}
+
+// 使用授权码换取令牌，为客户端创建独立的会话
+func (h *OAuthHandler) exchangeAuthorizationCode(c *gin.Context, client *models.OAuthClient) {
+	ctx := c.Request.Context()
+	code, err := h.oauth.FindAuthorizationCode(ctx, client.ClientID, c.PostForm("code"))
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+
+	// 授权码被重复使用时吊销已经用它签发的令牌（RFC 6749 4.1.2）
+	if code.UsedAt != nil {
+		if code.GrantSessionID != "" {
+			h.auth.RevokeSession(ctx, code.GrantSessionID)
+		}
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Authorization code has already been used")
+		return
+	}
+
+	if time.Now().After(code.ExpiresAt) || c.PostForm("redirect_uri") != code.RedirectURI {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+
+	if !utils.VerifyPKCE(c.PostForm("code_verifier"), code.CodeChallenge) {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid code_verifier")
+		return
+	}
+
+	// 条件更新保证授权码只能兑换一次
+	if err := h.oauth.UseAuthorizationCode(ctx, code); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Authorization code has already been used")
+		return
+	}
+
+	user, err := h.users.Get(ctx, code.UserID)
+	if err != nil || !user.IsActive {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "User account is not available")
+		return
+	}
+
+	loginSession, err := h.auth.FindSession(ctx, code.SessionID)
+	if err != nil || !loginSession.IsActive() {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Login session has ended")
+		return
+	}
+
+	session, err := createSessionWith(h.auth, c, user, client.Name, loginSession.AuthMethodList(), func(session *models.Session) {
+		session.ClientID = client.ClientID
+		session.Scope = code.Scope
+	})
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to create session")
+		return
+	}
+	if err := h.oauth.SetCodeGrantSession(ctx, code, session.ID); err != nil {
+		slog.WarnContext(ctx, "Failed to record authorization code session", "session_id", session.ID, "error", err)
+	}
+
+	tokens, err := h.auth.IssueTokens(ctx, user, session)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate token")
+		return
+	}
+
+	idToken, err := utils.GenerateIDToken(user, session, loginSession.CreatedAt, code.Nonce)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  tokens.AccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    tokens.ExpiresIn,
+		"refresh_token": tokens.RefreshToken,
+		"id_token":      idToken,
+		"scope":         session.Scope,
+	})
+}
+
+// 使用刷新令牌换取新的令牌对，刷新令牌必须属于该客户端
+func (h *OAuthHandler) refreshOAuthToken(c *gin.Context, client *models.OAuthClient) {
+	raw := c.PostForm("refresh_token")
+
+	session, err := h.auth.RefreshTokenSession(c.Request.Context(), raw)
+	if err != nil || session.ClientID != client.ClientID {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	_, _, tokens, err := h.auth.RefreshTokens(c.Request.Context(), raw)
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  tokens.AccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    tokens.ExpiresIn,
+		"refresh_token": tokens.RefreshToken,
+		"scope":         session.Scope,
+	})
+}
+
+// 用户信息端点，按访问令牌授权的scope返回
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	scopes := strings.Fields(middleware.GetCurrentClaims(c).Scope)
+	if !utils.ContainsScope(scopes, utils.ScopeOpenID) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		oauthError(c, http.StatusForbidden, "insufficient_scope", "The access token was not issued for OpenID Connect")
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.UserInfo(middleware.GetCurrentUser(c), scopes))
+}
+
+// 客户端认证：client_secret_basic、client_secret_post，公开客户端不需要密钥
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+
+	client, err := h.oauth.AuthenticateClient(c.Request.Context(), clientID, secret)
+	if err != nil {
+		return nil, false
+	}
+	return client, true
+}
+
+// 校验授权页提交的用户名、密码和两步验证码，失败时返回展示给用户的提示
+func (h *OAuthHandler) authenticateAuthorizeForm(c *gin.Context) (*models.User, []string, string) {
+	username := c.PostForm("username")
+	password := c.PostForm("password")
+	if username == "" || password == "" {
+		return nil, nil, "Please enter your username and password"
+	}
+
+	// 与登录接口共用失败计数和锁定
+	user, err := h.users.FindByLogin(c.Request.Context(), username)
+	if err != nil && !errors.Is(err, services.ErrUserNotFound) {
+		return nil, nil, "Failed to sign in, please try again"
+	}
+	guard := newLoginGuard(c, username, user)
+	if guard.locked() {
+		return nil, nil, "Invalid username or password"
+	}
+	guard.delay(c.Request.Context())
+
+	if err := h.auth.Authenticate(user, password); err != nil {
+		if errors.Is(err, services.ErrUserDeactivated) {
+			return nil, nil, "Your account has been deactivated"
+		}
+		guard.fail()
+		return nil, nil, "Invalid username or password"
+	}
+	if config.Current().EmailVerificationRequired && !user.EmailVerified {
+		return nil, nil, "Please verify your email address before signing in"
+	}
+
+	if !user.TOTPEnabled {
+		guard.succeed()
+		return user, []string{utils.AuthMethodPassword}, ""
+	}
+
+	code := c.PostForm("code")
+	if code == "" {
+		return nil, nil, "Please enter the verification code from your authenticator app"
+	}
+	method, ok := h.users.VerifySecondFactor(c.Request.Context(), user, code, code)
+	if !ok {
+		guard.fail()
+		return nil, nil, "Invalid verification code"
+	}
+	guard.succeed()
+	return user, []string{utils.AuthMethodPassword, method, utils.AuthMethodMFA}, ""
+}
+
+// 生成授权码并重定向回客户端
+func (h *OAuthHandler) issueAuthorizationCode(c *gin.Context, req *authorizeRequest, user *models.User, session *models.Session) {
+	raw, err := h.oauth.CreateAuthorizationCode(c.Request.Context(), &models.OAuthAuthorizationCode{
+		ClientID:            req.ClientID,
+		UserID:              user.ID,
+		SessionID:           session.ID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: "S256",
+		ExpiresAt:           time.Now().Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		redirectAuthorizeError(c, req.RedirectURI, req.State, "server_error", "Failed to generate authorization code")
+		return
+	}
+
+	params := url.Values{"code": {raw}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	redirectWithParams(c, req.RedirectURI, params)
+}
+
+// 保存授权请求并设置CSRF Cookie，返回请求ID
+func saveAuthorizeRequest(c *gin.Context, req *authorizeRequest) (string, error) {
+	requestID, err := utils.GenerateID()
+	if err != nil {
+		return "", err
+	}
+	if req.CSRF, err = utils.GenerateID(); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	if err := database.SetCache("oauth_request:"+requestID, data, authorizeRequestTTL); err != nil {
+		return "", err
+	}
+
+	setOAuthCookie(c, csrfCookieName, req.CSRF, authorizeRequestTTL)
+	return requestID, nil
+}
+
+func loadAuthorizeRequest(requestID string) (*authorizeRequest, error) {
+	data, err := database.GetCache("oauth_request:" + requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var req authorizeRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// 为授权页登录创建的会话设置单点登录Cookie，Cookie中只保存随机令牌
+func setSSOCookie(c *gin.Context, session *models.Session) error {
+	raw, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(session.ExpiresAt)
+	if err := database.SetCache("oauth_sso:"+utils.HashToken(raw), session.ID, ttl); err != nil {
+		return err
+	}
+
+	setOAuthCookie(c, ssoCookieName, raw, ttl)
+	return nil
+}
+
+// 根据单点登录Cookie查找有效的会话和用户
+func (h *OAuthHandler) currentSSOSession(c *gin.Context) (*models.Session, *models.User) {
+	raw, err := c.Cookie(ssoCookieName)
+	if err != nil || raw == "" {
+		return nil, nil
+	}
+
+	sessionID, err := database.GetCache("oauth_sso:" + utils.HashToken(raw))
+	if err != nil {
+		return nil, nil
+	}
+
+	session, err := h.auth.FindSession(c.Request.Context(), sessionID)
+	if err != nil || !session.IsActive() {
+		return nil, nil
+	}
+
+	user, err := h.users.Get(c.Request.Context(), session.UserID)
+	if err != nil || !user.IsActive {
+		return nil, nil
+	}
+
+	return session, user
+}
+
+func setOAuthCookie(c *gin.Context, name, value string, ttl time.Duration) {
+	secure := strings.HasPrefix(config.Current().OIDCIssuer, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, int(ttl.Seconds()), "/oauth", "", secure, true)
+}
+
+// 重定向回客户端并附带错误信息
+func redirectAuthorizeError(c *gin.Context, redirectURI, state, code, description string) {
+	params := url.Values{"error": {code}, "error_description": {description}}
+	if state != "" {
+		params.Set("state", state)
+	}
+	redirectWithParams(c, redirectURI, params)
+}
+
+func redirectWithParams(c *gin.Context, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "Invalid redirect_uri")
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	c.Redirect(http.StatusFound, target.String())
+}
+
+// OAuth 2.0 格式的错误响应（RFC 6749 5.2）
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+func renderAuthorizePage(c *gin.Context, status int, client *models.OAuthClient, req *authorizeRequest, page authorizePage) {
+	page.Title = "Sign in to " + client.Name
+	page.ClientName = client.Name
+	for _, scope := range req.scopes() {
+		if description, ok := scopeDescriptions[scope]; ok {
+			page.Scopes = append(page.Scopes, description)
+		}
+	}
+	renderHTML(c, status, page)
+}
+
+func renderAuthorizeError(c *gin.Context, status int, message string) {
+	renderHTML(c, status, authorizePage{Title: "Authorization error", Fatal: true, Error: message})
+}
+
+func renderHTML(c *gin.Context, status int, page authorizePage) {
+	// 禁止缓存和被嵌入框架，防止点击劫持
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+
+	if err := authorizeTemplate.ExecuteTemplate(c.Writer, "layout", page); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to render authorization page", "error", err)
+	}
+}
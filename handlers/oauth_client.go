@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 注册OAuth客户端（仅管理员），客户端密钥只在创建时返回一次
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	var req models.OAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !utils.ContainsScope(utils.SupportedScopes, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported scope: " + scope})
+			return
+		}
+	}
+
+	client, secret, err := h.oauth.CreateClient(c.Request.Context(), services.CreateClientInput{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+
+	response := gin.H{
+		"message": "Client created successfully",
+		"client":  client.ToResponse(),
+	}
+	if secret != "" {
+		response["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// 获取所有OAuth客户端（仅管理员）
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	clients, err := h.oauth.Clients(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clients"})
+		return
+	}
+
+	responses := make([]models.OAuthClientResponse, 0, len(clients))
+	for _, client := range clients {
+		responses = append(responses, client.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": responses})
+}
+
+// 删除OAuth客户端（仅管理员），同时吊销该客户端的所有会话和授权记录
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+
+	if err := h.oauth.DeleteClient(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, services.ErrClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted successfully"})
+}
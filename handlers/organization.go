@@ -0,0 +1,516 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/mailer"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationHandler struct {
+	orgs  *services.OrganizationService
+	roles *services.RoleService
+	users *services.UserService
+	auth  *services.AuthService
+}
+
+func NewOrganizationHandler(orgs *services.OrganizationService, roles *services.RoleService, users *services.UserService, auth *services.AuthService) *OrganizationHandler {
+	return &OrganizationHandler{orgs: orgs, roles: roles, users: users, auth: auth}
+}
+
+// 组织标识只能包含小写字母、数字和连字符
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// 获取当前用户所属的组织
+func (h *OrganizationHandler) ListMyOrganizations(c *gin.Context) {
+	memberships, err := h.orgs.UserMemberships(c.Request.Context(), middleware.GetCurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+
+	orgIDs := make([]uint, 0, len(memberships))
+	for _, membership := range memberships {
+		orgIDs = append(orgIDs, membership.OrganizationID)
+	}
+
+	orgs, err := h.orgs.GetMany(c.Request.Context(), orgIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+	byID := make(map[uint]models.Organization, len(orgs))
+	for _, org := range orgs {
+		byID[org.ID] = org
+	}
+
+	currentOrgID := middleware.GetCurrentOrgID(c)
+	result := make([]gin.H, 0, len(memberships))
+	for _, membership := range memberships {
+		result = append(result, gin.H{
+			"organization": byID[membership.OrganizationID],
+			"role":         membership.Role,
+			"current":      membership.OrganizationID == currentOrgID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": result, "current_org_id": currentOrgID})
+}
+
+// 切换当前组织：更新会话并签发带有新org_id声明的访问令牌，旧的访问令牌立即作废
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	var req models.SwitchOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	claims := middleware.GetCurrentClaims(c)
+
+	ctx := c.Request.Context()
+	if req.OrgID != 0 {
+		if _, err := h.orgs.FindMembership(ctx, req.OrgID, user.ID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this organization"})
+			return
+		}
+	}
+
+	session, err := h.auth.FindUserSession(ctx, user.ID, claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Switching organizations requires a login session"})
+		return
+	}
+
+	if err := h.auth.SetSessionOrg(ctx, session, req.OrgID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch organization"})
+		return
+	}
+
+	token, err := utils.GenerateToken(user, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	if claims.ExpiresAt != nil {
+		tokenID := utils.TokenRevocationID(claims, middleware.GetCurrentToken(c))
+		if err := database.RevokeToken(tokenID, claims.ExpiresAt.Time); err != nil {
+			slog.WarnContext(c.Request.Context(), "Token revoked locally only", "token_id", claims.ID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Organization switched successfully",
+		"token":      token,
+		"expires_in": int64(config.Current().JWTExpireHours) * 3600,
+		"org_id":     req.OrgID,
+	})
+}
+
+// 接受组织邀请，邀请只能由已验证的被邀请邮箱接受
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified", "email_verification_required": true})
+		return
+	}
+
+	membership, err := h.orgs.AcceptInvitation(c.Request.Context(), user, req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidInvitation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Invitation accepted successfully",
+		"membership": membership,
+	})
+}
+
+// 获取所有组织
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.orgs.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+// 创建组织，成员通过邀请加入
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if !orgSlugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug may only contain lowercase letters, digits and hyphens"})
+		return
+	}
+
+	org, err := h.orgs.Create(c.Request.Context(), req.Name, req.Slug)
+	if err != nil {
+		if errors.Is(err, services.ErrSlugTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Organization slug already exists"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Organization created successfully",
+		"organization": org,
+	})
+}
+
+// 删除组织及其成员和邀请，选择该组织的会话回到未选择组织的状态
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+
+	if err := h.orgs.Delete(c.Request.Context(), org); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// 获取组织成员
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+
+	memberships, err := h.orgs.Members(c.Request.Context(), org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	members := make([]models.MembershipResponse, 0, len(memberships))
+	for i := range memberships {
+		members = append(members, memberships[i].ToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"organization": org, "members": members})
+}
+
+// 修改成员在组织内的角色
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+	membership, ok := h.findMembership(c, org.ID)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMembershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if !h.checkMembershipRole(c, req.Role) || !h.checkMemberChange(c, membership, &req.Role) {
+		return
+	}
+
+	if err := h.orgs.UpdateMemberRole(c.Request.Context(), membership, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Member updated successfully",
+		"membership": membership,
+	})
+}
+
+// 移除组织成员，该成员选择此组织的会话回到未选择组织的状态
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+	membership, ok := h.findMembership(c, org.ID)
+	if !ok || !h.checkMemberChange(c, membership, nil) {
+		return
+	}
+
+	if err := h.orgs.RemoveMember(c.Request.Context(), membership); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// 获取组织的待接受邀请
+func (h *OrganizationHandler) ListInvitations(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.orgs.PendingInvitations(c.Request.Context(), org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+// 邀请成员加入组织，邀请链接通过邮件发送
+func (h *OrganizationHandler) CreateInvitation(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+
+	if !h.checkMembershipRole(c, req.Role) {
+		return
+	}
+
+	invitation, raw, err := h.orgs.CreateInvitation(c.Request.Context(), org, req.Email, req.Role, middleware.GetCurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	if err := sendInvitationEmail(org, invitation, raw, middleware.GetCurrentUser(c)); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to send invitation email", "organization_id", org.ID, "error", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invitation sent successfully",
+		"invitation": invitation,
+	})
+}
+
+// 撤销邀请
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+
+	invitationID, err := strconv.ParseUint(c.Param("iid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	if err := h.orgs.RevokeInvitation(c.Request.Context(), org.ID, uint(invitationID)); err != nil {
+		if errors.Is(err, services.ErrInvitationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// 发送邀请邮件
+func sendInvitationEmail(org *models.Organization, invitation *models.OrganizationInvitation, raw string, inviter *models.User) error {
+	link := strings.TrimRight(config.Current().AppBaseURL, "/") + "/accept-invitation?token=" + url.QueryEscape(raw)
+	body := fmt.Sprintf("Hi,\n\n"+
+		"%s has invited you to join the organization %s as %s. Log in with this email address and open the link below to accept:\n\n%s\n\n"+
+		"The invitation expires in %d days.\n"+
+		"If you were not expecting this invitation, you can ignore this email.\n",
+		inviter.Username, org.Name, invitation.Role, link, int(services.InvitationTTL.Hours()/24))
+
+	return mailer.Send(&mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to join " + org.Name,
+		Body:    body,
+	})
+}
+
+// 按路径中的ID查找组织
+// 只通过组织成员角色获得权限时，只能管理当前选择的组织
+func (h *OrganizationHandler) findOrganization(c *gin.Context) (*models.Organization, bool) {
+	orgID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return nil, false
+	}
+
+	if scope := middleware.PermissionOrgScope(c, models.PermissionOrgMembersManage); scope != 0 && scope != uint(orgID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, false
+	}
+
+	org, err := h.orgs.Get(c.Request.Context(), uint(orgID))
+	if err != nil {
+		if errors.Is(err, services.ErrOrganizationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organization"})
+		}
+		return nil, false
+	}
+	return org, true
+}
+
+// 按路径中的用户ID查找组织成员
+func (h *OrganizationHandler) findMembership(c *gin.Context, orgID uint) (*models.Membership, bool) {
+	userID, err := strconv.ParseUint(c.Param("uid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	membership, err := h.orgs.FindMembership(c.Request.Context(), orgID, uint(userID))
+	if err != nil {
+		if errors.Is(err, services.ErrMemberNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch member"})
+		}
+		return nil, false
+	}
+	return membership, true
+}
+
+// 检查能否修改或移除成员（newRole为nil表示移除），不满足时写入响应
+// 不能修改自己的成员身份；只通过组织成员角色获得权限时不能修改拥有全局管理类权限的用户；
+// 组织中必须保留至少一个可以管理成员的成员
+func (h *OrganizationHandler) checkMemberChange(c *gin.Context, membership *models.Membership, newRole *models.Role) bool {
+	if membership.UserID == middleware.GetCurrentUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change or remove your own membership"})
+		return false
+	}
+
+	if middleware.PermissionOrgScope(c, models.PermissionOrgMembersManage) != 0 {
+		user, err := h.users.Get(c.Request.Context(), membership.UserID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return false
+		}
+		if hasPrivilegedPermission(c, user) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This user can only be modified by a platform administrator"})
+			return false
+		}
+	}
+
+	if err := h.orgs.CheckKeepsMemberManager(c.Request.Context(), membership, newRole); err != nil {
+		if errors.Is(err, services.ErrLastOrgAdmin) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The organization must keep at least one member who can manage members"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization members"})
+		}
+		return false
+	}
+	return true
+}
+
+// 校验成员角色：角色必须存在，且其组织内有效的权限不能超出当前用户自己的权限
+func (h *OrganizationHandler) checkMembershipRole(c *gin.Context, name models.Role) bool {
+	role, err := h.roles.FindByName(c.Request.Context(), name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + string(name)})
+		return false
+	}
+
+	for _, permission := range role.Permissions {
+		if models.IsOrgScopedPermission(permission.Name) && !middleware.HasPermission(c, permission.Name) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot assign a role with permissions you do not have: " + string(name)})
+			return false
+		}
+	}
+	return true
+}
+
+// 检查能否操作指定用户，不满足时写入响应
+// 只通过组织成员角色获得权限时，目标用户必须是当前组织的成员；修改操作还要求目标用户
+// 不属于其他组织且没有全局管理类权限，避免组织管理员影响组织之外的账号
+func checkUserAccess(users *services.UserService, c *gin.Context, permission string, userID uint, modify bool) bool {
+	orgID := middleware.PermissionOrgScope(c, permission)
+	if orgID == 0 {
+		return true
+	}
+
+	orgIDs, err := users.OrgIDs(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization membership"})
+		return false
+	}
+
+	member := false
+	for _, id := range orgIDs {
+		if id == orgID {
+			member = true
+		}
+	}
+	if !member {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
+	}
+
+	if !modify || userID == middleware.GetCurrentUserID(c) {
+		return true
+	}
+
+	user, err := users.Get(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
+	}
+	if len(orgIDs) > 1 || hasPrivilegedPermission(c, user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user can only be modified by a platform administrator"})
+		return false
+	}
+	return true
+}
+
+// 解析路径中的用户ID并检查能否操作该用户
+func parseAccessibleUserIDParam(users *services.UserService, c *gin.Context, permission string, modify bool) (uint, bool) {
+	userID, ok := parseUserIDParam(c)
+	if !ok || !checkUserAccess(users, c, permission, userID, modify) {
+		return 0, false
+	}
+	return userID, true
+}
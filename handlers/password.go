@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/mailer"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 同一邮箱两次申请重置密码的最短间隔
+const passwordResetInterval = time.Minute
+
+// 后台同时发送邮件的上限，避免大量请求堆积goroutine和邮件服务器连接
+var mailSenders = make(chan struct{}, 16)
+
+// 在后台查找用户并发送邮件，避免通过响应时间判断邮箱是否存在；同时发送的邮件数量有上限，超出时丢弃
+func sendMailInBackground(c *gin.Context, send func(ctx context.Context)) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	select {
+	case mailSenders <- struct{}{}:
+		go func() {
+			defer func() { <-mailSenders }()
+			send(ctx)
+		}()
+	default:
+		slog.WarnContext(ctx, "Too many pending emails, request dropped")
+	}
+}
+
+// 忘记密码：发送重置邮件，无论邮箱是否存在都返回相同的结果
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	// 按邮箱限制发送频率
+	email := strings.ToLower(req.Email)
+	count, err := database.IncrCache("password_reset_request:"+email, passwordResetInterval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
+		return
+	}
+	if count > 1 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another password reset email"})
+		return
+	}
+
+	sendMailInBackground(c, func(ctx context.Context) {
+		sendPasswordResetEmail(ctx, h.auth, email)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the address belongs to an account, a password reset email has been sent",
+	})
+}
+
+// 生成重置令牌并发送邮件，之前未使用的重置令牌全部作废
+func sendPasswordResetEmail(ctx context.Context, auth *services.AuthService, email string) {
+	user, raw, err := auth.RequestPasswordReset(ctx, email)
+	if err != nil {
+		if !errors.Is(err, services.ErrUserNotFound) {
+			slog.ErrorContext(ctx, "Failed to save password reset token", "error", err)
+		}
+		return
+	}
+
+	link := passwordResetLink(raw)
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"We received a request to reset your password. Open the link below to choose a new one:\n\n%s\n\n"+
+		"The link expires in %d minutes and can only be used once.\n"+
+		"If you did not request a password reset, you can ignore this email.\n",
+		user.Username, link, config.Current().PasswordResetExpireMinutes)
+
+	err = mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send password reset email", "user_id", user.ID, "error", err)
+	}
+}
+
+// 重置邮件中的链接，指向前端的重置密码页面，由页面提交到 /api/auth/password/reset
+func passwordResetLink(token string) string {
+	page := config.Current().PasswordResetURL
+	if page == "" {
+		page = strings.TrimRight(config.Current().AppBaseURL, "/") + "/reset-password"
+	}
+	separator := "?"
+	if strings.Contains(page, "?") {
+		separator = "&"
+	}
+	return page + separator + "token=" + url.QueryEscape(token)
+}
+
+// 重置密码：设置新密码并吊销该用户的所有会话和令牌
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if err := h.auth.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully, please log in again"})
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gin-auth-project/database"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roles *services.RoleService
+}
+
+func NewRoleHandler(roles *services.RoleService) *RoleHandler {
+	return &RoleHandler{roles: roles}
+}
+
+// 权限目录
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions := make([]gin.H, 0, len(models.PermissionCatalog))
+	for _, info := range models.PermissionCatalog {
+		permissions = append(permissions, gin.H{
+			"name":        info.Name,
+			"description": info.Description,
+			"privileged":  info.Privileged,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+// 获取所有角色
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roles.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	responses := make([]models.RoleResponse, 0, len(roles))
+	for i := range roles {
+		responses = append(responses, roles[i].ToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": responses})
+}
+
+// 创建角色，授予的权限不能超出当前用户自己的权限
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if !checkGrantablePermissions(c, req.Permissions) {
+		return
+	}
+
+	role, err := h.roles.Create(c.Request.Context(), services.CreateRoleInput{
+		Name:        req.Name,
+		Description: req.Description,
+		RequireMFA:  req.RequireMFA,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrRoleExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Role created successfully",
+		"role":    role.ToResponse(),
+	})
+}
+
+// 更新角色的描述、两步验证要求和权限，管理员角色始终拥有全部权限
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	if req.Permissions != nil && role.Name == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The admin role always has all permissions"})
+		return
+	}
+
+	if req.Permissions != nil && !checkGrantablePermissions(c, req.Permissions) {
+		return
+	}
+
+	err := h.roles.Update(c.Request.Context(), role, services.UpdateRoleInput{
+		Description: req.Description,
+		RequireMFA:  req.RequireMFA,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated successfully",
+		"role":    role.ToResponse(),
+	})
+}
+
+// 删除角色，内置角色和仍作为用户主角色使用的角色不能删除
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Built-in roles cannot be deleted"})
+		return
+	}
+
+	if err := h.roles.Delete(c.Request.Context(), role); err != nil {
+		if errors.Is(err, services.ErrRoleInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Role is still the primary role of some users"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// 按路径中的ID查找角色
+func (h *RoleHandler) findRole(c *gin.Context) (*models.RoleDefinition, bool) {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return nil, false
+	}
+
+	role, err := h.roles.Get(c.Request.Context(), uint(roleID))
+	if err != nil {
+		if errors.Is(err, services.ErrRoleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		}
+		return nil, false
+	}
+	return role, true
+}
+
+// 校验权限，只能授予当前用户自己拥有的权限，避免通过角色提升权限
+func checkGrantablePermissions(c *gin.Context, names []string) bool {
+	for _, name := range names {
+		if !models.IsKnownPermission(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + name})
+			return false
+		}
+		if !middleware.HasPermission(c, name) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant a permission you do not have: " + name})
+			return false
+		}
+	}
+	return true
+}
+
+// 校验并加载角色，角色必须存在，且其权限不能超出当前用户自己的权限
+func loadGrantableRoles(users *services.UserService, c *gin.Context, names []models.Role) ([]models.RoleDefinition, bool) {
+	roles, err := users.FindRoles(c.Request.Context(), names)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roles"})
+		return nil, false
+	}
+
+	found := make(map[models.Role]bool)
+	for _, role := range roles {
+		found[role.Name] = true
+		for _, permission := range role.Permissions {
+			if !middleware.HasPermission(c, permission.Name) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Cannot assign a role with permissions you do not have: " + string(role.Name)})
+				return nil, false
+			}
+		}
+	}
+	for _, name := range names {
+		if !found[name] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + string(name)})
+			return nil, false
+		}
+	}
+	return roles, true
+}
+
+// 用户是否拥有管理类权限，查询失败时按主角色判断
+func hasPrivilegedPermission(c *gin.Context, user *models.User) bool {
+	permissions, err := database.UserPermissions(user)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to resolve permissions", "user_id", user.ID, "error", err)
+		return user.Role == models.RoleAdmin
+	}
+	for _, permission := range permissions {
+		if models.IsPrivilegedPermission(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// 设置用户的附加角色（整体替换）
+func (h *UserHandler) SetUserRoles(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	user, ok := h.findUser(c, userID)
+	if !ok {
+		return
+	}
+
+	roles, ok := loadGrantableRoles(h.users, c, req.Roles)
+	if !ok {
+		return
+	}
+
+	if err := h.users.SetRoles(c.Request.Context(), user, roles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user roles"})
+		return
+	}
+
+	user.Roles = roles
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User roles updated successfully",
+		"user":    user.ToResponse(),
+	})
+}
+
+// 修改主角色前的校验，需要users:assign_roles权限
+func checkPrimaryRoleChange(users *services.UserService, c *gin.Context, role models.Role) bool {
+	if !middleware.HasPermission(c, models.PermissionUsersAssignRoles) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions to change roles"})
+		return false
+	}
+	_, ok := loadGrantableRoles(users, c, []models.Role{role})
+	return ok
+}
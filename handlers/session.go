@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	auth  *services.AuthService
+	users *services.UserService
+}
+
+func NewSessionHandler(auth *services.AuthService, users *services.UserService) *SessionHandler {
+	return &SessionHandler{auth: auth, users: users}
+}
+
+// 创建登录会话
+func createSession(auth *services.AuthService, c *gin.Context, user *models.User, device string, authMethods []string) (*models.Session, error) {
+	return createSessionWith(auth, c, user, device, authMethods, nil)
+}
+
+// 创建登录会话，customize可以在保存前补充会话字段
+func createSessionWith(auth *services.AuthService, c *gin.Context, user *models.User, device string, authMethods []string, customize func(*models.Session)) (*models.Session, error) {
+	userAgent := c.Request.UserAgent()
+	if device == "" {
+		device = describeDevice(userAgent)
+	}
+
+	return auth.StartSession(c.Request.Context(), user, services.SessionInfo{
+		Device:      device,
+		IP:          c.ClientIP(),
+		UserAgent:   userAgent,
+		AuthMethods: authMethods,
+	}, customize)
+}
+
+// 根据User-Agent粗略识别设备
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return "Unknown"
+	case strings.Contains(ua, "iphone"):
+		return "iPhone"
+	case strings.Contains(ua, "ipad"):
+		return "iPad"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os"):
+		return "Mac"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	default:
+		return "Other"
+	}
+}
+
+func sessionResponses(sessions []models.Session, currentID string) []models.SessionResponse {
+	responses := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, session.ToResponse(currentID))
+	}
+	return responses
+}
+
+// 获取当前用户的有效会话
+func (h *SessionHandler) ListMySessions(c *gin.Context) {
+	sessions, err := h.auth.Sessions(c.Request.Context(), middleware.GetCurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessionResponses(sessions, middleware.GetCurrentClaims(c).SessionID),
+	})
+}
+
+// 吊销当前用户的某个会话
+func (h *SessionHandler) RevokeMySession(c *gin.Context) {
+	h.revokeOne(c, middleware.GetCurrentUserID(c))
+}
+
+// 吊销当前用户除当前会话外的所有会话
+func (h *SessionHandler) RevokeMyOtherSessions(c *gin.Context) {
+	userID := middleware.GetCurrentUserID(c)
+
+	count, err := h.auth.RevokeUserSessions(c.Request.Context(), userID, middleware.GetCurrentClaims(c).SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Other sessions revoked successfully",
+		"revoked": count,
+	})
+}
+
+// 获取指定用户的有效会话（仅管理员）
+func (h *SessionHandler) ListUserSessions(c *gin.Context) {
+	userID, ok := parseAccessibleUserIDParam(h.users, c, models.PermissionUsersManageSessions, false)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.auth.Sessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessionResponses(sessions, middleware.GetCurrentClaims(c).SessionID),
+	})
+}
+
+// 吊销指定用户的某个会话（仅管理员）
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	userID, ok := parseAccessibleUserIDParam(h.users, c, models.PermissionUsersManageSessions, true)
+	if !ok {
+		return
+	}
+	h.revokeOne(c, userID)
+}
+
+// 吊销指定用户的所有会话（仅管理员），管理员操作自己时保留当前会话
+func (h *SessionHandler) RevokeAllUserSessions(c *gin.Context) {
+	userID, ok := parseAccessibleUserIDParam(h.users, c, models.PermissionUsersManageSessions, true)
+	if !ok {
+		return
+	}
+
+	exceptID := ""
+	if userID == middleware.GetCurrentUserID(c) {
+		exceptID = middleware.GetCurrentClaims(c).SessionID
+	}
+
+	count, err := h.auth.RevokeUserSessions(c.Request.Context(), userID, exceptID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sessions revoked successfully",
+		"revoked": count,
+	})
+}
+
+func (h *SessionHandler) revokeOne(c *gin.Context, userID uint) {
+	session, err := h.auth.FindUserSession(c.Request.Context(), userID, c.Param("sid"))
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		}
+		return
+	}
+
+	if err := h.auth.RevokeSession(c.Request.Context(), session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// 解析路径中的用户ID
+func parseUserIDParam(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(userID), true
+}
@@ -1,34 +1,37 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
-	"gin-auth-project/database"
 	"gin-auth-project/middleware"
 	"gin-auth-project/models"
-	"gin-auth-project/utils"
+	"gin-auth-project/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-type UserHandler struct{}
+type UserHandler struct {
+	users *services.UserService
+}
+
+func NewUserHandler(users *services.UserService) *UserHandler {
+	return &UserHandler{users: users}
+}
 
 // 获取所有用户（仅管理员）
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
-	var users []models.User
-
 	// 分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	offset := (page - 1) * limit
 
-	// 查询用户总数
-	var total int64
-	database.DB.Model(&models.User{}).Count(&total)
+	// 只通过组织成员角色获得权限时只能看到当前组织的成员
+	orgID := middleware.PermissionOrgScope(c, models.PermissionUsersRead)
 
-	// 查询用户列表
-	if err := database.DB.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+	users, total, err := h.users.List(c.Request.Context(), orgID, page, limit)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
@@ -57,14 +60,18 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !checkUserAccess(h.users, c, models.PermissionUsersRead, uint(userID), false) {
+		return
+	}
+
+	user, ok := h.findUser(c, uint(userID))
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": user.ToResponse(),
+		"user":         user.ToResponse(),
+		"locked_until": accountLockedUntil(c.Request.Context(), user.ID),
 	})
 }
 
@@ -76,38 +83,19 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	// 检查用户名是否已存在
-	var existingUser models.User
-	if err := database.DB.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-		return
-	}
-
-	// 检查邮箱是否已存在
-	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-		return
-	}
-
-	// 加密密码
-	hashedPassword, err := utils.HashPassword(req.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
-		return
-	}
-
-	// 创建新用户
-	newUser := models.User{
+	// 检查用户名和邮箱是否已存在，加密密码后创建用户
+	newUser, err := h.users.Create(c.Request.Context(), services.CreateUserInput{
 		Username: req.Username,
 		Email:    req.Email,
-		Password: hashedPassword,
-		Role:     models.RoleUser,
-		IsActive: true,
+		Password: req.Password,
+	})
+	if err != nil {
+		respondUserError(c, err, "Failed to create user")
+		return
 	}
 
-	if err := database.DB.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
+	if err := sendVerificationEmail(newUser); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to send verification email", "user_id", newUser.ID, "error", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -130,52 +118,33 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// 查找用户
-	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !checkUserAccess(h.users, c, models.PermissionUsersUpdate, uint(userID), true) {
 		return
 	}
 
-	updates := make(map[string]interface{})
-
-	if req.Email != "" {
-		// 检查邮箱是否已被其他用户使用
-		var existingUser models.User
-		if err := database.DB.Where("email = ? AND id != ?", req.Email, userID).First(&existingUser).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-			return
-		}
-		updates["email"] = req.Email
+	// 查找用户
+	user, ok := h.findUser(c, uint(userID))
+	if !ok {
+		return
 	}
 
-	if req.Password != "" {
-		hashedPassword, err := utils.HashPassword(req.Password)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
-			return
-		}
-		updates["password"] = hashedPassword
+	// 修改主角色需要users:assign_roles权限
+	if req.Role != "" && req.Role != user.Role && !checkPrimaryRoleChange(h.users, c, req.Role) {
+		return
 	}
 
-	if req.Role != "" {
-		updates["role"] = req.Role
+	result, err := h.users.Update(c.Request.Context(), user, services.UpdateUserInput{Email: req.Email, Password: req.Password, Role: req.Role})
+	if err != nil {
+		respondUserError(c, err, "Failed to update user")
+		return
 	}
 
-	if len(updates) > 0 {
-		if err := database.DB.Model(&user).Updates(updates).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-			return
+	if result.EmailChanged {
+		if err := sendVerificationEmail(user); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to send verification email", "user_id", user.ID, "error", err)
 		}
 	}
 
-	// 清除用户缓存
-	cacheKey := "user:" + strconv.FormatUint(userID, 10)
-	err = database.DeleteCache(cacheKey)
-	if err != nil {
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User updated successfully",
 		"user":    user.ToResponse(),
@@ -197,25 +166,17 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	user, ok := h.findUser(c, uint(userID))
+	if !ok {
 		return
 	}
 
 	// 软删除用户
-	if err := database.DB.Delete(&user).Error; err != nil {
+	if err := h.users.Delete(c.Request.Context(), user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	// 清除用户缓存
-	cacheKey := "user:" + strconv.FormatUint(userID, 10)
-	err = database.DeleteCache(cacheKey)
-	if err != nil {
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User deleted successfully",
 	})
@@ -229,23 +190,18 @@ func (h *UserHandler) ToggleUserStatus(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !checkUserAccess(h.users, c, models.PermissionUsersDeactivate, uint(userID), true) {
 		return
 	}
 
-	// 切换用户状态
-	user.IsActive = !user.IsActive
-	if err := database.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
+	user, ok := h.findUser(c, uint(userID))
+	if !ok {
 		return
 	}
 
-	// 清除用户缓存
-	cacheKey := "user:" + strconv.FormatUint(userID, 10)
-	err = database.DeleteCache(cacheKey)
-	if err != nil {
+	// 切换用户状态
+	if err := h.users.SetActive(c.Request.Context(), user, !user.IsActive); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
 		return
 	}
 
@@ -254,3 +210,17 @@ func (h *UserHandler) ToggleUserStatus(c *gin.Context) {
 		"user":    user.ToResponse(),
 	})
 }
+
+// 查找用户，不存在时直接返回404
+func (h *UserHandler) findUser(c *gin.Context, userID uint) (*models.User, bool) {
+	user, err := h.users.Get(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		}
+		return nil, false
+	}
+	return user, true
+}
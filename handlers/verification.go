@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/mailer"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 同一邮箱两次发送验证邮件的最短间隔
+const verificationResendInterval = time.Minute
+
+// 发送邮箱验证邮件
+func sendVerificationEmail(user *models.User) error {
+	token, err := utils.GenerateEmailVerificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(config.Current().AppBaseURL, "/") + "/api/auth/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Please confirm your email address by opening the link below:\n\n%s\n\n"+
+		"The link expires in %d hours and can only be used once.\n"+
+		"If you did not create an account, you can ignore this email.\n",
+		user.Username, link, config.Current().EmailVerificationExpireHours)
+
+	return mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    body,
+	})
+}
+
+// 开启邮箱验证要求时，未验证邮箱的用户不能登录
+func requireVerifiedEmail(c *gin.Context, user *models.User) bool {
+	if config.Current().EmailVerificationRequired && !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                       "Email address is not verified",
+			"email_verification_required": true,
+		})
+		return false
+	}
+	return true
+}
+
+// 验证邮箱（支持邮件链接的GET请求和JSON格式的POST请求）
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var req models.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		token = req.Token
+	}
+
+	claims, err := utils.ValidateToken(token)
+	if err != nil || claims.Purpose != utils.PurposeEmailVerification || database.IsTokenRevoked(claims.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	// 令牌签发后邮箱被修改过时作废
+	user, err := h.users.Get(c.Request.Context(), claims.UserID)
+	if err != nil || user.Email != claims.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	if err := h.users.MarkEmailVerified(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	// 验证令牌只能使用一次
+	if err := database.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		slog.WarnContext(c.Request.Context(), "Verification token revoked locally only", "token_id", claims.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+		"user":    user.ToResponse(),
+	})
+}
+
+// 重新发送验证邮件，无论邮箱是否存在都返回相同的结果
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	// 按邮箱限制发送频率
+	email := strings.ToLower(req.Email)
+	count, err := database.IncrCache("verify_email_resend:"+email, verificationResendInterval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+	if count > 1 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Please wait before requesting another verification email"})
+		return
+	}
+
+	sendMailInBackground(c, func(ctx context.Context) {
+		user, err := h.users.FindByEmail(ctx, email)
+		if err != nil || user.EmailVerified || !user.IsActive {
+			return
+		}
+		if err := sendVerificationEmail(user); err != nil {
+			slog.ErrorContext(ctx, "Failed to send verification email", "user_id", user.ID, "error", err)
+		}
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the address belongs to an unverified account, a verification email has been sent",
+	})
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gin-auth-project/database"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebAuthn仪式（注册/登录）状态的有效期
+const webAuthnCeremonyTTL = 5 * time.Minute
+
+// 保存在缓存中的仪式状态
+type webAuthnCeremony struct {
+	UserID  uint                 `json:"user_id"`
+	Session webauthn.SessionData `json:"session"`
+}
+
+// 保存仪式状态，返回供客户端回传的challenge_id
+func saveWebAuthnCeremony(userID uint, session *webauthn.SessionData) (string, error) {
+	challengeID, err := utils.GenerateID()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(webAuthnCeremony{UserID: userID, Session: *session})
+	if err != nil {
+		return "", err
+	}
+
+	if err := database.SetCache("webauthn:"+challengeID, data, webAuthnCeremonyTTL); err != nil {
+		return "", err
+	}
+	return challengeID, nil
+}
+
+// 取出仪式状态，每个challenge只能使用一次
+func takeWebAuthnCeremony(challengeID string) (*webAuthnCeremony, error) {
+	data, err := database.TakeCache("webauthn:" + challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ceremony webAuthnCeremony
+	if err := json.Unmarshal([]byte(data), &ceremony); err != nil {
+		return nil, err
+	}
+	return &ceremony, nil
+}
+
+// 开始注册通行密钥
+func (h *AuthHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	web, err := utils.NewWebAuthn()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "WebAuthn is not configured correctly"})
+		return
+	}
+
+	webUser, err := h.users.WebAuthnUser(c.Request.Context(), middleware.GetCurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	options, session, err := web.BeginRegistration(webUser,
+		webauthn.WithExclusions(webauthn.Credentials(webUser.WebAuthnCredentials()).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin registration"})
+		return
+	}
+
+	challengeID, err := saveWebAuthnCeremony(webUser.User.ID, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge_id": challengeID,
+		"options":      options,
+	})
+}
+
+// 完成注册通行密钥
+func (h *AuthHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	var req models.WebAuthnRegisterFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	userID := middleware.GetCurrentUserID(c)
+	ceremony, err := takeWebAuthnCeremony(req.ChallengeID)
+	if err != nil || ceremony.UserID != userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential"})
+		return
+	}
+
+	web, err := utils.NewWebAuthn()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "WebAuthn is not configured correctly"})
+		return
+	}
+
+	webUser, err := h.users.WebAuthnUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	credential, err := web.CreateCredential(webUser, ceremony.Session, parsed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to verify credential"})
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = describeDevice(c.Request.UserAgent())
+	}
+
+	record := utils.NewWebAuthnCredential(userID, name, credential)
+	if err := h.users.AddWebAuthnCredential(c.Request.Context(), &record); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Credential already registered"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Passkey registered successfully",
+		"credential": record,
+	})
+}
+
+// 开始通行密钥登录
+// 始终使用可发现凭证，不接受用户名，避免根据返回的可用凭证判断账号是否存在、是否注册了通行密钥
+func (h *AuthHandler) BeginWebAuthnLogin(c *gin.Context) {
+	web, err := utils.NewWebAuthn()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "WebAuthn is not configured correctly"})
+		return
+	}
+
+	options, session, err := web.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin login"})
+		return
+	}
+
+	challengeID, err := saveWebAuthnCeremony(0, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge_id": challengeID,
+		"options":      options,
+	})
+}
+
+// 完成通行密钥登录，签发与密码登录相同的令牌
+func (h *AuthHandler) FinishWebAuthnLogin(c *gin.Context) {
+	var req models.WebAuthnLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	ceremony, err := takeWebAuthnCeremony(req.ChallengeID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential"})
+		return
+	}
+
+	web, err := utils.NewWebAuthn()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "WebAuthn is not configured correctly"})
+		return
+	}
+
+	var webUser *utils.WebAuthnUser
+	_, credential, err := web.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, ok := utils.UserIDFromWebAuthnHandle(userHandle)
+		if !ok {
+			return nil, errors.New("invalid user handle")
+		}
+		user, err := h.users.WebAuthnUser(c.Request.Context(), userID)
+		if err != nil {
+			return nil, err
+		}
+		webUser = user
+		return user, nil
+	}, ceremony.Session, parsed)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	// 签名计数器回退说明凭证可能被克隆
+	if credential.Authenticator.CloneWarning {
+		slog.WarnContext(c.Request.Context(), "WebAuthn sign count regression, possible cloned credential", "user_id", webUser.User.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	// 先检查账号状态，停用的账号不更新凭证
+	if !webUser.User.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+		return
+	}
+
+	err = h.users.RecordWebAuthnLogin(c.Request.Context(), credential.ID, credential.Authenticator.SignCount, uint8(credential.Flags.ProtocolValue()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credential"})
+		return
+	}
+
+	if !requireVerifiedEmail(c, webUser.User) {
+		return
+	}
+
+	// 要求了用户验证（生物识别/PIN），通行密钥本身即为多因素认证
+	h.completeLogin(c, webUser.User, req.Device, []string{utils.AuthMethodHardwareKey, utils.AuthMethodMFA})
+}
+
+// 获取当前用户的通行密钥
+func (h *AuthHandler) ListWebAuthnCredentials(c *gin.Context) {
+	credentials, err := h.users.WebAuthnCredentials(c.Request.Context(), middleware.GetCurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credentials"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// 删除当前用户的通行密钥
+func (h *AuthHandler) DeleteWebAuthnCredential(c *gin.Context) {
+	credentialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential ID"})
+		return
+	}
+
+	err = h.users.DeleteWebAuthnCredential(c.Request.Context(), middleware.GetCurrentUserID(c), uint(credentialID))
+	if err != nil {
+		if errors.Is(err, services.ErrCredentialNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete credential"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GORM日志输出到slog：出错的语句为error，慢查询为warn，其他语句为debug
+// SQL只记录占位符，不记录绑定的参数值，避免密码哈希、令牌哈希等写入日志
+type gormLogger struct {
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+// slowThreshold为0时不记录慢查询
+func NewGormLogger(slowThreshold time.Duration) gormlogger.Interface {
+	return &gormLogger{level: gormlogger.Info, slowThreshold: slowThreshold}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, data...), "component", "gorm")
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, data...), "component", "gorm")
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, data...), "component", "gorm")
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "SQL query failed", "component", "gorm",
+			"sql", sql, "rows", rows, "duration_ms", durationMS(elapsed), "error", err)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Slow SQL query", "component", "gorm",
+			"sql", sql, "rows", rows, "duration_ms", durationMS(elapsed), "threshold_ms", durationMS(l.slowThreshold))
+	case l.level >= gormlogger.Info && slog.Default().Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		slog.DebugContext(ctx, "SQL query", "component", "gorm",
+			"sql", sql, "rows", rows, "duration_ms", durationMS(elapsed))
+	}
+}
+
+// 实现gorm.ParamsFilter，日志中的SQL不展开参数
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
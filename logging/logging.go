@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gin-auth-project/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 被替换为占位符的敏感字段值
+const redacted = "[REDACTED]"
+
+type requestIDKey struct{}
+
+// 全局日志的级别，重新加载配置时通过SetLevel修改
+var level slog.LevelVar
+
+// 初始化全局日志，slog.Default和标准库log都输出到同一个处理器
+func Init(cfg *config.Config) {
+	SetLevel(cfg.LogLevel)
+	slog.SetDefault(newLogger(os.Stdout, &level, cfg.LogFormat))
+}
+
+// 修改全局日志的级别，立即生效
+func SetLevel(l string) {
+	level.Set(ParseLevel(l))
+}
+
+// 创建日志记录器：format为text时输出key=value格式，否则输出JSON；
+// 上下文中的请求ID和链路ID会附加到每条日志，敏感字段的值会被替换
+func New(w io.Writer, level, format string) *slog.Logger {
+	return newLogger(w, ParseLevel(level), format)
+}
+
+func newLogger(w io.Writer, level slog.Leveler, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "text") {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// 解析日志级别（debug、info、warn、error），无法识别时使用info
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// 将请求ID保存到上下文中
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// 上下文中的请求ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// 从上下文读取请求ID和链路ID并附加到日志
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if requestID := RequestID(ctx); requestID != "" {
+			record.AddAttrs(slog.String("request_id", requestID))
+		}
+		// 链路追踪的trace_id，便于从日志跳转到对应的链路
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()), slog.String("span_id", spanContext.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
+
+// 密码、令牌、密钥等字段：完全匹配或以这些名称结尾（如new_password、refresh_token、client_secret）
+var (
+	sensitiveKeys     = []string{"authorization", "cookie", "set-cookie", "code", "otp"}
+	sensitiveSuffixes = []string{"password", "secret", "token", "api_key"}
+)
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range sensitiveKeys {
+		if key == name {
+			return true
+		}
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
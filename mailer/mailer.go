@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"errors"
+	"log/slog"
+
+	"gin-auth-project/config"
+)
+
+// 邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// 邮件发送接口，不同的传输方式实现该接口
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// 全局使用的邮件发送器
+var Default Mailer
+
+var errMailerNotInitialized = errors.New("mailer is not initialized")
+
+// 根据配置初始化邮件发送器
+func Init() {
+	cfg := config.Current()
+
+	switch cfg.MailTransport {
+	case "smtp":
+		Default = NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "memory":
+		Default = NewMemoryMailer()
+	default:
+		Default = NewFileMailer(cfg.MailOutboxDir, cfg.MailFrom)
+	}
+
+	slog.Info("Mail transport configured", "transport", cfg.MailTransport)
+}
+
+// 使用全局邮件发送器发送邮件
+func Send(msg *Message) error {
+	if Default == nil {
+		return errMailerNotInitialized
+	}
+	return Default.Send(msg)
+}
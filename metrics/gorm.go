@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+const gormStartKey = "metrics:start"
+
+var (
+	dbStatsMu        sync.Mutex
+	dbStatsCollector prometheus.Collector
+)
+
+// 采集数据库语句耗时和连接池状态；重复调用时连接池指标切换到新的连接
+func InstrumentDB(db *gorm.DB) error {
+	if err := db.Use(gormPlugin{}); err != nil && !errors.Is(err, gorm.ErrRegistered) {
+		return err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	dbStatsMu.Lock()
+	defer dbStatsMu.Unlock()
+	if dbStatsCollector != nil {
+		Registry.Unregister(dbStatsCollector)
+	}
+	dbStatsCollector = collectors.NewDBStatsCollector(sqlDB, db.Dialector.Name())
+	return Registry.Register(dbStatsCollector)
+}
+
+// 在GORM各类操作前后注册回调，记录语句耗时
+type gormPlugin struct{}
+
+func (gormPlugin) Name() string {
+	return "metrics"
+}
+
+func (gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", startQuery),
+		cb.Create().After("gorm:create").Register("metrics:after_create", observeQuery("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", startQuery),
+		cb.Query().After("gorm:query").Register("metrics:after_query", observeQuery("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", startQuery),
+		cb.Update().After("gorm:update").Register("metrics:after_update", observeQuery("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", startQuery),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", observeQuery("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", startQuery),
+		cb.Row().After("gorm:row").Register("metrics:after_row", observeQuery("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", startQuery),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", observeQuery("raw")),
+	)
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(gormStartKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+		if start, ok := value.(time.Time); ok {
+			dbQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package metrics 以Prometheus格式导出HTTP、认证和存储的指标
+// HTTP指标由中间件采集，认证指标订阅events包的事件，数据库和Redis指标通过GORM回调和go-redis钩子采集
+package metrics
+
+import (
+	"net/http"
+
+	"gin-auth-project/events"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 本服务的指标注册表，不使用全局默认注册表，避免依赖库注册的指标混入
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	authLogins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts by result (success, failure, blocked).",
+	}, []string{"result"})
+
+	authLockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_lockouts_total",
+		Help: "Temporary login lockouts by scope (account, ip).",
+	}, []string{"scope"})
+
+	authRegistrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "auth_registrations_total",
+		Help: "Self-service user registrations.",
+	})
+
+	authTokensIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_tokens_issued_total",
+		Help: "Issued tokens by type (access, refresh, personal_access).",
+	}, []string{"type"})
+
+	authTokensRevoked = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_tokens_revoked_total",
+		Help: "Revoked tokens and sessions by type (access, session, personal_access).",
+	}, []string{"type"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "GORM statement latency by operation.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"operation"})
+
+	redisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Redis command latency by command.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"command"})
+)
+
+// 登录结果
+const (
+	loginSuccess = "success"
+	loginFailure = "failure"
+	loginBlocked = "blocked"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		authLogins,
+		authLockouts,
+		authRegistrations,
+		authTokensIssued,
+		authTokensRevoked,
+		dbQueryDuration,
+		redisCommandDuration,
+	)
+
+	// 预先创建已知的标签组合，没有发生过的事件也导出为0
+	for _, result := range []string{loginSuccess, loginFailure, loginBlocked} {
+		authLogins.WithLabelValues(result)
+	}
+	for _, scope := range []string{"account", "ip"} {
+		authLockouts.WithLabelValues(scope)
+	}
+	for _, token := range []string{events.TokenAccess, events.TokenRefresh, events.TokenPersonal} {
+		authTokensIssued.WithLabelValues(token)
+	}
+	for _, token := range []string{events.TokenAccess, events.TokenSession, events.TokenPersonal} {
+		authTokensRevoked.WithLabelValues(token)
+	}
+
+	events.Subscribe(record)
+}
+
+// 将认证事件计入对应的计数器
+func record(event events.Event) {
+	count := float64(event.Count)
+	switch event.Type {
+	case events.LoginSucceeded:
+		authLogins.WithLabelValues(loginSuccess).Add(count)
+	case events.LoginFailed:
+		authLogins.WithLabelValues(loginFailure).Add(count)
+	case events.LoginBlocked:
+		authLogins.WithLabelValues(loginBlocked).Add(count)
+	case events.AccountLocked:
+		authLockouts.WithLabelValues("account").Add(count)
+	case events.IPLocked:
+		authLockouts.WithLabelValues("ip").Add(count)
+	case events.UserRegistered:
+		authRegistrations.Add(count)
+	case events.TokenIssued:
+		authTokensIssued.WithLabelValues(event.Token).Add(count)
+	case events.TokenRevoked:
+		authTokensRevoked.WithLabelValues(event.Token).Add(count)
+	}
+}
+
+// 导出指标的HTTP处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
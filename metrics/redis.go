@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type redisStartKey struct{}
+
+// 采集Redis命令耗时
+func InstrumentRedis(client *redis.Client) {
+	client.AddHook(redisHook{})
+}
+
+// go-redis钩子，流水线按整体计为一次pipeline命令
+type redisHook struct{}
+
+func (redisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observeRedis(ctx, cmd.Name())
+	return nil
+}
+
+func (redisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	observeRedis(ctx, "pipeline")
+	return nil
+}
+
+func observeRedis(ctx context.Context, command string) {
+	if start, ok := ctx.Value(redisStartKey{}).(time.Time); ok {
+		redisCommandDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	}
+}
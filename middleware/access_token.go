@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 校验个人访问令牌，上下文中的用户信息与JWT认证相同
+func authenticateAccessToken(auth *services.AuthService, c *gin.Context, tokenString string) bool {
+	token, err := auth.FindAccessToken(c.Request.Context(), tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return false
+	}
+
+	// 只读令牌不能发起修改请求
+	if !isSafeMethod(c.Request.Method) && !token.HasScope(models.AccessTokenScopeWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access token does not have the write scope"})
+		c.Abort()
+		return false
+	}
+
+	user, err := auth.FindUser(c.Request.Context(), token.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return false
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+		c.Abort()
+		return false
+	}
+
+	// 重置密码前创建的令牌一律失效
+	if user.PasswordChangedAt != nil && token.CreatedAt.Before(*user.PasswordChangedAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return false
+	}
+
+	auth.TouchAccessToken(c.Request.Context(), token)
+
+	claims := &utils.Claims{
+		UserID:      user.ID,
+		Username:    user.Username,
+		Role:        user.Role,
+		AuthMethods: token.AuthMethodList(),
+		OrgID:       token.OrgID,
+	}
+	setAuthContext(c, claims, user, tokenString)
+	c.Set("access_token", token)
+
+	return true
+}
+
+// 只允许登录会话的令牌访问，个人访问令牌不能管理账号安全设置
+func InteractiveMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetCurrentAccessToken(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires an interactive login"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 获取当前请求使用的个人访问令牌，使用JWT认证时返回nil
+func GetCurrentAccessToken(c *gin.Context) *models.PersonalAccessToken {
+	token, exists := c.Get("access_token")
+	if !exists {
+		return nil
+	}
+	return token.(*models.PersonalAccessToken)
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -6,82 +6,171 @@ import (
 
 	"gin-auth-project/database"
 	"gin-auth-project/models"
+	"gin-auth-project/services"
 	"gin-auth-project/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // 认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(auth *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+		if !authenticate(auth, c, "") {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// 检查Bearer前缀
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+// 两步验证中间件，只接受登录第一步签发的待验证令牌
+func MFAPendingMiddleware(auth *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(auth, c, utils.PurposeMFAPending) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		tokenString := parts[1]
-		claims, err := utils.ValidateToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+// OAuth访问令牌中间件，只接受通过OAuth授权签发给客户端的访问令牌，用于userinfo
+func OAuthMiddleware(auth *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(auth, c, utils.PurposeOAuthAccess) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// 检查用户是否仍然存在且激活
-		var user models.User
-		if err := database.DB.First(&user, claims.UserID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			c.Abort()
-			return
-		}
+// 校验Bearer令牌及其用途，成功时将用户信息写入上下文，失败时中止请求
+func authenticate(auth *services.AuthService, c *gin.Context, purpose string) bool {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+		c.Abort()
+		return false
+	}
 
-		if !user.IsActive {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
-			c.Abort()
-			return
-		}
+	// 检查Bearer前缀
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		c.Abort()
+		return false
+	}
 
-		// 将用户信息存储到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
-		c.Set("user", user)
+	tokenString := parts[1]
 
-		c.Next()
+	// 个人访问令牌
+	if purpose == "" && strings.HasPrefix(tokenString, utils.AccessTokenPrefix) {
+		return authenticateAccessToken(auth, c, tokenString)
+	}
+
+	claims, err := utils.ValidateToken(tokenString)
+	if err != nil || claims.Purpose != purpose {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return false
+	}
+
+	// 检查令牌是否已被吊销（登出）
+	if database.IsTokenRevoked(utils.TokenRevocationID(claims, tokenString)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return false
+	}
+
+	// 检查令牌所属的会话是否仍然有效
+	if claims.SessionID != "" && !auth.CheckSession(c.Request.Context(), claims.SessionID, claims.UserID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+		c.Abort()
+		return false
+	}
+
+	// 检查用户是否仍然存在且激活
+	user, err := auth.FindUser(c.Request.Context(), claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return false
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is deactivated"})
+		c.Abort()
+		return false
+	}
+
+	// 重置密码前签发的令牌一律失效
+	if issuedBeforePasswordChange(claims, user) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return false
+	}
+
+	setAuthContext(c, claims, user, tokenString)
+	return true
+}
+
+// 将用户信息存储到上下文中
+func setAuthContext(c *gin.Context, claims *utils.Claims, user *models.User, tokenString string) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+	c.Set("user", user)
+	c.Set("claims", claims)
+	c.Set("token", tokenString)
+}
+
+// 令牌是否签发于最近一次修改密码之前；iat精度为秒，无法区分同一秒内的先后，同一秒内签发的令牌也视为失效
+func issuedBeforePasswordChange(claims *utils.Claims, user *models.User) bool {
+	if user.PasswordChangedAt == nil || claims.IssuedAt == nil {
+		return false
 	}
+	return !claims.IssuedAt.Time.After(*user.PasswordChangedAt)
 }
 
 // 角色权限中间件
 func RoleMiddleware(allowedRoles ...models.Role) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-			c.Abort()
+		if !checkRole(c, allowedRoles...) {
 			return
 		}
+		c.Next()
+	}
+}
+
+// 检查当前用户角色，不满足时中止请求
+func checkRole(c *gin.Context, allowedRoles ...models.Role) bool {
+	userRole, exists := c.Get("role")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.Abort()
+		return false
+	}
+
+	role := userRole.(models.Role)
+	for _, allowedRole := range allowedRoles {
+		if role == allowedRole {
+			return true
+		}
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	c.Abort()
+	return false
+}
 
-		role := userRole.(models.Role)
-		allowed := false
-		for _, allowedRole := range allowedRoles {
-			if role == allowedRole {
-				allowed = true
-				break
-			}
+// 管理员权限中间件，个人访问令牌需要admin权限范围
+// 开启REQUIRE_ADMIN_MFA时还要求本次登录（或创建个人访问令牌时的登录）通过了两步验证
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkRole(c, models.RoleAdmin) {
+			return
 		}
 
-		if !allowed {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		if reason := privilegedDenial(c); reason != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": reason})
 			c.Abort()
 			return
 		}
@@ -90,11 +179,6 @@ func RoleMiddleware(allowedRoles ...models.Role) gin.HandlerFunc {
 	}
 }
 
-// 管理员权限中间件
-func AdminMiddleware() gin.HandlerFunc {
-	return RoleMiddleware(models.RoleAdmin)
-}
-
 // 用户权限中间件（管理员和普通用户）
 func UserMiddleware() gin.HandlerFunc {
 	return RoleMiddleware(models.RoleAdmin, models.RoleUser)
@@ -117,3 +201,19 @@ func GetCurrentUser(c *gin.Context) *models.User {
 	user, _ := c.Get("user")
 	return user.(*models.User)
 }
+
+// 获取当前请求的令牌声明
+func GetCurrentClaims(c *gin.Context) *utils.Claims {
+	claims, _ := c.Get("claims")
+	return claims.(*utils.Claims)
+}
+
+// 获取当前选择的组织ID，0表示未选择组织
+func GetCurrentOrgID(c *gin.Context) uint {
+	return GetCurrentClaims(c).OrgID
+}
+
+// 获取当前请求的原始令牌
+func GetCurrentToken(c *gin.Context) string {
+	return c.GetString("token")
+}
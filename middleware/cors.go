@@ -1,15 +1,23 @@
 package middleware
 
 import (
+	"gin-auth-project/config"
+
 	"github.com/gin-gonic/gin"
 )
 
-// CORSMiddleware 处理跨域请求
+// CORSMiddleware 处理跨域请求，允许的来源取自CORS_ALLOWED_ORIGINS，每次请求时读取当前配置
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if origin := allowedOrigin(c.GetHeader("Origin"), config.Current().CORSAllowedOrigins); origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				c.Header("Vary", "Origin")
+			}
+		}
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -20,3 +28,16 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// 请求来源在允许列表中时返回该来源，列表包含 * 时返回 *，否则返回空
+func allowedOrigin(origin string, allowed []string) string {
+	for _, a := range allowed {
+		if a == "*" {
+			return "*"
+		}
+		if origin != "" && a == origin {
+			return origin
+		}
+	}
+	return ""
+}
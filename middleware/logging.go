@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"gin-auth-project/logging"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求ID的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// 客户端提供的请求ID只接受有限长度的常见字符，避免日志注入
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware 沿用客户端或上游代理传入的X-Request-ID，没有时生成一个，
+// 写入响应头并保存到请求上下文中，之后的日志都会带上该ID
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			generated, err := utils.GenerateID()
+			if err != nil {
+				generated = fmt.Sprintf("%x", time.Now().UnixNano())
+			}
+			requestID = generated
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
+
+// 获取当前请求ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// RequestLoggerMiddleware 每个请求结束后记录一条访问日志，5xx为error，4xx为warn
+// 只记录路径不记录查询参数，邮件链接等场景的令牌放在查询参数中
+func RequestLoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.Request.Context(), level, "HTTP request", attrs...)
+	}
+}
+
+// RecoveryMiddleware 处理器panic时记录错误日志并返回500
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "Panic recovered", "error", fmt.Sprint(err), "path", c.Request.URL.Path, "stack", string(debug.Stack()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	})
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 权限中间件，例如 RequirePermission(models.PermissionUsersDelete)
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkPermission(c, permission) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// 检查当前用户是否拥有权限（全局角色或当前组织的成员角色），不满足时中止请求
+func checkPermission(c *gin.Context, permission string) bool {
+	set, err := currentPermissions(c)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to resolve permissions", "user_id", GetCurrentUserID(c), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		c.Abort()
+		return false
+	}
+
+	if !set.has(permission) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "required_permission": permission})
+		c.Abort()
+		return false
+	}
+
+	if models.IsPrivilegedPermission(permission) {
+		if reason := privilegedDenial(c); reason != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": reason})
+			c.Abort()
+			return false
+		}
+	}
+	return true
+}
+
+// 当前用户是否拥有权限（不中止请求），用于处理器内部的细粒度判断
+func HasPermission(c *gin.Context, permission string) bool {
+	set, err := currentPermissions(c)
+	if err != nil || !set.has(permission) {
+		return false
+	}
+	return !models.IsPrivilegedPermission(permission) || privilegedDenial(c) == ""
+}
+
+// 管理类权限对本次请求凭证的额外要求：个人访问令牌需要admin权限范围，
+// 开启REQUIRE_ADMIN_MFA或用户拥有要求两步验证的角色时，本次登录（或创建个人访问令牌时的登录）需要通过两步验证
+func privilegedDenial(c *gin.Context) string {
+	if token := GetCurrentAccessToken(c); token != nil && !token.HasScope(models.AccessTokenScopeAdmin) {
+		return "Access token does not have the admin scope"
+	}
+	if !GetCurrentClaims(c).IsMultiFactor() && (config.Current().RequireAdminMFA || roleRequiresMFA(c)) {
+		return "Two-factor authentication is required for admin accounts"
+	}
+	return ""
+}
+
+// 当前用户的角色是否要求两步验证，查询失败时按要求处理
+func roleRequiresMFA(c *gin.Context) bool {
+	value, _ := c.Get("user")
+	user, ok := value.(*models.User)
+	if !ok {
+		return false
+	}
+	required, err := database.UserRequiresMFA(user)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to check role MFA policy", "user_id", user.ID, "error", err)
+		return true
+	}
+	return required
+}
+
+// 权限的生效范围：返回0表示通过全局角色获得（不受限制），
+// 否则返回当前组织ID，表示只通过该组织的成员角色获得，处理器需要把操作限制在组织成员内
+func PermissionOrgScope(c *gin.Context, permission string) uint {
+	set, err := currentPermissions(c)
+	if err != nil || set.global[permission] || !set.org[permission] {
+		return 0
+	}
+	return set.orgID
+}
+
+// 当前用户的权限：全局角色的权限，以及当前组织成员角色中组织内有效的权限
+type permissionSet struct {
+	global map[string]bool
+	org    map[string]bool
+	orgID  uint
+}
+
+func (s *permissionSet) has(permission string) bool {
+	return s.global[permission] || s.org[permission]
+}
+
+// 当前用户的权限集合，同一请求内只解析一次
+// 全局权限和组织内权限分别保存在上下文的permissions和org_permissions中
+func currentPermissions(c *gin.Context) (*permissionSet, error) {
+	set := &permissionSet{orgID: GetCurrentOrgID(c)}
+
+	if cached, ok := c.Get("permissions"); ok {
+		set.global = cached.(map[string]bool)
+	} else {
+		permissions, err := database.UserPermissions(GetCurrentUser(c))
+		if err != nil {
+			return nil, err
+		}
+		set.global = toPermissionMap(permissions)
+		c.Set("permissions", set.global)
+	}
+
+	if set.orgID == 0 {
+		return set, nil
+	}
+
+	if cached, ok := c.Get("org_permissions"); ok {
+		set.org = cached.(map[string]bool)
+	} else {
+		permissions, err := database.MembershipPermissions(GetCurrentUserID(c), set.orgID)
+		if err != nil {
+			return nil, err
+		}
+		set.org = toPermissionMap(permissions)
+		c.Set("org_permissions", set.org)
+	}
+	return set, nil
+}
+
+func toPermissionMap(permissions []string) map[string]bool {
+	granted := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+	return granted
+}
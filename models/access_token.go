@@ -0,0 +1,93 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// 个人访问令牌的权限范围
+const (
+	AccessTokenScopeRead  = "read"  // 只读请求（GET、HEAD）
+	AccessTokenScopeWrite = "write" // 所有非管理请求
+	AccessTokenScopeAdmin = "admin" // 管理接口，仅管理员可以创建
+)
+
+// 个人访问令牌，用于脚本和CI，数据库中只保存令牌的哈希
+type PersonalAccessToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Name        string     `json:"name" gorm:"not null"`
+	TokenPrefix string     `json:"token_prefix"` // 令牌开头的几个字符，便于用户辨认
+	TokenHash   string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes      string     `json:"-"` // 权限范围，空格分隔
+	AuthMethods string     `json:"-"` // 创建令牌时所在会话的认证方式，逗号分隔
+	OrgID       uint       `json:"-"` // 创建令牌时所在会话选择的组织，0表示未选择
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// 权限范围列表
+func (t *PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// 是否拥有指定权限，admin包含write，write包含read
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, granted := range t.ScopeList() {
+		switch {
+		case granted == scope:
+			return true
+		case granted == AccessTokenScopeAdmin:
+			return true
+		case granted == AccessTokenScopeWrite && scope == AccessTokenScopeRead:
+			return true
+		}
+	}
+	return false
+}
+
+// 创建令牌时的认证方式列表
+func (t *PersonalAccessToken) AuthMethodList() []string {
+	if t.AuthMethods == "" {
+		return nil
+	}
+	return strings.Split(t.AuthMethods, ",")
+}
+
+// 令牌是否仍然有效
+func (t *PersonalAccessToken) IsActive() bool {
+	return t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// 个人访问令牌响应
+type PersonalAccessTokenResponse struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// 转换为响应格式
+func (t *PersonalAccessToken) ToResponse() PersonalAccessTokenResponse {
+	return PersonalAccessTokenResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		TokenPrefix: t.TokenPrefix,
+		Scopes:      t.ScopeList(),
+		ExpiresAt:   t.ExpiresAt,
+		LastUsedAt:  t.LastUsedAt,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+// 创建个人访问令牌请求
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=read write admin"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
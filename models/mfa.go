@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// 两步验证恢复码，只保存哈希，每个只能使用一次
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TOTP验证码请求（确认启用、重新生成恢复码）
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// 关闭TOTP请求
+type TOTPDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// 两步验证请求，code和recovery_code二选一
+type MFAVerifyRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	Device       string `json:"device" binding:"max=100"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// OAuth 2.0 / OpenID Connect 客户端
+// 机密客户端使用client_secret认证，公开客户端（单页应用、移动端）只依靠PKCE
+type OAuthClient struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	ClientID         string    `json:"client_id" gorm:"uniqueIndex;size:64;not null"`
+	ClientSecretHash string    `json:"-"`
+	Name             string    `json:"name" gorm:"not null"`
+	RedirectURIs     string    `json:"-" gorm:"type:text;not null"` // 允许的回调地址，空格分隔
+	Scopes           string    `json:"-"`                           // 允许申请的scope，空格分隔
+	Public           bool      `json:"public" gorm:"default:false"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// 允许的回调地址列表
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// 回调地址必须与注册的地址完全一致
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	for _, allowed := range c.RedirectURIList() {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// 允许申请的scope列表
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// 申请的scope是否都在允许范围内
+func (c *OAuthClient) AllowsScopes(scopes []string) bool {
+	allowed := make(map[string]bool)
+	for _, scope := range c.ScopeList() {
+		allowed[scope] = true
+	}
+	for _, scope := range scopes {
+		if !allowed[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// 客户端响应
+type OAuthClientResponse struct {
+	ID           uint      `json:"id"`
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// 转换为响应格式
+func (c *OAuthClient) ToResponse() OAuthClientResponse {
+	return OAuthClientResponse{
+		ID:           c.ID,
+		ClientID:     c.ClientID,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectURIList(),
+		Scopes:       c.ScopeList(),
+		Public:       c.Public,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// 授权码，只保存哈希，每个只能兑换一次
+type OAuthAuthorizationCode struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	CodeHash            string     `json:"-" gorm:"uniqueIndex;not null"`
+	ClientID            string     `json:"client_id" gorm:"index;not null"`
+	UserID              uint       `json:"user_id" gorm:"not null"`
+	SessionID           string     `json:"session_id" gorm:"size:64"` // 用户在授权页登录的会话
+	RedirectURI         string     `json:"redirect_uri"`
+	Scope               string     `json:"scope"`
+	Nonce               string     `json:"nonce"`
+	CodeChallenge       string     `json:"-"`
+	CodeChallengeMethod string     `json:"-"`
+	ExpiresAt           time.Time  `json:"expires_at"`
+	UsedAt              *time.Time `json:"used_at,omitempty"`
+	GrantSessionID      string     `json:"grant_session_id" gorm:"size:64"` // 兑换授权码时为客户端创建的会话
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// 用户对客户端的授权记录，已授权的scope不再重复询问
+type OAuthConsent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_oauth_consent_user_client;not null"`
+	ClientID  string    `json:"client_id" gorm:"uniqueIndex:idx_oauth_consent_user_client;size:64;not null"`
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 已授权的scope是否覆盖本次申请
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	granted := make(map[string]bool)
+	for _, scope := range strings.Fields(c.Scope) {
+		granted[scope] = true
+	}
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// 注册客户端请求
+type OAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+)
+
+// 组织（租户），同一部署中的不同客户团队
+type Organization struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex;size:64;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 组织成员，每个成员在组织内有一个角色
+// 角色的权限中只有组织内有效的权限（PermissionInfo.OrgScoped）在组织范围内生效
+type Membership struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_membership_org_user;not null"`
+	UserID         uint      `json:"user_id" gorm:"uniqueIndex:idx_membership_org_user;index;not null"`
+	Role           Role      `json:"role" gorm:"size:50;not null"`
+	User           User      `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// 组织邀请，只保存令牌的哈希，被邀请的邮箱接受后成为成员
+type OrganizationInvitation struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID uint       `json:"organization_id" gorm:"index;not null"`
+	Email          string     `json:"email" gorm:"not null"`
+	Role           Role       `json:"role" gorm:"size:50;not null"`
+	TokenHash      string     `json:"-" gorm:"uniqueIndex;not null"`
+	InvitedBy      uint       `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// 邀请是否仍可接受
+func (i *OrganizationInvitation) IsPending() bool {
+	return i.AcceptedAt == nil && time.Now().Before(i.ExpiresAt)
+}
+
+// 组织成员响应
+type MembershipResponse struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	IsActive  bool      `json:"is_active"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 转换为响应格式，需要预加载User
+func (m *Membership) ToResponse() MembershipResponse {
+	return MembershipResponse{
+		UserID:    m.UserID,
+		Username:  m.User.Username,
+		Email:     m.User.Email,
+		IsActive:  m.User.IsActive,
+		Role:      m.Role,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// 创建组织请求
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required,min=2,max=64"`
+}
+
+// 邀请成员请求
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  Role   `json:"role"`
+}
+
+// 修改成员角色请求
+type UpdateMembershipRequest struct {
+	Role Role `json:"role" binding:"required"`
+}
+
+// 接受邀请请求
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// 切换组织请求，org_id为0表示不选择组织
+type SwitchOrganizationRequest struct {
+	OrgID uint `json:"org_id"`
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"time"
+)
+
+// 权限名称，格式为 资源:操作
+const (
+	PermissionUsersRead           = "users:read"
+	PermissionUsersCreate         = "users:create"
+	PermissionUsersUpdate         = "users:update"
+	PermissionUsersDelete         = "users:delete"
+	PermissionUsersDeactivate     = "users:deactivate"
+	PermissionUsersAssignRoles    = "users:assign_roles"
+	PermissionUsersManageSessions = "users:manage_sessions"
+	PermissionRolesManage         = "roles:manage"
+	PermissionOAuthClientsManage  = "oauth_clients:manage"
+	PermissionOrgsManage          = "orgs:manage"
+	PermissionOrgMembersManage    = "org_members:manage"
+	PermissionProtectedRead       = "protected:read"
+)
+
+// 权限定义，Privileged表示管理类权限：
+// 个人访问令牌需要admin权限范围，开启REQUIRE_ADMIN_MFA时需要通过两步验证
+// OrgScoped表示通过组织成员角色授予时，在当前组织范围内生效
+type PermissionInfo struct {
+	Name        string
+	Description string
+	Privileged  bool
+	OrgScoped   bool
+}
+
+// 权限目录，由代码定义并在启动时写入数据库
+var PermissionCatalog = []PermissionInfo{
+	{PermissionUsersRead, "View users", true, true},
+	{PermissionUsersCreate, "Create users", true, false},
+	{PermissionUsersUpdate, "Update user email and password", true, true},
+	{PermissionUsersDelete, "Delete users", true, false},
+	{PermissionUsersDeactivate, "Activate and deactivate users", true, true},
+	{PermissionUsersAssignRoles, "Change user roles", true, false},
+	{PermissionUsersManageSessions, "View and revoke other users' sessions and access tokens", true, true},
+	{PermissionRolesManage, "Create, update and delete roles", true, false},
+	{PermissionOAuthClientsManage, "Register and delete OAuth clients", true, false},
+	{PermissionOrgsManage, "Create and delete organizations", true, false},
+	{PermissionOrgMembersManage, "Invite, remove and change the roles of organization members", true, true},
+	{PermissionProtectedRead, "Read protected data", false, true},
+}
+
+// 默认角色及其权限，管理员始终拥有全部权限
+var DefaultRolePermissions = map[Role][]string{
+	RoleAdmin: AllPermissions(),
+	RoleUser:  {PermissionProtectedRead},
+}
+
+// 全部权限名称
+func AllPermissions() []string {
+	names := make([]string, 0, len(PermissionCatalog))
+	for _, permission := range PermissionCatalog {
+		names = append(names, permission.Name)
+	}
+	return names
+}
+
+// 是否为已定义的权限
+func IsKnownPermission(name string) bool {
+	for _, permission := range PermissionCatalog {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否为管理类权限
+func IsPrivilegedPermission(name string) bool {
+	for _, permission := range PermissionCatalog {
+		if permission.Name == name {
+			return permission.Privileged
+		}
+	}
+	return false
+}
+
+// 是否可以通过组织成员角色授予
+func IsOrgScopedPermission(name string) bool {
+	for _, permission := range PermissionCatalog {
+		if permission.Name == name {
+			return permission.OrgScoped
+		}
+	}
+	return false
+}
+
+// 权限
+type Permission struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"uniqueIndex;size:100;not null"`
+	Description string `json:"description"`
+}
+
+// 角色，可以通过管理接口维护
+// 用户的主角色保存在User.Role中，此外还可以拥有多个附加角色（user_roles）
+type RoleDefinition struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        Role         `json:"name" gorm:"uniqueIndex;size:50;not null"`
+	Description string       `json:"description"`
+	IsSystem    bool         `json:"is_system" gorm:"default:false"`            // 内置角色不能删除
+	RequireMFA  bool         `json:"require_mfa" gorm:"not null;default:false"` // 拥有该角色的用户必须启用两步验证
+	Permissions []Permission `json:"-" gorm:"many2many:role_permissions;joinForeignKey:RoleID;joinReferences:PermissionID"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+func (RoleDefinition) TableName() string {
+	return "roles"
+}
+
+// 角色响应
+type RoleResponse struct {
+	ID          uint      `json:"id"`
+	Name        Role      `json:"name"`
+	Description string    `json:"description"`
+	IsSystem    bool      `json:"is_system"`
+	RequireMFA  bool      `json:"require_mfa"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// 转换为响应格式
+func (r *RoleDefinition) ToResponse() RoleResponse {
+	permissions := make([]string, 0, len(r.Permissions))
+	for _, permission := range r.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+	return RoleResponse{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		IsSystem:    r.IsSystem,
+		RequireMFA:  r.RequireMFA,
+		Permissions: permissions,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// 创建角色请求
+type CreateRoleRequest struct {
+	Name        Role     `json:"name" binding:"required,min=2,max=50"`
+	Description string   `json:"description" binding:"max=200"`
+	RequireMFA  bool     `json:"require_mfa"`
+	Permissions []string `json:"permissions"`
+}
+
+// 更新角色请求
+type UpdateRoleRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=200"`
+	RequireMFA  *bool    `json:"require_mfa"`
+	Permissions []string `json:"permissions"`
+}
+
+// 设置用户附加角色请求
+type SetUserRolesRequest struct {
+	Roles []Role `json:"roles"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// 登录会话，ID即JWT中的sid声明，同时也是刷新令牌的令牌族ID
+type Session struct {
+	ID          string     `json:"id" gorm:"primaryKey;size:64"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Device      string     `json:"device"`
+	IP          string     `json:"ip"`
+	UserAgent   string     `json:"user_agent"`
+	AuthMethods string     `json:"auth_methods"` // 登录时使用的认证方式，逗号分隔
+	ClientID    string     `json:"client_id"`    // 通过OAuth授权创建的会话所属的客户端
+	Scope       string     `json:"scope"`        // OAuth授权的scope，空格分隔
+	OrgID       uint       `json:"org_id"`       // 当前选择的组织，写入令牌的org_id声明，0表示未选择
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// 登录时使用的认证方式列表
+func (s *Session) AuthMethodList() []string {
+	if s.AuthMethods == "" {
+		return nil
+	}
+	return strings.Split(s.AuthMethods, ",")
+}
+
+// OAuth授权的scope列表
+func (s *Session) ScopeList() []string {
+	return strings.Fields(s.Scope)
+}
+
+// 会话是否仍然有效
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// 会话响应
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	ClientID   string    `json:"client_id,omitempty"`
+	OrgID      uint      `json:"org_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// 转换为响应格式
+func (s *Session) ToResponse(currentID string) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID,
+		Device:     s.Device,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		ClientID:   s.ClientID,
+		OrgID:      s.OrgID,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == currentID,
+	}
+}
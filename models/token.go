@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// 刷新令牌，数据库中只保存令牌的哈希
+// 同一次登录轮换出的刷新令牌属于同一个令牌族（FamilyID，即会话ID）
+type RefreshToken struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"index;not null"`
+	FamilyID     string     `json:"family_id" gorm:"index;not null"`
+	TokenHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
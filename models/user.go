@@ -14,15 +14,30 @@ const (
 )
 
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"uniqueIndex;not null"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"` // 密码不返回给前端
-	Role      Role           `json:"role" gorm:"default:'user'"`
-	IsActive  bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	Username      string         `json:"username" gorm:"uniqueIndex;not null"`
+	Email         string         `json:"email" gorm:"uniqueIndex;not null"`
+	Password      string         `json:"-" gorm:"not null"` // 密码不返回给前端
+	Role          Role           `json:"role" gorm:"default:'user'"`
+	IsActive      bool           `json:"is_active" gorm:"default:true"`
+	EmailVerified bool           `json:"email_verified" gorm:"default:false"` // 邮箱是否已验证
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	// 最近一次重置密码的时间，此前签发的令牌全部失效
+	PasswordChangedAt *time.Time `json:"-"`
+
+	// 两步验证（TOTP）
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `json:"totp_enabled" gorm:"default:false"`
+	TOTPLastStep int64  `json:"-" gorm:"default:0"` // 最近一次使用的时间窗口，防止验证码重放
+
+	// 附加角色，与主角色（Role）一起决定用户的权限
+	Roles []RoleDefinition `json:"-" gorm:"many2many:user_roles;joinForeignKey:UserID;joinReferences:RoleID"`
+
+	// WebAuthn凭证（通行密钥）
+	WebAuthnCredentials []WebAuthnCredential `json:"-" gorm:"foreignKey:UserID"`
 }
 
 type UserProfile struct {
@@ -42,6 +57,7 @@ type UserProfile struct {
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device" binding:"max=100"`
 }
 
 // 用户注册请求
@@ -51,6 +67,16 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+// 邮箱验证请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// 重新发送验证邮件请求
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
 // 用户更新请求
 type UpdateUserRequest struct {
 	Email    string `json:"email" binding:"omitempty,email"`
@@ -60,24 +86,41 @@ type UpdateUserRequest struct {
 
 // 用户响应
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Role      Role      `json:"role"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint      `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	Role          Role      `json:"role"`
+	Roles         []Role    `json:"roles"`
+	IsActive      bool      `json:"is_active"`
+	EmailVerified bool      `json:"email_verified"`
+	TOTPEnabled   bool      `json:"totp_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// 主角色和附加角色的名称
+func (u *User) RoleNames() []Role {
+	names := []Role{u.Role}
+	for _, role := range u.Roles {
+		if role.Name != u.Role {
+			names = append(names, role.Name)
+		}
+	}
+	return names
 }
 
 // 转换为响应格式
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		ID:        u.ID,
-		Username:  u.Username,
-		Email:     u.Email,
-		Role:      u.Role,
-		IsActive:  u.IsActive,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		Role:          u.Role,
+		Roles:         u.RoleNames(),
+		IsActive:      u.IsActive,
+		EmailVerified: u.EmailVerified,
+		TOTPEnabled:   u.TOTPEnabled,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
 	}
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebAuthn凭证（通行密钥/安全密钥）
+type WebAuthnCredential struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UserID          uint       `json:"user_id" gorm:"index;not null"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `json:"-" gorm:"uniqueIndex;not null"`
+	PublicKey       []byte     `json:"-" gorm:"not null"`
+	AttestationType string     `json:"attestation_type"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"sign_count"`
+	Flags           uint8      `json:"-"` // 认证器数据中的原始标志位（BE/BS等）
+	Transports      string     `json:"transports"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+}
+
+// 完成WebAuthn注册请求，credential为浏览器 navigator.credentials.create 的返回值
+type WebAuthnRegisterFinishRequest struct {
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	Name        string          `json:"name" binding:"max=100"`
+	Credential  json.RawMessage `json:"credential" binding:"required"`
+}
+
+// 完成WebAuthn登录请求，credential为浏览器 navigator.credentials.get 的返回值
+type WebAuthnLoginFinishRequest struct {
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	Device      string          `json:"device" binding:"max=100"`
+	Credential  json.RawMessage `json:"credential" binding:"required"`
+}
@@ -0,0 +1,738 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gin-auth-project/models"
+)
+
+// 内存中的用户数据，用于测试和本地开发
+type MemoryUserRepository struct {
+	mu            sync.RWMutex
+	nextID        uint
+	users         map[uint]models.User
+	roles         map[models.Role][]string
+	memberships   map[uint][]uint // 用户ID -> 按加入顺序排列的组织ID
+	recoveryCodes map[uint][]models.RecoveryCode
+	resetTokens   []models.PasswordResetToken
+	credentials   []models.WebAuthnCredential
+}
+
+// 预置内置角色及其默认权限
+func NewMemoryUserRepository() *MemoryUserRepository {
+	roles := make(map[models.Role][]string)
+	for role, permissions := range models.DefaultRolePermissions {
+		roles[role] = permissions
+	}
+	return &MemoryUserRepository{
+		users:         make(map[uint]models.User),
+		roles:         roles,
+		memberships:   make(map[uint][]uint),
+		recoveryCodes: make(map[uint][]models.RecoveryCode),
+	}
+}
+
+// 定义角色及其权限
+func (r *MemoryUserRepository) DefineRole(role models.Role, permissions ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles[role] = permissions
+}
+
+// 将用户加入组织
+func (r *MemoryUserRepository) AddMembership(orgID, userID uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.memberships[userID] = append(r.memberships[userID], orgID)
+}
+
+func (r *MemoryUserRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) FindByLogin(ctx context.Context, login string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if user.Username == login || user.Email == login {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryUserRepository) EmailExists(ctx context.Context, email string, exceptID uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if user.Email == email && user.ID != exceptID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryUserRepository) RoleExists(ctx context.Context, role models.Role) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.roles[role]
+	return ok, nil
+}
+
+func (r *MemoryUserRepository) FindRoles(ctx context.Context, names []models.Role) ([]models.RoleDefinition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var roles []models.RoleDefinition
+	for _, name := range names {
+		permissions, ok := r.roles[name]
+		if !ok {
+			continue
+		}
+		role := models.RoleDefinition{Name: name}
+		for _, permission := range permissions {
+			role.Permissions = append(role.Permissions, models.Permission{Name: permission})
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return fmt.Errorf("duplicate user %q", user.Username)
+		}
+	}
+
+	r.nextID++
+	now := time.Now()
+	user.ID = r.nextID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, user *models.User, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := applyUserUpdates(&stored, updates); err != nil {
+		return err
+	}
+
+	r.users[user.ID] = stored
+	*user = stored
+	return nil
+}
+
+// 按列名更新用户字段，只支持服务层使用的列
+func applyUserUpdates(user *models.User, updates map[string]interface{}) error {
+	for column, value := range updates {
+		switch column {
+		case "email":
+			user.Email = value.(string)
+		case "email_verified":
+			user.EmailVerified = value.(bool)
+		case "password":
+			user.Password = value.(string)
+		case "password_changed_at":
+			changedAt := value.(time.Time)
+			user.PasswordChangedAt = &changedAt
+		case "role":
+			user.Role = value.(models.Role)
+		case "is_active":
+			user.IsActive = value.(bool)
+		case "totp_secret":
+			user.TOTPSecret = value.(string)
+		default:
+			return fmt.Errorf("unsupported column %q", column)
+		}
+	}
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.users, user.ID)
+	return nil
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, orgID uint, offset, limit int) ([]models.User, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var users []models.User
+	for _, user := range r.users {
+		if orgID == 0 || r.isMember(orgID, user.ID) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	total := int64(len(users))
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(users) {
+		return nil, total, nil
+	}
+	users = users[offset:]
+	if limit >= 0 && limit < len(users) {
+		users = users[:limit]
+	}
+	return users, total, nil
+}
+
+func (r *MemoryUserRepository) isMember(orgID, userID uint) bool {
+	for _, id := range r.memberships[userID] {
+		if id == orgID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryUserRepository) LoadRoles(ctx context.Context, user *models.User) error {
+	return nil
+}
+
+func (r *MemoryUserRepository) SetRoles(ctx context.Context, user *models.User, roles []models.RoleDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Roles = roles
+	r.users[user.ID] = stored
+	user.Roles = roles
+	return nil
+}
+
+func (r *MemoryUserRepository) Permissions(ctx context.Context, user *models.User) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var permissions []string
+	for _, role := range user.RoleNames() {
+		for _, permission := range r.roles[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (r *MemoryUserRepository) DefaultOrgID(ctx context.Context, userID uint) (uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if orgs := r.memberships[userID]; len(orgs) > 0 {
+		return orgs[0], nil
+	}
+	return 0, nil
+}
+
+func (r *MemoryUserRepository) OrgIDs(ctx context.Context, userID uint) ([]uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]uint(nil), r.memberships[userID]...), nil
+}
+
+func (r *MemoryUserRepository) EnableTOTP(ctx context.Context, user *models.User, step int64, codes []models.RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.TOTPEnabled = true
+	stored.TOTPLastStep = step
+	r.users[user.ID] = stored
+	*user = stored
+	r.recoveryCodes[user.ID] = codes
+	return nil
+}
+
+func (r *MemoryUserRepository) DisableTOTP(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.TOTPEnabled = false
+	stored.TOTPSecret = ""
+	stored.TOTPLastStep = 0
+	r.users[user.ID] = stored
+	*user = stored
+	delete(r.recoveryCodes, user.ID)
+	return nil
+}
+
+func (r *MemoryUserRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []models.RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *MemoryUserRepository) ClaimTOTPStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[userID]
+	if !ok || stored.TOTPLastStep >= step {
+		return false, nil
+	}
+	stored.TOTPLastStep = step
+	r.users[userID] = stored
+	return true, nil
+}
+
+func (r *MemoryUserRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := r.recoveryCodes[userID]
+	for i := range codes {
+		if codes[i].CodeHash == codeHash && codes[i].UsedAt == nil {
+			now := time.Now()
+			codes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryUserRepository) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i := range r.resetTokens {
+		if r.resetTokens[i].UserID == token.UserID && r.resetTokens[i].UsedAt == nil {
+			r.resetTokens[i].UsedAt = &now
+		}
+	}
+	token.ID = uint(len(r.resetTokens) + 1)
+	token.CreatedAt = now
+	r.resetTokens = append(r.resetTokens, *token)
+	return nil
+}
+
+func (r *MemoryUserRepository) UsePasswordResetToken(ctx context.Context, tokenHash string, updates map[string]interface{}) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i := range r.resetTokens {
+		token := &r.resetTokens[i]
+		if token.TokenHash != tokenHash {
+			continue
+		}
+		if token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+			return 0, ErrNotFound
+		}
+		stored, ok := r.users[token.UserID]
+		if !ok {
+			return 0, ErrNotFound
+		}
+		if err := applyUserUpdates(&stored, updates); err != nil {
+			return 0, err
+		}
+		token.UsedAt = &now
+		r.users[token.UserID] = stored
+		return token.UserID, nil
+	}
+	return 0, ErrNotFound
+}
+
+func (r *MemoryUserRepository) WebAuthnCredentials(ctx context.Context, userID uint) ([]models.WebAuthnCredential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var credentials []models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *MemoryUserRepository) CreateWebAuthnCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.credentials {
+		if bytes.Equal(existing.CredentialID, credential.CredentialID) {
+			return fmt.Errorf("duplicate credential")
+		}
+	}
+	r.nextID++
+	credential.ID = r.nextID
+	credential.CreatedAt = time.Now()
+	r.credentials = append(r.credentials, *credential)
+	return nil
+}
+
+func (r *MemoryUserRepository) UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.credentials {
+		credential := &r.credentials[i]
+		if !bytes.Equal(credential.CredentialID, credentialID) {
+			continue
+		}
+		for column, value := range updates {
+			switch column {
+			case "sign_count":
+				credential.SignCount = value.(uint32)
+			case "flags":
+				credential.Flags = value.(uint8)
+			case "last_used_at":
+				usedAt := value.(time.Time)
+				credential.LastUsedAt = &usedAt
+			default:
+				return fmt.Errorf("unsupported column %q", column)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) DeleteWebAuthnCredential(ctx context.Context, userID, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, credential := range r.credentials {
+		if credential.ID == id && credential.UserID == userID {
+			r.credentials = append(r.credentials[:i], r.credentials[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// 内存中的会话和令牌，用于测试和本地开发
+type MemoryTokenStore struct {
+	mu            sync.RWMutex
+	nextID        uint
+	sessions      map[string]models.Session
+	refreshTokens map[uint]models.RefreshToken
+	accessTokens  map[uint]models.PersonalAccessToken
+	revoked       map[string]time.Time
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		sessions:      make(map[string]models.Session),
+		refreshTokens: make(map[uint]models.RefreshToken),
+		accessTokens:  make(map[uint]models.PersonalAccessToken),
+		revoked:       make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryTokenStore) CreateSession(ctx context.Context, session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session.CreatedAt = time.Now()
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+func (s *MemoryTokenStore) FindSession(ctx context.Context, id string) (*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (s *MemoryTokenStore) ListSessions(ctx context.Context, userID uint) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.IsActive() {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+func (s *MemoryTokenStore) UpdateSession(ctx context.Context, session *models.Session, updates map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sessions[session.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for column, value := range updates {
+		switch column {
+		case "last_seen_at":
+			stored.LastSeenAt = value.(time.Time)
+		case "org_id":
+			stored.OrgID = value.(uint)
+		default:
+			return fmt.Errorf("unsupported column %q", column)
+		}
+	}
+	s.sessions[session.ID] = stored
+	*session = stored
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeSession(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if session, ok := s.sessions[id]; ok && session.RevokedAt == nil {
+		session.RevokedAt = &now
+		s.sessions[id] = session
+	}
+	for tokenID, token := range s.refreshTokens {
+		if token.FamilyID == id && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.refreshTokens[tokenID] = token
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeUserSessions(ctx context.Context, userID uint, exceptID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var revoked int64
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && id != exceptID {
+			session.RevokedAt = &now
+			s.sessions[id] = session
+			revoked++
+		}
+	}
+	for tokenID, token := range s.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil && token.FamilyID != exceptID {
+			token.RevokedAt = &now
+			s.refreshTokens[tokenID] = token
+		}
+	}
+	return revoked, nil
+}
+
+func (s *MemoryTokenStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.createRefreshToken(token)
+	return nil
+}
+
+func (s *MemoryTokenStore) createRefreshToken(token *models.RefreshToken) {
+	s.nextID++
+	token.ID = s.nextID
+	token.CreatedAt = time.Now()
+	s.refreshTokens[token.ID] = *token
+}
+
+func (s *MemoryTokenStore) FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryTokenStore) RotateRefreshToken(ctx context.Context, old, next *models.RefreshToken, session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.refreshTokens[old.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.RevokedAt != nil {
+		return ErrConflict
+	}
+
+	now := time.Now()
+	s.createRefreshToken(next)
+	stored.RevokedAt = &now
+	stored.ReplacedByID = &next.ID
+	s.refreshTokens[old.ID] = stored
+
+	if current, ok := s.sessions[session.ID]; ok {
+		current.LastSeenAt = now
+		current.ExpiresAt = next.ExpiresAt
+		s.sessions[session.ID] = current
+		*session = current
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[tokenID] = expiresAt
+	return nil
+}
+
+// 访问令牌是否已被加入黑名单
+func (s *MemoryTokenStore) IsAccessTokenRevoked(tokenID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expiresAt, ok := s.revoked[tokenID]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (s *MemoryTokenStore) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	token.ID = s.nextID
+	token.CreatedAt = time.Now()
+	s.accessTokens[token.ID] = *token
+	return nil
+}
+
+func (s *MemoryTokenStore) FindPersonalAccessToken(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, token := range s.accessTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryTokenStore) ListPersonalAccessTokens(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tokens []models.PersonalAccessToken
+	for _, token := range s.accessTokens {
+		if token.UserID == userID && token.IsActive() {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (s *MemoryTokenStore) RevokePersonalAccessToken(ctx context.Context, userID, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.accessTokens[id]
+	if !ok || token.UserID != userID || token.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	s.accessTokens[id] = token
+	return nil
+}
+
+func (s *MemoryTokenStore) TouchPersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.accessTokens[token.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	stored.LastUsedAt = &now
+	s.accessTokens[token.ID] = stored
+	token.LastUsedAt = &now
+	return nil
+}
+
+func (s *MemoryTokenStore) InvalidateUser(ctx context.Context, userID uint) error {
+	return nil
+}
+
+// 会话的当前状态，便于测试检查
+func (s *MemoryTokenStore) Sessions(userID uint) []models.Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
@@ -0,0 +1,434 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+
+	"gorm.io/gorm"
+)
+
+// 基于GORM的用户数据访问
+type userRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Preload("Roles").First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByLogin(ctx context.Context, login string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("username = ? OR email = ?", login, login).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	return r.exists(r.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username))
+}
+
+func (r *userRepository) EmailExists(ctx context.Context, email string, exceptID uint) (bool, error) {
+	query := r.db.WithContext(ctx).Model(&models.User{}).Where("email = ?", email)
+	if exceptID != 0 {
+		query = query.Where("id <> ?", exceptID)
+	}
+	return r.exists(query)
+}
+
+func (r *userRepository) RoleExists(ctx context.Context, role models.Role) (bool, error) {
+	return r.exists(r.db.WithContext(ctx).Model(&models.RoleDefinition{}).Where("name = ?", role))
+}
+
+func (r *userRepository) FindRoles(ctx context.Context, names []models.Role) ([]models.RoleDefinition, error) {
+	var roles []models.RoleDefinition
+	if len(names) == 0 {
+		return roles, nil
+	}
+	err := r.db.WithContext(ctx).Preload("Permissions").Where("name IN ?", names).Find(&roles).Error
+	return roles, err
+}
+
+func (r *userRepository) exists(query *gorm.DB) (bool, error) {
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *userRepository) Update(ctx context.Context, user *models.User, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(user).Updates(updates).Error
+}
+
+func (r *userRepository) Delete(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Delete(user).Error
+}
+
+func (r *userRepository) List(ctx context.Context, orgID uint, offset, limit int) ([]models.User, int64, error) {
+	// 只通过组织成员角色获得权限时只能看到当前组织的成员
+	scope := func(db *gorm.DB) *gorm.DB {
+		if orgID == 0 {
+			return db
+		}
+		return db.Where("id IN (?)", r.db.WithContext(ctx).Model(&models.Membership{}).Select("user_id").Where("organization_id = ?", orgID))
+	}
+
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&models.User{}).Scopes(scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	if err := r.db.WithContext(ctx).Preload("Roles").Scopes(scope).Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) LoadRoles(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Model(user).Association("Roles").Find(&user.Roles)
+}
+
+func (r *userRepository) SetRoles(ctx context.Context, user *models.User, roles []models.RoleDefinition) error {
+	association := r.db.WithContext(ctx).Model(user).Association("Roles")
+	var err error
+	if len(roles) == 0 {
+		err = association.Clear()
+	} else {
+		err = association.Replace(roles)
+	}
+	if err != nil {
+		return err
+	}
+	database.InvalidatePermissions()
+	return nil
+}
+
+func (r *userRepository) Permissions(ctx context.Context, user *models.User) ([]string, error) {
+	return database.UserPermissions(user)
+}
+
+func (r *userRepository) DefaultOrgID(ctx context.Context, userID uint) (uint, error) {
+	var membership models.Membership
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").First(&membership).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return membership.OrganizationID, nil
+}
+
+func (r *userRepository) OrgIDs(ctx context.Context, userID uint) ([]uint, error) {
+	var orgIDs []uint
+	err := r.db.WithContext(ctx).Model(&models.Membership{}).Where("user_id = ?", userID).Order("id").Pluck("organization_id", &orgIDs).Error
+	return orgIDs, err
+}
+
+func (r *userRepository) EnableTOTP(ctx context.Context, user *models.User, step int64, codes []models.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, user.ID, codes)
+	})
+}
+
+func (r *userRepository) DisableTOTP(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+func (r *userRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []models.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []models.RecoveryCode) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&codes).Error
+}
+
+func (r *userRepository) ClaimTOTPStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	// 条件更新保证同一时间窗口的验证码只能使用一次
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepository) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *userRepository) UsePasswordResetToken(ctx context.Context, tokenHash string, updates map[string]interface{}) (uint, error) {
+	var userID uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+			return translateError(err)
+		}
+
+		// 条件更新保证令牌只能使用一次
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		userID = token.UserID
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+	})
+	return userID, err
+}
+
+func (r *userRepository) WebAuthnCredentials(ctx context.Context, userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+	return credentials, err
+}
+
+func (r *userRepository) CreateWebAuthnCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+func (r *userRepository) UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&models.WebAuthnCredential{}).Where("credential_id = ?", credentialID).Updates(updates).Error
+}
+
+func (r *userRepository) DeleteWebAuthnCredential(ctx context.Context, userID, id uint) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// 会话、刷新令牌和个人访问令牌保存在数据库中，访问令牌黑名单和用户缓存保存在缓存中
+type tokenStore struct {
+	db *gorm.DB
+}
+
+func NewTokenStore(db *gorm.DB) TokenStore {
+	return &tokenStore{db: db}
+}
+
+func (s *tokenStore) CreateSession(ctx context.Context, session *models.Session) error {
+	return s.db.WithContext(ctx).Create(session).Error
+}
+
+func (s *tokenStore) FindSession(ctx context.Context, id string) (*models.Session, error) {
+	var session models.Session
+	if err := s.db.WithContext(ctx).First(&session, "id = ?", id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &session, nil
+}
+
+func (s *tokenStore) ListSessions(ctx context.Context, userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (s *tokenStore) UpdateSession(ctx context.Context, session *models.Session, updates map[string]interface{}) error {
+	return s.db.WithContext(ctx).Model(session).Updates(updates).Error
+}
+
+func (s *tokenStore) RevokeSession(ctx context.Context, id string) error {
+	now := time.Now()
+	err := s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", now).Error
+}
+
+func (s *tokenStore) RevokeUserSessions(ctx context.Context, userID uint, exceptID string) (int64, error) {
+	now := time.Now()
+
+	sessions := s.db.WithContext(ctx).Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	tokens := s.db.WithContext(ctx).Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptID != "" {
+		sessions = sessions.Where("id <> ?", exceptID)
+		tokens = tokens.Where("family_id <> ?", exceptID)
+	}
+
+	result := sessions.Update("revoked_at", now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if err := tokens.Update("revoked_at", now).Error; err != nil {
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
+
+func (s *tokenStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+func (s *tokenStore) FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (s *tokenStore) RotateRefreshToken(ctx context.Context, old, next *models.RefreshToken, session *models.Session) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发请求中只有一个能完成轮换
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", old.ID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(old).Update("replaced_by_id", next.ID).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(session).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"expires_at":   next.ExpiresAt,
+		}).Error
+	})
+}
+
+func (s *tokenStore) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	return database.RevokeToken(tokenID, expiresAt)
+}
+
+func (s *tokenStore) CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+func (s *tokenStore) FindPersonalAccessToken(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (s *tokenStore) ListPersonalAccessTokens(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (s *tokenStore) RevokePersonalAccessToken(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *tokenStore) TouchPersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error {
+	return s.db.WithContext(ctx).Model(token).Update("last_used_at", time.Now()).Error
+}
+
+func (s *tokenStore) InvalidateUser(ctx context.Context, userID uint) error {
+	if !database.CacheEnabled() {
+		return nil
+	}
+	return database.DeleteCache("user:" + strconv.Itoa(int(userID)))
+}
+
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gin-auth-project/models"
+
+	"gorm.io/gorm"
+)
+
+// 基于GORM的OAuth数据访问
+type oauthRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthRepository(db *gorm.DB) OAuthRepository {
+	return &oauthRepository{db: db}
+}
+
+func (r *oauthRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+func (r *oauthRepository) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.WithContext(ctx).Order("created_at").Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthRepository) FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.WithContext(ctx).Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &client, nil
+}
+
+func (r *oauthRepository) FindClientByID(ctx context.Context, id uint) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.WithContext(ctx).First(&client, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &client, nil
+}
+
+func (r *oauthRepository) DeleteClient(ctx context.Context, client *models.OAuthClient) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(client).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		sessionIDs := tx.Model(&models.Session{}).Select("id").Where("client_id = ?", client.ClientID)
+		err := tx.Model(&models.RefreshToken{}).
+			Where("family_id IN (?) AND revoked_at IS NULL", sessionIDs).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Session{}).
+			Where("client_id = ? AND revoked_at IS NULL", client.ClientID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("client_id = ?", client.ClientID).Delete(&models.OAuthConsent{}).Error
+	})
+}
+
+func (r *oauthRepository) CreateCode(ctx context.Context, code *models.OAuthAuthorizationCode) error {
+	return r.db.WithContext(ctx).Create(code).Error
+}
+
+func (r *oauthRepository) FindCode(ctx context.Context, codeHash string) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	if err := r.db.WithContext(ctx).Where("code_hash = ?", codeHash).First(&code).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &code, nil
+}
+
+func (r *oauthRepository) UseCode(ctx context.Context, code *models.OAuthAuthorizationCode) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.OAuthAuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", code.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	code.UsedAt = &now
+	return nil
+}
+
+func (r *oauthRepository) SetCodeGrantSession(ctx context.Context, code *models.OAuthAuthorizationCode, sessionID string) error {
+	return r.db.WithContext(ctx).Model(code).Update("grant_session_id", sessionID).Error
+}
+
+func (r *oauthRepository) FindConsent(ctx context.Context, userID uint, clientID string) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &consent, nil
+}
+
+func (r *oauthRepository) SaveConsent(ctx context.Context, consent *models.OAuthConsent) error {
+	return r.db.WithContext(ctx).Save(consent).Error
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+
+	"gorm.io/gorm"
+)
+
+// 基于GORM的组织数据访问，成员变更后使权限缓存失效
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (r *organizationRepository) List(ctx context.Context) ([]models.Organization, error) {
+	var orgs []models.Organization
+	err := r.db.WithContext(ctx).Order("id").Find(&orgs).Error
+	return orgs, err
+}
+
+func (r *organizationRepository) FindByID(ctx context.Context, id uint) (*models.Organization, error) {
+	var org models.Organization
+	if err := r.db.WithContext(ctx).First(&org, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &org, nil
+}
+
+func (r *organizationRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Organization, error) {
+	var orgs []models.Organization
+	if len(ids) == 0 {
+		return orgs, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&orgs).Error
+	return orgs, err
+}
+
+func (r *organizationRepository) SlugExists(ctx context.Context, slug string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Organization{}).Where("slug = ?", slug).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	return r.db.WithContext(ctx).Create(org).Error
+}
+
+func (r *organizationRepository) Delete(ctx context.Context, org *models.Organization) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", org.ID).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", org.ID).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Session{}).Where("org_id = ?", org.ID).Update("org_id", 0).Error; err != nil {
+			return err
+		}
+		return tx.Delete(org).Error
+	})
+	if err != nil {
+		return err
+	}
+	database.InvalidatePermissions()
+	return nil
+}
+
+func (r *organizationRepository) UserMemberships(ctx context.Context, userID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&memberships).Error
+	return memberships, err
+}
+
+func (r *organizationRepository) Members(ctx context.Context, orgID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.db.WithContext(ctx).Preload("User").Where("organization_id = ?", orgID).Order("id").Find(&memberships).Error
+	return memberships, err
+}
+
+func (r *organizationRepository) FindMembership(ctx context.Context, orgID, userID uint) (*models.Membership, error) {
+	var membership models.Membership
+	if err := r.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", orgID, userID).First(&membership).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &membership, nil
+}
+
+func (r *organizationRepository) UpdateMemberRole(ctx context.Context, membership *models.Membership, role models.Role) error {
+	if err := r.db.WithContext(ctx).Model(membership).Update("role", role).Error; err != nil {
+		return err
+	}
+	database.InvalidatePermissions()
+	return nil
+}
+
+func (r *organizationRepository) RemoveMember(ctx context.Context, membership *models.Membership) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(membership).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).
+			Where("user_id = ? AND org_id = ?", membership.UserID, membership.OrganizationID).
+			Update("org_id", 0).Error
+	})
+	if err != nil {
+		return err
+	}
+	database.InvalidatePermissions()
+	return nil
+}
+
+func (r *organizationRepository) CountMembersWithRoles(ctx context.Context, orgID, exceptUserID uint, roles []models.Role) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Membership{}).
+		Where("organization_id = ? AND user_id <> ? AND role IN ?", orgID, exceptUserID, roles).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *organizationRepository) PendingInvitations(ctx context.Context, orgID uint) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", orgID, time.Now()).
+		Order("id").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *organizationRepository) CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error {
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, tokenHash, email string, userID uint) (*models.Membership, error) {
+	var membership models.Membership
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation models.OrganizationInvitation
+		if err := tx.Where("token_hash = ?", tokenHash).First(&invitation).Error; err != nil {
+			return translateError(err)
+		}
+		if !strings.EqualFold(invitation.Email, email) {
+			return ErrNotFound
+		}
+
+		// 条件更新保证邀请只能使用一次
+		now := time.Now()
+		result := tx.Model(&models.OrganizationInvitation{}).
+			Where("id = ? AND accepted_at IS NULL AND expires_at > ?", invitation.ID, now).
+			Update("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		// 已经是成员时按邀请更新角色
+		err := tx.Where("organization_id = ? AND user_id = ?", invitation.OrganizationID, userID).First(&membership).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			membership = models.Membership{
+				OrganizationID: invitation.OrganizationID,
+				UserID:         userID,
+				Role:           invitation.Role,
+			}
+			return tx.Create(&membership).Error
+		}
+		if err != nil {
+			return err
+		}
+		membership.Role = invitation.Role
+		return tx.Save(&membership).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	database.InvalidatePermissions()
+	return &membership, nil
+}
+
+func (r *organizationRepository) DeleteInvitation(ctx context.Context, orgID, id uint) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND organization_id = ? AND accepted_at IS NULL", id, orgID).
+		Delete(&models.OrganizationInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+
+	"gorm.io/gorm"
+)
+
+// 基于GORM的角色数据访问，修改角色后使权限缓存失效
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) List(ctx context.Context) ([]models.RoleDefinition, error) {
+	var roles []models.RoleDefinition
+	err := r.db.WithContext(ctx).Preload("Permissions").Order("id").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) FindByID(ctx context.Context, id uint) (*models.RoleDefinition, error) {
+	var role models.RoleDefinition
+	if err := r.db.WithContext(ctx).First(&role, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) FindByName(ctx context.Context, name models.Role) (*models.RoleDefinition, error) {
+	var role models.RoleDefinition
+	if err := r.db.WithContext(ctx).Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) NameExists(ctx context.Context, name models.Role) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RoleDefinition{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *roleRepository) FindPermissions(ctx context.Context, names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	err := r.db.WithContext(ctx).Where("name IN ?", names).Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *models.RoleDefinition) error {
+	return r.db.WithContext(ctx).Create(role).Error
+}
+
+func (r *roleRepository) Update(ctx context.Context, role *models.RoleDefinition, updates map[string]interface{}, permissions []models.Permission) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(role).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if permissions == nil {
+			return nil
+		}
+		if len(permissions) == 0 {
+			return tx.Model(role).Association("Permissions").Clear()
+		}
+		return tx.Model(role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return err
+	}
+	database.InvalidatePermissions()
+
+	return r.db.WithContext(ctx).Preload("Permissions").First(role, role.ID).Error
+}
+
+func (r *roleRepository) CountPrimaryUsers(ctx context.Context, name models.Role) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", name).Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) Delete(ctx context.Context, role *models.RoleDefinition) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_roles WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		return tx.Select("Permissions").Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+	database.InvalidatePermissions()
+	return nil
+}
+
+func (r *roleRepository) NamesWithPermission(ctx context.Context, permission string) ([]models.Role, error) {
+	var names []models.Role
+	err := r.db.WithContext(ctx).Model(&models.RoleDefinition{}).
+		Joins("JOIN role_permissions ON role_permissions.role_id = roles.id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("permissions.name = ?", permission).
+		Pluck("roles.name", &names).Error
+	return names, err
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gin-auth-project/models"
+)
+
+var (
+	// 记录不存在
+	ErrNotFound = errors.New("record not found")
+	// 条件更新未生效（例如并发请求已经修改了记录）
+	ErrConflict = errors.New("record was modified concurrently")
+)
+
+// 用户数据访问
+type UserRepository interface {
+	// 按ID查找用户，同时加载附加角色
+	FindByID(ctx context.Context, id uint) (*models.User, error)
+	// 按用户名或邮箱查找用户
+	FindByLogin(ctx context.Context, login string) (*models.User, error)
+	// 按邮箱查找用户，不区分大小写
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// 用户名是否已被使用
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	// 邮箱是否已被exceptID以外的用户使用
+	EmailExists(ctx context.Context, email string, exceptID uint) (bool, error)
+	// 角色是否已定义
+	RoleExists(ctx context.Context, role models.Role) (bool, error)
+	// 按名称加载角色及其权限，未定义的角色不返回
+	FindRoles(ctx context.Context, names []models.Role) ([]models.RoleDefinition, error)
+	Create(ctx context.Context, user *models.User) error
+	// 更新指定字段，并同步到user
+	Update(ctx context.Context, user *models.User, updates map[string]interface{}) error
+	Delete(ctx context.Context, user *models.User) error
+	// 分页查询用户，orgID不为0时只查询该组织的成员
+	List(ctx context.Context, orgID uint, offset, limit int) ([]models.User, int64, error)
+	// 加载用户的附加角色
+	LoadRoles(ctx context.Context, user *models.User) error
+	// 整体替换用户的附加角色
+	SetRoles(ctx context.Context, user *models.User, roles []models.RoleDefinition) error
+	// 用户的全部权限
+	Permissions(ctx context.Context, user *models.User) ([]string, error)
+	// 用户最早加入的组织，没有时返回0
+	DefaultOrgID(ctx context.Context, userID uint) (uint, error)
+	// 用户加入的全部组织，按加入顺序排列
+	OrgIDs(ctx context.Context, userID uint) ([]uint, error)
+
+	// 启用TOTP并替换恢复码
+	EnableTOTP(ctx context.Context, user *models.User, step int64, codes []models.RecoveryCode) error
+	// 关闭TOTP并删除恢复码
+	DisableTOTP(ctx context.Context, user *models.User) error
+	// 替换用户的全部恢复码
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []models.RecoveryCode) error
+	// 记录已使用的TOTP时间窗口，不晚于上次使用的窗口时返回false
+	ClaimTOTPStep(ctx context.Context, userID uint, step int64) (bool, error)
+	// 使用一个恢复码，不存在或已使用时返回false
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+
+	// 作废用户未使用的重置令牌并保存新令牌
+	CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	// 使用重置令牌并更新其用户，令牌不存在、已使用或已过期时返回ErrNotFound
+	UsePasswordResetToken(ctx context.Context, tokenHash string, updates map[string]interface{}) (uint, error)
+
+	// 用户的通行密钥，按创建时间排列
+	WebAuthnCredentials(ctx context.Context, userID uint) ([]models.WebAuthnCredential, error)
+	CreateWebAuthnCredential(ctx context.Context, credential *models.WebAuthnCredential) error
+	// 更新通行密钥字段，credentialID为认证器返回的凭证ID
+	UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, updates map[string]interface{}) error
+	// 删除用户的通行密钥，不存在时返回ErrNotFound
+	DeleteWebAuthnCredential(ctx context.Context, userID, id uint) error
+}
+
+// 会话、刷新令牌、个人访问令牌和访问令牌黑名单
+type TokenStore interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	FindSession(ctx context.Context, id string) (*models.Session, error)
+	// 用户未吊销且未过期的会话，最近活动的在前
+	ListSessions(ctx context.Context, userID uint) ([]models.Session, error)
+	// 更新会话字段，并同步到session
+	UpdateSession(ctx context.Context, session *models.Session, updates map[string]interface{}) error
+	// 吊销会话及其刷新令牌族
+	RevokeSession(ctx context.Context, id string) error
+	// 吊销用户的全部会话和刷新令牌，exceptID不为空时保留该会话，返回吊销的会话数量
+	RevokeUserSessions(ctx context.Context, userID uint, exceptID string) (int64, error)
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// 作废旧刷新令牌并保存新令牌，会话随之续期；旧令牌已被作废时返回ErrConflict
+	RotateRefreshToken(ctx context.Context, old, next *models.RefreshToken, session *models.Session) error
+	// 将访问令牌加入黑名单，直到令牌过期
+	RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+
+	CreatePersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error
+	FindPersonalAccessToken(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	// 用户未吊销且未过期的个人访问令牌，最近创建的在前
+	ListPersonalAccessTokens(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error)
+	// 吊销用户的个人访问令牌，不存在或已吊销时返回ErrNotFound
+	RevokePersonalAccessToken(ctx context.Context, userID, id uint) error
+	// 记录个人访问令牌的最近使用时间
+	TouchPersonalAccessToken(ctx context.Context, token *models.PersonalAccessToken) error
+	// 清除缓存的用户信息
+	InvalidateUser(ctx context.Context, userID uint) error
+}
+
+// 角色和权限
+type RoleRepository interface {
+	// 全部角色及其权限，按ID排列
+	List(ctx context.Context) ([]models.RoleDefinition, error)
+	FindByID(ctx context.Context, id uint) (*models.RoleDefinition, error)
+	// 按名称查找角色，同时加载权限
+	FindByName(ctx context.Context, name models.Role) (*models.RoleDefinition, error)
+	NameExists(ctx context.Context, name models.Role) (bool, error)
+	// 按名称加载权限，未定义的权限不返回
+	FindPermissions(ctx context.Context, names []string) ([]models.Permission, error)
+	Create(ctx context.Context, role *models.RoleDefinition) error
+	// 更新角色字段，permissions不为nil时整体替换角色的权限，完成后重新加载角色
+	Update(ctx context.Context, role *models.RoleDefinition, updates map[string]interface{}, permissions []models.Permission) error
+	// 以该角色为主角色的用户数量
+	CountPrimaryUsers(ctx context.Context, name models.Role) (int64, error)
+	// 删除角色及其权限和用户关联
+	Delete(ctx context.Context, role *models.RoleDefinition) error
+	// 拥有指定权限的角色名称
+	NamesWithPermission(ctx context.Context, permission string) ([]models.Role, error)
+}
+
+// 组织、成员和邀请
+type OrganizationRepository interface {
+	// 全部组织，按ID排列
+	List(ctx context.Context) ([]models.Organization, error)
+	FindByID(ctx context.Context, id uint) (*models.Organization, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Organization, error)
+	SlugExists(ctx context.Context, slug string) (bool, error)
+	Create(ctx context.Context, org *models.Organization) error
+	// 删除组织及其成员和邀请，选择该组织的会话回到未选择组织的状态
+	Delete(ctx context.Context, org *models.Organization) error
+
+	// 用户的全部成员身份，按加入顺序排列
+	UserMemberships(ctx context.Context, userID uint) ([]models.Membership, error)
+	// 组织的全部成员，同时加载用户
+	Members(ctx context.Context, orgID uint) ([]models.Membership, error)
+	FindMembership(ctx context.Context, orgID, userID uint) (*models.Membership, error)
+	UpdateMemberRole(ctx context.Context, membership *models.Membership, role models.Role) error
+	// 移除成员，该成员选择此组织的会话回到未选择组织的状态
+	RemoveMember(ctx context.Context, membership *models.Membership) error
+	// 组织中除exceptUserID以外拥有指定角色之一的成员数量
+	CountMembersWithRoles(ctx context.Context, orgID, exceptUserID uint, roles []models.Role) (int64, error)
+
+	// 组织未接受且未过期的邀请
+	PendingInvitations(ctx context.Context, orgID uint) ([]models.OrganizationInvitation, error)
+	CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error
+	// 接受邀请并加入组织（已是成员时按邀请更新角色）
+	// 邀请不存在、邮箱不一致、已接受或已过期时返回ErrNotFound
+	AcceptInvitation(ctx context.Context, tokenHash, email string, userID uint) (*models.Membership, error)
+	// 删除组织未接受的邀请，不存在时返回ErrNotFound
+	DeleteInvitation(ctx context.Context, orgID, id uint) error
+}
+
+// OAuth客户端、授权码和用户授权记录
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *models.OAuthClient) error
+	// 全部客户端，按创建时间排列
+	ListClients(ctx context.Context) ([]models.OAuthClient, error)
+	FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	FindClientByID(ctx context.Context, id uint) (*models.OAuthClient, error)
+	// 删除客户端，同时吊销其会话、刷新令牌和授权记录
+	DeleteClient(ctx context.Context, client *models.OAuthClient) error
+
+	CreateCode(ctx context.Context, code *models.OAuthAuthorizationCode) error
+	FindCode(ctx context.Context, codeHash string) (*models.OAuthAuthorizationCode, error)
+	// 标记授权码已使用，已被使用时返回ErrConflict
+	UseCode(ctx context.Context, code *models.OAuthAuthorizationCode) error
+	// 记录用授权码创建的会话，授权码被重复使用时吊销
+	SetCodeGrantSession(ctx context.Context, code *models.OAuthAuthorizationCode, sessionID string) error
+
+	FindConsent(ctx context.Context, userID uint, clientID string) (*models.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent *models.OAuthConsent) error
+}
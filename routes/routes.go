@@ -1,58 +1,225 @@
 package routes
 
 import (
+	"log/slog"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
 	"gin-auth-project/handlers"
+	"gin-auth-project/metrics"
 	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/services"
+	"gin-auth-project/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes() *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+
+	// 只信任配置的反向代理设置的X-Forwarded-For，否则客户端IP可以被伪造，绕过按IP的限流和登录锁定
+	// 配置在加载时已校验，这里不会失败
+	if err := r.SetTrustedProxies(config.Current().TrustedProxies); err != nil {
+		slog.Error("Invalid trusted proxies", "error", err)
+	}
 
-	// 添加CORS中间件
-	r.Use(middleware.CORSMiddleware())
+	// 请求ID、链路追踪、结构化访问日志和panic恢复
+	r.Use(middleware.RequestIDMiddleware(), tracing.Middleware(), middleware.RequestLoggerMiddleware(), middleware.RecoveryMiddleware())
+
+	// Prometheus指标：按路由模板统计请求耗时
+	if config.Current().MetricsEnabled {
+		r.Use(metrics.Middleware())
+		r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
 
-	// 健康检查
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok", "message": "Server is running"})
-	})
+	// 数据访问和业务逻辑
+	userRepo := repository.NewUserRepository(database.DB)
+	tokenStore := repository.NewTokenStore(database.DB)
+	roleRepo := repository.NewRoleRepository(database.DB)
+	userService := services.NewUserService(userRepo, tokenStore)
+	authService := services.NewAuthService(userRepo, tokenStore)
+	roleService := services.NewRoleService(roleRepo)
+	orgService := services.NewOrganizationService(repository.NewOrganizationRepository(database.DB), roleRepo)
+	oauthService := services.NewOAuthService(repository.NewOAuthRepository(database.DB))
+
+	authHandler := handlers.NewAuthHandler(authService, userService)
+	userHandler := handlers.NewUserHandler(userService)
+	sessionHandler := handlers.NewSessionHandler(authService, userService)
+	oauthHandler := handlers.NewOAuthHandler(authService, userService, oauthService)
+	accessTokenHandler := handlers.NewAccessTokenHandler(authService, userService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	orgHandler := handlers.NewOrganizationHandler(orgService, roleService, userService, authService)
+	healthHandler := &handlers.HealthHandler{}
+
+	// 添加CORS中间件，之后注册的路由为处理器记录单独的span
+	r.Use(middleware.CORSMiddleware(), tracing.HandlerMiddleware())
+
+	// 限流：未认证的敏感接口按IP单独计数，已认证的接口按用户或个人访问令牌计数
+	// 每次请求时读取当前配置，重新加载后立即生效
+	authPerMinute := func() int { return config.Current().RateLimitAuthPerMinute }
+	apiPerMinute := func() int { return config.Current().RateLimitAPIPerMinute }
+	authLimit := func(name string) gin.HandlerFunc {
+		return middleware.RateLimitMiddleware(name, authPerMinute, time.Minute, middleware.KeyByIP)
+	}
+	apiLimit := middleware.RateLimitMiddleware("api", apiPerMinute, time.Minute, middleware.KeyByAPIKey)
+
+	// 存活和就绪检查，/health保留为存活检查的别名
+	r.GET("/livez", healthHandler.Live)
+	r.GET("/readyz", healthHandler.Ready)
+	r.GET("/health", healthHandler.Live)
+
+	// 签名公钥（JWKS），供其他服务验证令牌
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// OpenID Connect 身份提供方
+	r.GET("/.well-known/openid-configuration", oauthHandler.Discovery)
+	oauth := r.Group("/oauth")
+	{
+		oauth.GET("/authorize", oauthHandler.Authorize)
+		oauth.POST("/authorize", authLimit("oauth_authorize"), oauthHandler.AuthorizeSubmit)
+		oauth.POST("/token", authLimit("oauth_token"), oauthHandler.Token)
+		oauth.GET("/userinfo", middleware.OAuthMiddleware(authService), oauthHandler.UserInfo)
+		oauth.POST("/userinfo", middleware.OAuthMiddleware(authService), oauthHandler.UserInfo)
+	}
 
 	// 认证相关路由（无需认证）
 	auth := r.Group("/api/auth")
 	{
-		auth.POST("/login", handlers.AuthHandler{}.Login)
-		auth.POST("/register", handlers.AuthHandler{}.Register)
+		auth.POST("/login", authLimit("login"), authHandler.Login)
+		auth.POST("/register", authLimit("register"), authHandler.Register)
+		auth.POST("/refresh", authLimit("refresh"), authHandler.RefreshToken)
+
+		// 邮箱验证
+		auth.GET("/verify-email", authHandler.VerifyEmail)
+		auth.POST("/verify-email", authHandler.VerifyEmail)
+		auth.POST("/verify-email/resend", authLimit("verify_email_resend"), authHandler.ResendVerificationEmail)
+
+		// 找回密码
+		auth.POST("/password/forgot", authLimit("password_forgot"), authHandler.ForgotPassword)
+		auth.POST("/password/reset", authLimit("password_reset"), authHandler.ResetPassword)
+
+		// 登录第二步，只接受待验证令牌
+		auth.POST("/2fa/verify", authLimit("2fa_verify"), middleware.MFAPendingMiddleware(authService), authHandler.VerifyMFA)
+
+		// 通行密钥登录
+		auth.POST("/webauthn/login/begin", authLimit("webauthn_login"), authHandler.BeginWebAuthnLogin)
+		auth.POST("/webauthn/login/finish", authLimit("webauthn_login"), authHandler.FinishWebAuthnLogin)
 	}
 
 	// 需要认证的路由
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.AuthMiddleware(authService), apiLimit)
 	{
 		// 用户认证相关
 		auth := api.Group("/auth")
 		{
-			auth.POST("/logout", handlers.AuthHandler{}.Logout)
-			auth.GET("/profile", handlers.AuthHandler{}.GetProfile)
-			auth.PUT("/profile", handlers.AuthHandler{}.UpdateProfile)
-			auth.POST("/refresh", handlers.AuthHandler{}.RefreshToken)
+			auth.POST("/logout", authHandler.Logout)
+			auth.GET("/profile", authHandler.GetProfile)
+			auth.PUT("/profile", authHandler.UpdateProfile)
+
+			// 两步验证（TOTP），不能使用个人访问令牌
+			mfa := auth.Group("/2fa", middleware.InteractiveMiddleware())
+			{
+				mfa.POST("/setup", authHandler.SetupTOTP)
+				mfa.POST("/confirm", authHandler.ConfirmTOTP)
+				mfa.POST("/disable", authHandler.DisableTOTP)
+				mfa.POST("/recovery-codes", authHandler.RegenerateRecoveryCodes)
+			}
+
+			// 通行密钥（WebAuthn）管理，不能使用个人访问令牌
+			passkeys := auth.Group("/webauthn", middleware.InteractiveMiddleware())
+			{
+				passkeys.POST("/register/begin", authHandler.BeginWebAuthnRegistration)
+				passkeys.POST("/register/finish", authHandler.FinishWebAuthnRegistration)
+				passkeys.GET("/credentials", authHandler.ListWebAuthnCredentials)
+				passkeys.DELETE("/credentials/:id", authHandler.DeleteWebAuthnCredential)
+			}
+
+			// 个人访问令牌管理，不能使用个人访问令牌
+			tokens := auth.Group("/tokens", middleware.InteractiveMiddleware())
+			{
+				tokens.GET("", accessTokenHandler.ListMyTokens)
+				tokens.POST("", accessTokenHandler.CreateMyToken)
+				tokens.DELETE("/:tid", accessTokenHandler.RevokeMyToken)
+			}
+
+			// 当前用户的组织，切换组织和接受邀请不能使用个人访问令牌
+			auth.GET("/orgs", orgHandler.ListMyOrganizations)
+			auth.POST("/orgs/switch", middleware.InteractiveMiddleware(), orgHandler.SwitchOrganization)
+			auth.POST("/orgs/invitations/accept", middleware.InteractiveMiddleware(), orgHandler.AcceptInvitation)
+
+			// 登录会话管理，不能使用个人访问令牌
+			sessions := auth.Group("/sessions", middleware.InteractiveMiddleware())
+			{
+				sessions.GET("", sessionHandler.ListMySessions)
+				sessions.DELETE("", sessionHandler.RevokeMyOtherSessions)
+				sessions.DELETE("/:sid", sessionHandler.RevokeMySession)
+			}
 		}
 
-		// 用户管理（需要管理员权限）
+		// 用户管理（按权限控制）
 		users := api.Group("/users")
-		users.Use(middleware.AdminMiddleware())
 		{
-			users.GET("", handlers.UserHandler{}.GetAllUsers)
-			users.POST("", handlers.UserHandler{}.CreateUser)
-			users.GET("/:id", handlers.UserHandler{}.GetUserByID)
-			users.PUT("/:id", handlers.UserHandler{}.UpdateUser)
-			users.DELETE("/:id", handlers.UserHandler{}.DeleteUser)
-			users.PATCH("/:id/status", handlers.UserHandler{}.ToggleUserStatus)
+			users.GET("", middleware.RequirePermission(models.PermissionUsersRead), userHandler.GetAllUsers)
+			users.POST("", middleware.RequirePermission(models.PermissionUsersCreate), userHandler.CreateUser)
+			users.GET("/:id", middleware.RequirePermission(models.PermissionUsersRead), userHandler.GetUserByID)
+			users.PUT("/:id", middleware.RequirePermission(models.PermissionUsersUpdate), userHandler.UpdateUser)
+			users.DELETE("/:id", middleware.RequirePermission(models.PermissionUsersDelete), userHandler.DeleteUser)
+			users.PATCH("/:id/status", middleware.RequirePermission(models.PermissionUsersDeactivate), userHandler.ToggleUserStatus)
+			users.POST("/:id/unlock", middleware.RequirePermission(models.PermissionUsersDeactivate), userHandler.UnlockUser)
+			users.PUT("/:id/roles", middleware.RequirePermission(models.PermissionUsersAssignRoles), userHandler.SetUserRoles)
+
+			manageSessions := middleware.RequirePermission(models.PermissionUsersManageSessions)
+			users.GET("/:id/sessions", manageSessions, sessionHandler.ListUserSessions)
+			users.DELETE("/:id/sessions", manageSessions, sessionHandler.RevokeAllUserSessions)
+			users.DELETE("/:id/sessions/:sid", manageSessions, sessionHandler.RevokeUserSession)
+			users.GET("/:id/tokens", manageSessions, accessTokenHandler.ListUserTokens)
+			users.DELETE("/:id/tokens/:tid", manageSessions, accessTokenHandler.RevokeUserToken)
+		}
+
+		// 角色和权限管理
+		api.GET("/permissions", middleware.RequirePermission(models.PermissionRolesManage), roleHandler.ListPermissions)
+		roles := api.Group("/roles")
+		roles.Use(middleware.RequirePermission(models.PermissionRolesManage))
+		{
+			roles.GET("", roleHandler.ListRoles)
+			roles.POST("", roleHandler.CreateRole)
+			roles.PUT("/:id", roleHandler.UpdateRole)
+			roles.DELETE("/:id", roleHandler.DeleteRole)
+		}
+
+		// 组织管理，组织管理员只能管理当前选择的组织
+		orgs := api.Group("/orgs")
+		{
+			manageOrgs := middleware.RequirePermission(models.PermissionOrgsManage)
+			orgs.GET("", manageOrgs, orgHandler.ListOrganizations)
+			orgs.POST("", manageOrgs, orgHandler.CreateOrganization)
+			orgs.DELETE("/:id", manageOrgs, orgHandler.DeleteOrganization)
+
+			manageMembers := middleware.RequirePermission(models.PermissionOrgMembersManage)
+			orgs.GET("/:id/members", manageMembers, orgHandler.ListMembers)
+			orgs.PUT("/:id/members/:uid", manageMembers, orgHandler.UpdateMember)
+			orgs.DELETE("/:id/members/:uid", manageMembers, orgHandler.RemoveMember)
+			orgs.GET("/:id/invitations", manageMembers, orgHandler.ListInvitations)
+			orgs.POST("/:id/invitations", manageMembers, orgHandler.CreateInvitation)
+			orgs.DELETE("/:id/invitations/:iid", manageMembers, orgHandler.RevokeInvitation)
+		}
+
+		// OAuth客户端管理
+		clients := api.Group("/oauth/clients")
+		clients.Use(middleware.RequirePermission(models.PermissionOAuthClientsManage))
+		{
+			clients.GET("", oauthHandler.ListClients)
+			clients.POST("", oauthHandler.CreateClient)
+			clients.DELETE("/:id", oauthHandler.DeleteClient)
 		}
 
-		// 受保护的资源路由（需要用户权限）
+		// 受保护的资源路由
 		protected := api.Group("/protected")
-		protected.Use(middleware.UserMiddleware())
+		protected.Use(middleware.RequirePermission(models.PermissionProtectedRead))
 		{
 			protected.GET("/data", func(c *gin.Context) {
 				c.JSON(200, gin.H{
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gin-auth-project/events"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+)
+
+var (
+	ErrInvalidAccessToken  = errors.New("invalid or expired access token")
+	ErrAccessTokenNotFound = errors.New("access token not found")
+)
+
+// 个人访问令牌最近使用时间的更新间隔
+const accessTokenTouchInterval = time.Minute
+
+// 新个人访问令牌的信息
+type CreateAccessTokenInput struct {
+	Name        string
+	Scopes      []string
+	AuthMethods []string // 创建令牌时所在会话的认证方式
+	OrgID       uint     // 令牌代表的组织，0表示未选择组织
+	ExpiresAt   time.Time
+}
+
+// 创建个人访问令牌，返回令牌记录和明文令牌（明文只在创建时出现一次）
+func (s *AuthService) CreateAccessToken(ctx context.Context, user *models.User, input CreateAccessTokenInput) (*models.PersonalAccessToken, string, error) {
+	raw, err := utils.GenerateAccessToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &models.PersonalAccessToken{
+		UserID:      user.ID,
+		Name:        input.Name,
+		TokenPrefix: raw[:len(utils.AccessTokenPrefix)+8],
+		TokenHash:   utils.HashToken(raw),
+		Scopes:      strings.Join(input.Scopes, " "),
+		AuthMethods: strings.Join(input.AuthMethods, ","),
+		OrgID:       input.OrgID,
+		ExpiresAt:   input.ExpiresAt,
+	}
+	if err := s.tokens.CreatePersonalAccessToken(ctx, token); err != nil {
+		return nil, "", err
+	}
+	events.Publish(events.Event{Type: events.TokenIssued, Token: events.TokenPersonal})
+	return token, raw, nil
+}
+
+// 用户的有效个人访问令牌
+func (s *AuthService) AccessTokens(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	return s.tokens.ListPersonalAccessTokens(ctx, userID)
+}
+
+// 吊销用户的个人访问令牌
+func (s *AuthService) RevokePersonalAccessToken(ctx context.Context, userID, tokenID uint) error {
+	if err := s.tokens.RevokePersonalAccessToken(ctx, userID, tokenID); err != nil {
+		return notFoundAs(err, ErrAccessTokenNotFound)
+	}
+	events.Publish(events.Event{Type: events.TokenRevoked, Token: events.TokenPersonal})
+	return nil
+}
+
+// 按明文查找有效的个人访问令牌
+func (s *AuthService) FindAccessToken(ctx context.Context, raw string) (*models.PersonalAccessToken, error) {
+	token, err := s.tokens.FindPersonalAccessToken(ctx, utils.HashToken(raw))
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidAccessToken)
+	}
+	if !token.IsActive() {
+		return nil, ErrInvalidAccessToken
+	}
+	return token, nil
+}
+
+// 按间隔更新个人访问令牌的最近使用时间，避免每个请求都写数据库
+func (s *AuthService) TouchAccessToken(ctx context.Context, token *models.PersonalAccessToken) {
+	if token.LastUsedAt != nil && time.Since(*token.LastUsedAt) <= accessTokenTouchInterval {
+		return
+	}
+	if err := s.tokens.TouchPersonalAccessToken(ctx, token); err != nil {
+		slog.WarnContext(ctx, "Failed to update access token usage", "token_id", token.ID, "error", err)
+	}
+}
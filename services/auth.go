@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/events"
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/utils"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrUserDeactivated     = errors.New("user account is deactivated")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// 认证：注册、校验密码、会话和令牌的签发与吊销
+type AuthService struct {
+	users  *UserService
+	repo   repository.UserRepository
+	tokens repository.TokenStore
+}
+
+func NewAuthService(users repository.UserRepository, tokens repository.TokenStore) *AuthService {
+	return &AuthService{
+		users:  NewUserService(users, tokens),
+		repo:   users,
+		tokens: tokens,
+	}
+}
+
+// 访问令牌和刷新令牌
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// 创建会话时的客户端信息
+type SessionInfo struct {
+	Device      string
+	IP          string
+	UserAgent   string
+	AuthMethods []string
+}
+
+// 注册普通用户
+func (s *AuthService) Register(ctx context.Context, username, email, password string) (*models.User, error) {
+	user, err := s.users.Create(ctx, CreateUserInput{Username: username, Email: email, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	events.Publish(events.Event{Type: events.UserRegistered})
+	return user, nil
+}
+
+// 用户不存在时用于比较的固定哈希，使响应时间与密码错误时一致，无法据此判断账号是否存在
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := utils.HashPassword("dummy-password")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// 校验密码，user为nil（用户不存在）时同样返回ErrInvalidCredentials
+func (s *AuthService) Authenticate(user *models.User, password string) error {
+	if user == nil {
+		utils.CheckPassword(password, dummyPasswordHash())
+		return ErrInvalidCredentials
+	}
+	if !utils.CheckPassword(password, user.Password) {
+		return ErrInvalidCredentials
+	}
+	if !user.IsActive {
+		return ErrUserDeactivated
+	}
+	return nil
+}
+
+// 创建登录会话，customize可以在保存前补充会话字段
+func (s *AuthService) StartSession(ctx context.Context, user *models.User, info SessionInfo, customize func(*models.Session)) (*models.Session, error) {
+	sessionID, err := utils.GenerateID()
+	if err != nil {
+		return nil, err
+	}
+
+	// 新会话默认选择用户最早加入的组织
+	orgID, err := s.repo.DefaultOrgID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:          sessionID,
+		UserID:      user.ID,
+		Device:      info.Device,
+		IP:          info.IP,
+		UserAgent:   info.UserAgent,
+		AuthMethods: strings.Join(info.AuthMethods, ","),
+		OrgID:       orgID,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(refreshTokenTTL()),
+	}
+	if customize != nil {
+		customize(session)
+	}
+
+	if err := s.tokens.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// 为登录会话签发访问令牌和刷新令牌
+func (s *AuthService) IssueTokens(ctx context.Context, user *models.User, session *models.Session) (*TokenPair, error) {
+	accessToken, err := utils.GenerateToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.newRefreshToken(user.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokens.CreateRefreshToken(ctx, &refreshToken.RefreshToken); err != nil {
+		return nil, err
+	}
+	events.Publish(events.Event{Type: events.TokenIssued, Token: events.TokenRefresh})
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken.raw,
+		ExpiresIn:    accessTokenExpiresIn(),
+	}, nil
+}
+
+// 轮换刷新令牌：旧令牌作废并签发同一令牌族中的新令牌
+// 已轮换过的令牌再次出现时视为泄露，吊销整个令牌族及其会话
+func (s *AuthService) RefreshTokens(ctx context.Context, raw string) (*models.User, *models.Session, *TokenPair, error) {
+	stored, err := s.tokens.FindRefreshToken(ctx, utils.HashToken(raw))
+	if err != nil {
+		return nil, nil, nil, notFoundAs(err, ErrInvalidRefreshToken)
+	}
+
+	// 只有已轮换的令牌再次出现才是重用；登出或吊销会话后作废的令牌只是无效
+	if stored.RevokedAt != nil {
+		if stored.ReplacedByID == nil {
+			return nil, nil, nil, ErrInvalidRefreshToken
+		}
+		s.RevokeSession(ctx, stored.FamilyID)
+		return nil, nil, nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, nil, nil, ErrRefreshTokenExpired
+	}
+
+	session, err := s.tokens.FindSession(ctx, stored.FamilyID)
+	if err != nil || !session.IsActive() {
+		return nil, nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.FindByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, nil, nil, ErrInvalidRefreshToken
+	}
+	if !user.IsActive {
+		return nil, nil, nil, ErrUserDeactivated
+	}
+
+	next, err := s.newRefreshToken(stored.UserID, stored.FamilyID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 会话随刷新令牌一起续期
+	if err := s.tokens.RotateRefreshToken(ctx, stored, &next.RefreshToken, session); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			s.RevokeSession(ctx, stored.FamilyID)
+			return nil, nil, nil, ErrRefreshTokenReused
+		}
+		return nil, nil, nil, err
+	}
+	events.Publish(events.Event{Type: events.TokenIssued, Token: events.TokenRefresh})
+
+	accessToken, err := utils.GenerateToken(user, session)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return user, session, &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: next.raw,
+		ExpiresIn:    accessTokenExpiresIn(),
+	}, nil
+}
+
+// 吊销会话及其刷新令牌族
+func (s *AuthService) RevokeSession(ctx context.Context, sessionID string) error {
+	if err := s.tokens.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	events.Publish(events.Event{Type: events.TokenRevoked, Token: events.TokenSession})
+	return nil
+}
+
+// 登出：访问令牌加入黑名单直到过期，并吊销所属会话
+func (s *AuthService) Logout(ctx context.Context, claims *utils.Claims, token string) {
+	if claims.ExpiresAt != nil {
+		if err := s.tokens.RevokeAccessToken(ctx, utils.TokenRevocationID(claims, token), claims.ExpiresAt.Time); err != nil {
+			slog.WarnContext(ctx, "Token revoked locally only", "token_id", claims.ID, "error", err)
+		}
+		events.Publish(events.Event{Type: events.TokenRevoked, Token: events.TokenAccess})
+	}
+	if claims.SessionID != "" {
+		if err := s.RevokeSession(ctx, claims.SessionID); err != nil {
+			slog.ErrorContext(ctx, "Failed to revoke session on logout", "session_id", claims.SessionID, "error", err)
+		}
+	}
+}
+
+// 刷新令牌及其明文（明文只在签发时出现一次）
+type issuedRefreshToken struct {
+	models.RefreshToken
+	raw string
+}
+
+func (s *AuthService) newRefreshToken(userID uint, familyID string) (*issuedRefreshToken, error) {
+	raw, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &issuedRefreshToken{
+		RefreshToken: models.RefreshToken{
+			UserID:    userID,
+			FamilyID:  familyID,
+			TokenHash: utils.HashToken(raw),
+			ExpiresAt: time.Now().Add(refreshTokenTTL()),
+		},
+		raw: raw,
+	}, nil
+}
+
+func refreshTokenTTL() time.Duration {
+	return time.Duration(config.Current().RefreshTokenExpireHours) * time.Hour
+}
+
+func accessTokenExpiresIn() int64 {
+	return int64(config.Current().JWTExpireHours) * 3600
+}
+
+func notFoundAs(err, target error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return target
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+)
+
+// 每个用户的恢复码数量
+const recoveryCodeCount = 10
+
+// 保存待确认的TOTP密钥，确认前不生效
+func (s *UserService) SetTOTPSecret(ctx context.Context, user *models.User, secret string) error {
+	if err := s.users.Update(ctx, user, map[string]interface{}{"totp_secret": secret}); err != nil {
+		return err
+	}
+	s.invalidate(ctx, user.ID)
+	return nil
+}
+
+// 启用TOTP，step为确认时使用的时间窗口，返回新生成的恢复码明文（只展示一次）
+func (s *UserService) EnableTOTP(ctx context.Context, user *models.User, step int64) ([]string, error) {
+	codes, records, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.EnableTOTP(ctx, user, step, records); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, user.ID)
+	return codes, nil
+}
+
+// 关闭TOTP并删除恢复码
+func (s *UserService) DisableTOTP(ctx context.Context, user *models.User) error {
+	if err := s.users.DisableTOTP(ctx, user); err != nil {
+		return err
+	}
+	s.invalidate(ctx, user.ID)
+	return nil
+}
+
+// 重新生成恢复码，旧的恢复码全部作废
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, user *models.User) ([]string, error) {
+	codes, records, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.ReplaceRecoveryCodes(ctx, user.ID, records); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// 校验第二因素（TOTP验证码或恢复码），返回使用的认证方式
+func (s *UserService) VerifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (string, bool) {
+	if code != "" && user.TOTPSecret != "" {
+		if step, ok := utils.ValidateTOTPCode(user.TOTPSecret, code, time.Now()); ok {
+			// 同一时间窗口的验证码只能使用一次
+			if claimed, err := s.users.ClaimTOTPStep(ctx, user.ID, step); err == nil && claimed {
+				return utils.AuthMethodOTP, true
+			}
+		}
+	}
+
+	if recoveryCode != "" {
+		codeHash := utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))
+		if used, err := s.users.UseRecoveryCode(ctx, user.ID, codeHash); err == nil && used {
+			return utils.AuthMethodRecoveryCode, true
+		}
+	}
+
+	return "", false
+}
+
+// 生成恢复码，返回明文和只保存哈希的记录
+func newRecoveryCodes(userID uint) ([]string, []models.RecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(code),
+		})
+	}
+	return codes, records, nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/utils"
+)
+
+var (
+	ErrClientNotFound        = errors.New("client not found")
+	ErrInvalidClient         = errors.New("client authentication failed")
+	ErrInvalidGrant          = errors.New("invalid authorization code")
+	ErrAuthorizationCodeUsed = errors.New("authorization code has already been used")
+)
+
+// OAuth客户端、授权码和用户授权记录
+type OAuthService struct {
+	oauth repository.OAuthRepository
+}
+
+func NewOAuthService(oauth repository.OAuthRepository) *OAuthService {
+	return &OAuthService{oauth: oauth}
+}
+
+// 新客户端的信息，Scopes为空时允许全部支持的scope
+type CreateClientInput struct {
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+	Public       bool
+}
+
+// 注册客户端，返回客户端和密钥明文（公开客户端没有密钥，明文只在创建时出现一次）
+func (s *OAuthService) CreateClient(ctx context.Context, input CreateClientInput) (*models.OAuthClient, string, error) {
+	scopes := input.Scopes
+	if len(scopes) == 0 {
+		scopes = utils.SupportedScopes
+	}
+
+	clientID, err := utils.GenerateID()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     clientID,
+		Name:         input.Name,
+		RedirectURIs: strings.Join(input.RedirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+		Public:       input.Public,
+	}
+
+	// 机密客户端生成密钥，只保存哈希
+	var secret string
+	if !input.Public {
+		if secret, err = utils.GenerateOpaqueToken(); err != nil {
+			return nil, "", err
+		}
+		client.ClientSecretHash = utils.HashToken(secret)
+	}
+
+	if err := s.oauth.CreateClient(ctx, client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// 全部客户端
+func (s *OAuthService) Clients(ctx context.Context) ([]models.OAuthClient, error) {
+	return s.oauth.ListClients(ctx)
+}
+
+// 按client_id查找客户端
+func (s *OAuthService) FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	client, err := s.oauth.FindClient(ctx, clientID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrClientNotFound)
+	}
+	return client, nil
+}
+
+// 删除客户端，同时吊销该客户端的所有会话和授权记录
+func (s *OAuthService) DeleteClient(ctx context.Context, id uint) error {
+	client, err := s.oauth.FindClientByID(ctx, id)
+	if err != nil {
+		return notFoundAs(err, ErrClientNotFound)
+	}
+	return s.oauth.DeleteClient(ctx, client)
+}
+
+// 客户端认证，公开客户端不能提供密钥，机密客户端必须提供正确的密钥
+func (s *OAuthService) AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+	client, err := s.oauth.FindClient(ctx, clientID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidClient)
+	}
+
+	if client.Public {
+		if secret != "" {
+			return nil, ErrInvalidClient
+		}
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.ClientSecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// 保存授权码，返回明文
+func (s *OAuthService) CreateAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode) (string, error) {
+	raw, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	code.CodeHash = utils.HashToken(raw)
+	if err := s.oauth.CreateCode(ctx, code); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// 按明文查找客户端的授权码，不存在或属于其他客户端时返回ErrInvalidGrant
+func (s *OAuthService) FindAuthorizationCode(ctx context.Context, clientID, raw string) (*models.OAuthAuthorizationCode, error) {
+	code, err := s.oauth.FindCode(ctx, utils.HashToken(raw))
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidGrant)
+	}
+	if code.ClientID != clientID {
+		return nil, ErrInvalidGrant
+	}
+	return code, nil
+}
+
+// 标记授权码已使用，保证授权码只能兑换一次
+func (s *OAuthService) UseAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode) error {
+	if err := s.oauth.UseCode(ctx, code); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return ErrAuthorizationCodeUsed
+		}
+		return err
+	}
+	return nil
+}
+
+// 记录兑换授权码时为客户端创建的会话
+func (s *OAuthService) SetCodeGrantSession(ctx context.Context, code *models.OAuthAuthorizationCode, sessionID string) error {
+	return s.oauth.SetCodeGrantSession(ctx, code, sessionID)
+}
+
+// 用户是否已同意客户端申请的全部scope
+func (s *OAuthService) HasConsent(ctx context.Context, userID uint, clientID string, scopes []string) bool {
+	consent, err := s.oauth.FindConsent(ctx, userID, clientID)
+	if err != nil {
+		return false
+	}
+	return consent.Covers(scopes)
+}
+
+// 记录用户同意的scope，与之前同意的合并
+func (s *OAuthService) SaveConsent(ctx context.Context, userID uint, clientID string, scopes []string) error {
+	consent, err := s.oauth.FindConsent(ctx, userID, clientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		consent = &models.OAuthConsent{UserID: userID, ClientID: clientID}
+	} else if err != nil {
+		return err
+	}
+
+	granted := strings.Fields(consent.Scope)
+	for _, scope := range scopes {
+		if !utils.ContainsScope(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	consent.Scope = strings.Join(granted, " ")
+	return s.oauth.SaveConsent(ctx, consent)
+}
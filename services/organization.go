@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/utils"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrSlugTaken            = errors.New("organization slug already exists")
+	ErrMemberNotFound       = errors.New("member not found")
+	ErrInvalidInvitation    = errors.New("invalid or expired invitation")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrLastOrgAdmin         = errors.New("the organization must keep at least one member who can manage members")
+)
+
+// 组织邀请的有效期
+const InvitationTTL = 7 * 24 * time.Hour
+
+// 组织、成员和邀请管理
+type OrganizationService struct {
+	orgs  repository.OrganizationRepository
+	roles repository.RoleRepository
+}
+
+func NewOrganizationService(orgs repository.OrganizationRepository, roles repository.RoleRepository) *OrganizationService {
+	return &OrganizationService{orgs: orgs, roles: roles}
+}
+
+// 全部组织
+func (s *OrganizationService) List(ctx context.Context) ([]models.Organization, error) {
+	return s.orgs.List(ctx)
+}
+
+// 按ID获取组织
+func (s *OrganizationService) Get(ctx context.Context, id uint) (*models.Organization, error) {
+	org, err := s.orgs.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrOrganizationNotFound)
+	}
+	return org, nil
+}
+
+// 按ID批量获取组织，不存在的组织不返回
+func (s *OrganizationService) GetMany(ctx context.Context, ids []uint) ([]models.Organization, error) {
+	return s.orgs.FindByIDs(ctx, ids)
+}
+
+// 创建组织，标识已存在时返回ErrSlugTaken
+func (s *OrganizationService) Create(ctx context.Context, name, slug string) (*models.Organization, error) {
+	if exists, err := s.orgs.SlugExists(ctx, slug); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, ErrSlugTaken
+	}
+
+	org := &models.Organization{Name: name, Slug: slug}
+	if err := s.orgs.Create(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// 删除组织及其成员和邀请
+func (s *OrganizationService) Delete(ctx context.Context, org *models.Organization) error {
+	return s.orgs.Delete(ctx, org)
+}
+
+// 用户的全部成员身份
+func (s *OrganizationService) UserMemberships(ctx context.Context, userID uint) ([]models.Membership, error) {
+	return s.orgs.UserMemberships(ctx, userID)
+}
+
+// 组织的全部成员
+func (s *OrganizationService) Members(ctx context.Context, orgID uint) ([]models.Membership, error) {
+	return s.orgs.Members(ctx, orgID)
+}
+
+// 查找组织成员
+func (s *OrganizationService) FindMembership(ctx context.Context, orgID, userID uint) (*models.Membership, error) {
+	membership, err := s.orgs.FindMembership(ctx, orgID, userID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrMemberNotFound)
+	}
+	return membership, nil
+}
+
+// 修改成员在组织内的角色
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, membership *models.Membership, role models.Role) error {
+	if err := s.orgs.UpdateMemberRole(ctx, membership, role); err != nil {
+		return err
+	}
+	membership.Role = role
+	return nil
+}
+
+// 移除组织成员
+func (s *OrganizationService) RemoveMember(ctx context.Context, membership *models.Membership) error {
+	return s.orgs.RemoveMember(ctx, membership)
+}
+
+// 检查修改或移除成员（newRole为nil表示移除）后组织中是否仍有可以管理成员的成员
+// 不满足时返回ErrLastOrgAdmin
+func (s *OrganizationService) CheckKeepsMemberManager(ctx context.Context, membership *models.Membership, newRole *models.Role) error {
+	names, err := s.roles.NamesWithPermission(ctx, models.PermissionOrgMembersManage)
+	if err != nil {
+		return err
+	}
+
+	adminRoles := make(map[models.Role]bool, len(names))
+	for _, name := range names {
+		adminRoles[name] = true
+	}
+	if !adminRoles[membership.Role] || (newRole != nil && adminRoles[*newRole]) {
+		return nil
+	}
+
+	others, err := s.orgs.CountMembersWithRoles(ctx, membership.OrganizationID, membership.UserID, names)
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastOrgAdmin
+	}
+	return nil
+}
+
+// 组织未接受且未过期的邀请
+func (s *OrganizationService) PendingInvitations(ctx context.Context, orgID uint) ([]models.OrganizationInvitation, error) {
+	return s.orgs.PendingInvitations(ctx, orgID)
+}
+
+// 创建邀请，返回邀请记录和明文令牌（只通过邮件发送）
+func (s *OrganizationService) CreateInvitation(ctx context.Context, org *models.Organization, email string, role models.Role, invitedBy uint) (*models.OrganizationInvitation, string, error) {
+	raw, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation := &models.OrganizationInvitation{
+		OrganizationID: org.ID,
+		Email:          strings.ToLower(email),
+		Role:           role,
+		TokenHash:      utils.HashToken(raw),
+		InvitedBy:      invitedBy,
+		ExpiresAt:      time.Now().Add(InvitationTTL),
+	}
+	if err := s.orgs.CreateInvitation(ctx, invitation); err != nil {
+		return nil, "", err
+	}
+	return invitation, raw, nil
+}
+
+// 接受邀请，邀请只能由被邀请的邮箱接受
+func (s *OrganizationService) AcceptInvitation(ctx context.Context, user *models.User, token string) (*models.Membership, error) {
+	membership, err := s.orgs.AcceptInvitation(ctx, utils.HashToken(token), user.Email, user.ID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidInvitation)
+	}
+	return membership, nil
+}
+
+// 撤销组织未接受的邀请
+func (s *OrganizationService) RevokeInvitation(ctx context.Context, orgID, invitationID uint) error {
+	if err := s.orgs.DeleteInvitation(ctx, orgID, invitationID); err != nil {
+		return notFoundAs(err, ErrInvitationNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// 为邮箱对应的有效用户生成重置令牌，之前未使用的重置令牌全部作废
+// 用户不存在或已停用时返回ErrUserNotFound
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) (*models.User, string, error) {
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, "", err
+	}
+	if !user.IsActive {
+		return nil, "", ErrUserNotFound
+	}
+
+	raw, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ttl := time.Duration(config.Current().PasswordResetExpireMinutes) * time.Minute
+	err = s.repo.CreatePasswordResetToken(ctx, &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return user, raw, nil
+}
+
+// 使用重置令牌设置新密码，并吊销该用户的所有会话和令牌
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	// 能收到重置邮件说明邮箱属于该用户
+	userID, err := s.repo.UsePasswordResetToken(ctx, utils.HashToken(token), map[string]interface{}{
+		"password":            hashedPassword,
+		"password_changed_at": time.Now(),
+		"email_verified":      true,
+	})
+	if err != nil {
+		return notFoundAs(err, ErrInvalidResetToken)
+	}
+
+	// 吊销所有会话和刷新令牌，访问令牌由password_changed_at统一作废
+	if _, err := s.users.revokeSessions(ctx, userID, ""); err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke sessions after password reset", "user_id", userID, "error", err)
+	}
+	s.users.invalidate(ctx, userID)
+	return nil
+}
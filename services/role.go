@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+)
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+	ErrRoleInUse    = errors.New("role is still the primary role of some users")
+)
+
+// 角色管理，权限是否可以授予由调用方检查
+type RoleService struct {
+	roles repository.RoleRepository
+}
+
+func NewRoleService(roles repository.RoleRepository) *RoleService {
+	return &RoleService{roles: roles}
+}
+
+// 新角色的信息
+type CreateRoleInput struct {
+	Name        models.Role
+	Description string
+	RequireMFA  bool
+	Permissions []string
+}
+
+// 修改角色的信息，nil表示不修改
+type UpdateRoleInput struct {
+	Description *string
+	RequireMFA  *bool
+	Permissions []string
+}
+
+// 全部角色及其权限
+func (s *RoleService) List(ctx context.Context) ([]models.RoleDefinition, error) {
+	return s.roles.List(ctx)
+}
+
+// 按ID获取角色
+func (s *RoleService) Get(ctx context.Context, id uint) (*models.RoleDefinition, error) {
+	role, err := s.roles.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFoundAs(err, ErrRoleNotFound)
+	}
+	return role, nil
+}
+
+// 按名称获取角色及其权限
+func (s *RoleService) FindByName(ctx context.Context, name models.Role) (*models.RoleDefinition, error) {
+	role, err := s.roles.FindByName(ctx, name)
+	if err != nil {
+		return nil, notFoundAs(err, ErrRoleNotFound)
+	}
+	return role, nil
+}
+
+// 创建角色
+func (s *RoleService) Create(ctx context.Context, input CreateRoleInput) (*models.RoleDefinition, error) {
+	if exists, err := s.roles.NameExists(ctx, input.Name); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, ErrRoleExists
+	}
+
+	permissions, err := s.roles.FindPermissions(ctx, input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.RoleDefinition{
+		Name:        input.Name,
+		Description: input.Description,
+		RequireMFA:  input.RequireMFA,
+		Permissions: permissions,
+	}
+	if err := s.roles.Create(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// 修改角色的描述、两步验证要求和权限
+func (s *RoleService) Update(ctx context.Context, role *models.RoleDefinition, input UpdateRoleInput) error {
+	updates := make(map[string]interface{})
+	if input.Description != nil {
+		updates["description"] = *input.Description
+	}
+	if input.RequireMFA != nil {
+		updates["require_mfa"] = *input.RequireMFA
+	}
+
+	var permissions []models.Permission
+	if input.Permissions != nil {
+		found, err := s.roles.FindPermissions(ctx, input.Permissions)
+		if err != nil {
+			return err
+		}
+		// 非nil的空切片表示清空权限
+		permissions = append(make([]models.Permission, 0, len(found)), found...)
+	}
+
+	return s.roles.Update(ctx, role, updates, permissions)
+}
+
+// 删除角色，仍作为用户主角色使用时返回ErrRoleInUse
+func (s *RoleService) Delete(ctx context.Context, role *models.RoleDefinition) error {
+	count, err := s.roles.CountPrimaryUsers(ctx, role.Name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleInUse
+	}
+	return s.roles.Delete(ctx, role)
+}
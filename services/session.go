@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// 会话最近活动时间的更新间隔，避免每个请求都写数据库
+const sessionTouchInterval = time.Minute
+
+// 按ID获取用户，用于认证令牌后检查账号状态
+func (s *AuthService) FindUser(ctx context.Context, id uint) (*models.User, error) {
+	return s.users.Get(ctx, id)
+}
+
+// 用户的有效会话，最近活动的在前
+func (s *AuthService) Sessions(ctx context.Context, userID uint) ([]models.Session, error) {
+	return s.tokens.ListSessions(ctx, userID)
+}
+
+// 按ID查找会话
+func (s *AuthService) FindSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	session, err := s.tokens.FindSession(ctx, sessionID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrSessionNotFound)
+	}
+	return session, nil
+}
+
+// 按ID查找用户的会话，会话不属于该用户时同样返回ErrSessionNotFound
+func (s *AuthService) FindUserSession(ctx context.Context, userID uint, sessionID string) (*models.Session, error) {
+	session, err := s.FindSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// 刷新令牌所属的会话，令牌或会话不存在时返回ErrInvalidRefreshToken
+func (s *AuthService) RefreshTokenSession(ctx context.Context, raw string) (*models.Session, error) {
+	stored, err := s.tokens.FindRefreshToken(ctx, utils.HashToken(raw))
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidRefreshToken)
+	}
+	session, err := s.tokens.FindSession(ctx, stored.FamilyID)
+	if err != nil {
+		return nil, notFoundAs(err, ErrInvalidRefreshToken)
+	}
+	return session, nil
+}
+
+// 检查令牌所属的会话是否仍然有效，并按间隔更新最近活动时间
+func (s *AuthService) CheckSession(ctx context.Context, sessionID string, userID uint) bool {
+	session, err := s.FindUserSession(ctx, userID, sessionID)
+	if err != nil || !session.IsActive() {
+		return false
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.tokens.UpdateSession(ctx, session, map[string]interface{}{"last_seen_at": time.Now()}); err != nil {
+			slog.WarnContext(ctx, "Failed to update session activity", "session_id", session.ID, "error", err)
+		}
+	}
+	return true
+}
+
+// 切换会话当前选择的组织，0表示不选择组织
+func (s *AuthService) SetSessionOrg(ctx context.Context, session *models.Session, orgID uint) error {
+	return s.tokens.UpdateSession(ctx, session, map[string]interface{}{"org_id": orgID})
+}
+
+// 吊销用户的全部会话和刷新令牌，exceptID不为空时保留该会话
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID uint, exceptID string) (int64, error) {
+	return s.users.revokeSessions(ctx, userID, exceptID)
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gin-auth-project/events"
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/utils"
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("username already exists")
+	ErrEmailTaken    = errors.New("email already exists")
+	ErrUnknownRole   = errors.New("role does not exist")
+)
+
+// 用户管理：唯一性检查、密码哈希和角色变更
+type UserService struct {
+	users  repository.UserRepository
+	tokens repository.TokenStore
+}
+
+func NewUserService(users repository.UserRepository, tokens repository.TokenStore) *UserService {
+	return &UserService{users: users, tokens: tokens}
+}
+
+// 新用户的信息，Role为空时为普通用户
+type CreateUserInput struct {
+	Username      string
+	Email         string
+	Password      string
+	Role          models.Role
+	EmailVerified bool
+}
+
+// 修改用户的信息，空值表示不修改
+type UpdateUserInput struct {
+	Email    string
+	Password string
+	Role     models.Role
+}
+
+// 修改结果
+type UpdateUserResult struct {
+	EmailChanged    bool  // 邮箱已修改，需要重新验证
+	PasswordChanged bool  // 密码已修改，全部会话和此前签发的访问令牌已失效
+	SessionsRevoked int64 // 修改密码时吊销的会话数量
+}
+
+// 创建用户
+func (s *UserService) Create(ctx context.Context, input CreateUserInput) (*models.User, error) {
+	if exists, err := s.users.UsernameExists(ctx, input.Username); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, ErrUsernameTaken
+	}
+	if exists, err := s.users.EmailExists(ctx, input.Email, 0); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, ErrEmailTaken
+	}
+
+	role := input.Role
+	if role == "" {
+		role = models.RoleUser
+	} else if err := s.checkRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	user := &models.User{
+		Username:      input.Username,
+		Email:         input.Email,
+		Password:      hashedPassword,
+		Role:          role,
+		IsActive:      true,
+		EmailVerified: input.EmailVerified,
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// 修改用户信息，修改邮箱后需要重新验证，修改密码后吊销全部会话
+func (s *UserService) Update(ctx context.Context, user *models.User, input UpdateUserInput) (*UpdateUserResult, error) {
+	updates := make(map[string]interface{})
+	result := &UpdateUserResult{}
+
+	if input.Email != "" {
+		// 检查邮箱是否已被其他用户使用
+		if exists, err := s.users.EmailExists(ctx, input.Email, user.ID); err != nil {
+			return nil, err
+		} else if exists {
+			return nil, ErrEmailTaken
+		}
+		if input.Email != user.Email {
+			updates["email"] = input.Email
+			updates["email_verified"] = false
+			result.EmailChanged = true
+		}
+	}
+
+	if input.Password != "" {
+		hashedPassword, err := utils.HashPassword(input.Password)
+		if err != nil {
+			return nil, fmt.Errorf("hash password: %w", err)
+		}
+		// 与重置密码一致，password_changed_at之前签发的访问令牌一律失效
+		updates["password"] = hashedPassword
+		updates["password_changed_at"] = time.Now()
+		result.PasswordChanged = true
+	}
+
+	if input.Role != "" && input.Role != user.Role {
+		if err := s.checkRole(ctx, input.Role); err != nil {
+			return nil, err
+		}
+		updates["role"] = input.Role
+	}
+
+	if len(updates) == 0 {
+		return result, nil
+	}
+	if err := s.users.Update(ctx, user, updates); err != nil {
+		return nil, err
+	}
+	if result.PasswordChanged {
+		revoked, err := s.revokeSessions(ctx, user.ID, "")
+		if err != nil {
+			return nil, fmt.Errorf("revoke sessions: %w", err)
+		}
+		result.SessionsRevoked = revoked
+	}
+	s.invalidate(ctx, user.ID)
+	return result, nil
+}
+
+// 激活或停用用户
+func (s *UserService) SetActive(ctx context.Context, user *models.User, active bool) error {
+	if err := s.users.Update(ctx, user, map[string]interface{}{"is_active": active}); err != nil {
+		return err
+	}
+	s.invalidate(ctx, user.ID)
+	return nil
+}
+
+// 停用用户并吊销全部会话，返回吊销的会话数量
+func (s *UserService) Deactivate(ctx context.Context, user *models.User) (int64, error) {
+	if err := s.users.Update(ctx, user, map[string]interface{}{"is_active": false}); err != nil {
+		return 0, err
+	}
+	s.invalidate(ctx, user.ID)
+
+	revoked, err := s.revokeSessions(ctx, user.ID, "")
+	if err != nil {
+		return 0, fmt.Errorf("revoke sessions: %w", err)
+	}
+	return revoked, nil
+}
+
+// 删除用户（软删除）
+func (s *UserService) Delete(ctx context.Context, user *models.User) error {
+	if err := s.users.Delete(ctx, user); err != nil {
+		return err
+	}
+	s.invalidate(ctx, user.ID)
+	return nil
+}
+
+// 按ID获取用户，包含附加角色
+func (s *UserService) Get(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.users.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// 按用户名或邮箱查找用户
+func (s *UserService) FindByLogin(ctx context.Context, login string) (*models.User, error) {
+	user, err := s.users.FindByLogin(ctx, login)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// 按邮箱查找用户，不区分大小写
+func (s *UserService) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := s.users.FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// 标记邮箱已验证
+func (s *UserService) MarkEmailVerified(ctx context.Context, user *models.User) error {
+	if err := s.users.Update(ctx, user, map[string]interface{}{"email_verified": true}); err != nil {
+		return err
+	}
+	s.invalidate(ctx, user.ID)
+	return nil
+}
+
+// 分页查询用户，orgID不为0时只返回该组织的成员
+func (s *UserService) List(ctx context.Context, orgID uint, page, limit int) ([]models.User, int64, error) {
+	return s.users.List(ctx, orgID, (page-1)*limit, limit)
+}
+
+// 加载用户的附加角色
+func (s *UserService) LoadRoles(ctx context.Context, user *models.User) error {
+	return s.users.LoadRoles(ctx, user)
+}
+
+// 按名称加载角色及其权限，未定义的角色不返回
+func (s *UserService) FindRoles(ctx context.Context, names []models.Role) ([]models.RoleDefinition, error) {
+	return s.users.FindRoles(ctx, names)
+}
+
+// 整体替换用户的附加角色
+func (s *UserService) SetRoles(ctx context.Context, user *models.User, roles []models.RoleDefinition) error {
+	if err := s.users.SetRoles(ctx, user, roles); err != nil {
+		return err
+	}
+	s.invalidate(ctx, user.ID)
+	return nil
+}
+
+// 用户加入的全部组织
+func (s *UserService) OrgIDs(ctx context.Context, userID uint) ([]uint, error) {
+	return s.users.OrgIDs(ctx, userID)
+}
+
+// 用户的全部权限
+func (s *UserService) Permissions(ctx context.Context, user *models.User) ([]string, error) {
+	return s.users.Permissions(ctx, user)
+}
+
+func (s *UserService) checkRole(ctx context.Context, role models.Role) error {
+	exists, err := s.users.RoleExists(ctx, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownRole
+	}
+	return nil
+}
+
+// 吊销用户的会话和刷新令牌，exceptID不为空时保留该会话
+func (s *UserService) revokeSessions(ctx context.Context, userID uint, exceptID string) (int64, error) {
+	revoked, err := s.tokens.RevokeUserSessions(ctx, userID, exceptID)
+	if err != nil {
+		return 0, err
+	}
+	if revoked > 0 {
+		events.Publish(events.Event{Type: events.TokenRevoked, Token: events.TokenSession, Count: int(revoked)})
+	}
+	return revoked, nil
+}
+
+// 清除用户缓存，失败时只记录日志，缓存会自然过期
+func (s *UserService) invalidate(ctx context.Context, userID uint) {
+	if err := s.tokens.InvalidateUser(ctx, userID); err != nil {
+		slog.WarnContext(ctx, "Failed to clear user cache", "user_id", userID, "error", err)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/utils"
+)
+
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// 加载用户及其通行密钥
+func (s *UserService) WebAuthnUser(ctx context.Context, userID uint) (*utils.WebAuthnUser, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := s.users.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.WebAuthnCredentials = credentials
+	return &utils.WebAuthnUser{User: user, Credentials: credentials}, nil
+}
+
+// 用户的通行密钥
+func (s *UserService) WebAuthnCredentials(ctx context.Context, userID uint) ([]models.WebAuthnCredential, error) {
+	return s.users.WebAuthnCredentials(ctx, userID)
+}
+
+// 保存注册的通行密钥，凭证ID已存在时返回错误
+func (s *UserService) AddWebAuthnCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return s.users.CreateWebAuthnCredential(ctx, credential)
+}
+
+// 登录成功后更新签名计数器、标志位和最近使用时间
+func (s *UserService) RecordWebAuthnLogin(ctx context.Context, credentialID []byte, signCount uint32, flags uint8) error {
+	return s.users.UpdateWebAuthnCredential(ctx, credentialID, map[string]interface{}{
+		"sign_count":   signCount,
+		"flags":        flags,
+		"last_used_at": time.Now(),
+	})
+}
+
+// 删除用户的通行密钥
+func (s *UserService) DeleteWebAuthnCredential(ctx context.Context, userID, id uint) error {
+	err := s.users.DeleteWebAuthnCredential(ctx, userID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCredentialNotFound
+	}
+	return err
+}
@@ -4,7 +4,7 @@ echo "🚀 启动 Gin Auth Project..."
 
 # 检查Go是否安装
 if ! command -v go &> /dev/null; then
-    echo "❌ Go未安装，请先安装Go 1.21+"
+    echo "❌ Go未安装，请先安装Go 1.23+"
     exit 1
 fi
 
@@ -54,7 +54,7 @@ fi
 echo ""
 echo "🎯 启动应用..."
 echo "📡 服务器将在 http://localhost:8080 启动"
-echo "🔍 健康检查: http://localhost:8080/health"
+echo "🔍 健康检查: http://localhost:8080/livez（就绪检查: /readyz）"
 echo "📚 API文档请查看 README.md"
 echo ""
 echo "按 Ctrl+C 停止服务器"
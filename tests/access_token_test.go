@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAccessToken(t *testing.T) {
+	token, err := utils.GenerateAccessToken()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, utils.AccessTokenPrefix))
+
+	other, err := utils.GenerateAccessToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestAccessTokenScopes(t *testing.T) {
+	read := models.PersonalAccessToken{Scopes: "read"}
+	assert.True(t, read.HasScope(models.AccessTokenScopeRead))
+	assert.False(t, read.HasScope(models.AccessTokenScopeWrite))
+	assert.False(t, read.HasScope(models.AccessTokenScopeAdmin))
+
+	write := models.PersonalAccessToken{Scopes: "write"}
+	assert.True(t, write.HasScope(models.AccessTokenScopeRead))
+	assert.True(t, write.HasScope(models.AccessTokenScopeWrite))
+	assert.False(t, write.HasScope(models.AccessTokenScopeAdmin))
+
+	admin := models.PersonalAccessToken{Scopes: "admin"}
+	assert.True(t, admin.HasScope(models.AccessTokenScopeWrite))
+	assert.True(t, admin.HasScope(models.AccessTokenScopeAdmin))
+}
+
+func TestAccessTokenIsActive(t *testing.T) {
+	now := time.Now()
+
+	assert.True(t, (&models.PersonalAccessToken{ExpiresAt: now.Add(time.Hour)}).IsActive())
+	assert.False(t, (&models.PersonalAccessToken{ExpiresAt: now.Add(-time.Hour)}).IsActive())
+	assert.False(t, (&models.PersonalAccessToken{ExpiresAt: now.Add(time.Hour), RevokedAt: &now}).IsActive())
+}
+
+func TestInteractiveMiddlewareRejectsAccessTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/tokens", func(c *gin.Context) {
+		if c.GetHeader("X-Test-PAT") != "" {
+			c.Set("access_token", &models.PersonalAccessToken{Scopes: "write"})
+		}
+		c.Next()
+	}, middleware.InteractiveMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tokens", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/tokens", nil)
+	req.Header.Set("X-Test-PAT", "1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
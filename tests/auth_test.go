@@ -2,25 +2,61 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"gin-auth-project/config"
+	"gin-auth-project/database"
 	"gin-auth-project/handlers"
+	"gin-auth-project/mailer"
 	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/routes"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// 使用内存中的用户数据和令牌存储创建认证处理器
+func newTestAuthHandler(t *testing.T) (*handlers.AuthHandler, *services.UserService) {
+	config.Store(&config.Config{
+		JWTSecret:                    "test_secret",
+		JWTExpireHours:               1,
+		RefreshTokenExpireHours:      24,
+		EmailVerificationExpireHours: 24,
+	})
+	mailer.Default = mailer.NewMemoryMailer()
+
+	userRepo := repository.NewMemoryUserRepository()
+	tokenStore := repository.NewMemoryTokenStore()
+	users := services.NewUserService(userRepo, tokenStore)
+	return handlers.NewAuthHandler(services.NewAuthService(userRepo, tokenStore), users), users
+}
+
+func postJSON(r http.Handler, path string, body interface{}) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
 func TestRegister(t *testing.T) {
 	// 设置Gin为测试模式
 	gin.SetMode(gin.TestMode)
 
 	// 创建路由
+	authHandler, _ := newTestAuthHandler(t)
 	r := gin.New()
-	r.POST("/register", handlers.AuthHandler{}.Register)
+	r.POST("/register", authHandler.Register)
 
 	// 测试数据
 	registerData := models.RegisterRequest{
@@ -29,17 +65,7 @@ func TestRegister(t *testing.T) {
 		Password: "password123",
 	}
 
-	jsonData, _ := json.Marshal(registerData)
-
-	// 创建请求
-	req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-
-	// 创建响应记录器
-	w := httptest.NewRecorder()
-
-	// 执行请求
-	r.ServeHTTP(w, req)
+	w := postJSON(r, "/register", registerData)
 
 	// 验证响应
 	assert.Equal(t, http.StatusCreated, w.Code)
@@ -48,6 +74,11 @@ func TestRegister(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, "User registered successfully", response["message"])
+
+	// 用户名重复
+	w = postJSON(r, "/register", registerData)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "Username already exists")
 }
 
 func TestLogin(t *testing.T) {
@@ -55,8 +86,18 @@ func TestLogin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	// 创建路由
+	authHandler, users := newTestAuthHandler(t)
 	r := gin.New()
-	r.POST("/login", handlers.AuthHandler{}.Login)
+	r.POST("/login", authHandler.Login)
+	r.POST("/refresh", authHandler.RefreshToken)
+
+	_, err := users.Create(context.Background(), services.CreateUserInput{
+		Username: "admin",
+		Email:    "admin@example.com",
+		Password: "password",
+		Role:     models.RoleAdmin,
+	})
+	require.NoError(t, err)
 
 	// 测试数据
 	loginData := models.LoginRequest{
@@ -64,24 +105,147 @@ func TestLogin(t *testing.T) {
 		Password: "password",
 	}
 
-	jsonData, _ := json.Marshal(loginData)
-
-	// 创建请求
-	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-
-	// 创建响应记录器
-	w := httptest.NewRecorder()
-
-	// 执行请求
-	r.ServeHTTP(w, req)
+	w := postJSON(r, "/login", loginData)
 
 	// 验证响应
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, "Login successful", response["message"])
 	assert.NotEmpty(t, response["token"])
+
+	// 刷新令牌只能使用一次
+	refresh := gin.H{"refresh_token": response["refresh_token"]}
+	w = postJSON(r, "/refresh", refresh)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postJSON(r, "/refresh", refresh)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 密码错误
+	w = postJSON(r, "/login", models.LoginRequest{Username: "admin", Password: "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// 使用SQLite和进程内缓存创建完整的路由，请求经过与生产环境相同的中间件
+func newTestRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	useSQLite(t)
+	require.NoError(t, database.SeedRBAC())
+	useMemoryCache(t)
+
+	previous := config.Current()
+	cfg := config.Defaults()
+	cfg.JWTSecret = "test_secret"
+	cfg.RateLimitEnabled = false
+	cfg.MailTransport = "memory"
+	config.Store(cfg)
+	t.Cleanup(func() { config.Store(previous) })
+
+	mailer.Default = mailer.NewMemoryMailer()
+	require.NoError(t, utils.InitSigningKeys())
+	return routes.SetupRoutes()
+}
+
+// 在数据库中创建已验证邮箱的用户
+func createTestUser(t *testing.T, username, password string, role models.Role) *models.User {
+	hashed, err := utils.HashPassword(password)
+	require.NoError(t, err)
+	user := &models.User{
+		Username:      username,
+		Email:         username + "@example.com",
+		Password:      hashed,
+		Role:          role,
+		IsActive:      true,
+		EmailVerified: true,
+	}
+	require.NoError(t, database.DB.Create(user).Error)
+	return user
+}
+
+// 通过登录接口登录，返回访问令牌和刷新令牌
+func loginAs(t *testing.T, r http.Handler, username, password string) (string, string) {
+	w := postJSON(r, "/api/auth/login", models.LoginRequest{Username: username, Password: password})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Token, response.RefreshToken
+}
+
+// 发送带访问令牌的请求，body为nil时不带请求体
+func authRequest(r http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		jsonData, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(jsonData)
+	}
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 使用刷新令牌，返回新的访问令牌和刷新令牌
+func refreshTokens(r http.Handler, refreshToken string) (*httptest.ResponseRecorder, string, string) {
+	w := postJSON(r, "/api/auth/refresh", models.RefreshTokenRequest{RefreshToken: refreshToken})
+	var response struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response.Token, response.RefreshToken
+}
+
+// 重放已轮换的刷新令牌会吊销整个令牌族：之后轮换出的令牌和会话的访问令牌都失效，其他会话不受影响
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "alice", "password123", models.RoleUser)
+	_, first := loginAs(t, r, "alice", "password123")
+	otherAccess, otherRefresh := loginAs(t, r, "alice", "password123")
+
+	w, _, second := refreshTokens(r, first)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w, access, third := refreshTokens(r, second)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", access, nil).Code)
+
+	w, _, _ = refreshTokens(r, first)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Refresh token has been revoked")
+
+	w, _, _ = refreshTokens(r, third)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", access, nil).Code)
+
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", otherAccess, nil).Code)
+	w, _, _ = refreshTokens(r, otherRefresh)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// 登出或吊销会话后作废的刷新令牌只是无效，不按重用处理
+func TestRevokedRefreshTokenIsNotReuse(t *testing.T) {
+	r := newTestRouter(t)
+	alice := createTestUser(t, "alice", "password123", models.RoleUser)
+	access, refresh := loginAs(t, r, "alice", "password123")
+	_, otherRefresh := loginAs(t, r, "alice", "password123")
+
+	require.Equal(t, http.StatusOK, authRequest(r, http.MethodPost, "/api/auth/logout", access, nil).Code)
+	w, _, _ := refreshTokens(r, refresh)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid refresh token")
+
+	_, err := repository.NewTokenStore(database.DB).RevokeUserSessions(context.Background(), alice.ID, "")
+	require.NoError(t, err)
+	w, _, _ = refreshTokens(r, otherRefresh)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid refresh token")
 }
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gin-auth-project/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 使用进程内缓存，测试结束后恢复为未初始化状态
+func useMemoryCache(t *testing.T) {
+	database.UseCache(database.NewMemoryCache())
+	t.Cleanup(func() { database.UseCache(nil) })
+}
+
+func TestMemoryCache(t *testing.T) {
+	useMemoryCache(t)
+
+	_, err := database.GetCache("missing")
+	assert.ErrorIs(t, err, database.ErrCacheMiss)
+	ttl, err := database.TTLCache("missing")
+	require.NoError(t, err)
+	assert.Less(t, ttl, time.Duration(0))
+
+	require.NoError(t, database.SetCache("greeting", []byte("hello"), time.Minute))
+	value, err := database.GetCache("greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", value)
+
+	ttl, err = database.TTLCache("greeting")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	// 一次性数据读取后删除
+	value, err = database.TakeCache("greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", value)
+	exists, err := database.ExistsCache("greeting")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// 计数器首次创建时设置过期时间，之后保留原有的过期时间
+	count, err := database.IncrCache("counter", 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = database.IncrCache("counter", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = database.GetCache("counter")
+	assert.ErrorIs(t, err, database.ErrCacheMiss)
+
+	require.NoError(t, database.SetCache("name", "alice", 0))
+	_, err = database.IncrCache("name", time.Minute)
+	assert.Error(t, err)
+	require.NoError(t, database.DeleteCache("name"))
+	exists, err = database.ExistsCache("name")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestLoginLockoutWithMemoryCache(t *testing.T) {
+	useMemoryCache(t)
+	ctx := context.Background()
+	assert.True(t, database.LoginAttemptsEnabled())
+
+	for i := 0; i < 2; i++ {
+		locked, err := database.RecordLoginFailure(ctx, "user:7", 3, time.Minute, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, locked)
+	}
+	locked, err := database.RecordLoginFailure(ctx, "user:7", 3, time.Minute, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	remaining, err := database.LoginLockRemaining(ctx, "user:7")
+	require.NoError(t, err)
+	assert.Greater(t, remaining, time.Duration(0))
+}
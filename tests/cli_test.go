@@ -0,0 +1,244 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-auth-project/cli"
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// 参数错误在连接数据库之前返回，退出码为2
+func TestCLIUsageErrors(t *testing.T) {
+	previous := config.Current()
+	defer func() { config.Store(previous) }()
+
+	var stdout, stderr bytes.Buffer
+	cli.Stdout, cli.Stderr = &stdout, &stderr
+	defer func() { cli.Stdout, cli.Stderr = os.Stdout, os.Stderr }()
+
+	for _, args := range [][]string{
+		{"unknown"},
+		{"migrate"},
+		{"migrate", "sideways"},
+		{"migrate", "down"},
+		{"migrate", "down", "0"},
+		{"user"},
+		{"user", "create", "--username", "ab", "--email", "ab@example.com"},
+		{"user", "create", "--username", "alice", "--email", "not-an-email"},
+		{"user", "set-role", "alice"},
+		{"user", "deactivate"},
+		{"token", "issue", "alice"},
+		{"token", "issue", "--name", "ci", "--scopes", "read,root", "alice"},
+		{"token", "issue", "--name", "ci", "--days", "400", "alice"},
+		{"user", "create", "--no-such-flag"},
+		{"config"},
+		{"config", "dump"},
+		{"config", "print", "extra"},
+		{"--set", "SERVER_PORT", "serve"},
+	} {
+		stderr.Reset()
+		assert.Equal(t, 2, cli.Run(args), args)
+		assert.Contains(t, stderr.String(), "Usage:", args)
+	}
+
+	stdout.Reset()
+	assert.Equal(t, 0, cli.Run([]string{"help"}))
+	assert.Contains(t, stdout.String(), "token issue")
+}
+
+// 命令行的执行结果
+type cliResult struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// 使用临时SQLite数据库文件和进程内缓存执行命令行，数据库已迁移并初始化角色
+func useCLI(t *testing.T) func(args ...string) cliResult {
+	previousConfig, previousDB := config.Current(), database.DB
+	var opened []*gorm.DB
+	t.Cleanup(func() {
+		for _, db := range opened {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}
+		database.DB = previousDB
+		database.UseCache(nil)
+		config.Store(previousConfig)
+		cli.Stdin, cli.Stdout, cli.Stderr = os.Stdin, os.Stdout, os.Stderr
+	})
+
+	path := filepath.Join(t.TempDir(), "cli.db")
+	run := func(args ...string) cliResult {
+		var stdout, stderr bytes.Buffer
+		cli.Stdout, cli.Stderr = &stdout, &stderr
+		global := []string{"--set", "DB_DRIVER=sqlite", "--set", "SQLITE_PATH=" + path, "--set", "REDIS_HOST="}
+		code := cli.Run(append(global, args...))
+		// 每次执行都会打开新的连接，测试结束时统一关闭
+		if database.DB != nil {
+			opened = append(opened, database.DB)
+		}
+		return cliResult{code: code, stdout: stdout.String(), stderr: stderr.String()}
+	}
+
+	result := run("migrate", "up")
+	require.Equal(t, 0, result.code, result.stderr)
+	require.NoError(t, database.SeedRBAC())
+	return run
+}
+
+// 创建测试会话和刷新令牌
+func createTestSession(t *testing.T, userID uint, id string) {
+	require.NoError(t, database.DB.Create(&models.Session{
+		ID:        id,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}).Error)
+	require.NoError(t, database.DB.Create(&models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  id,
+		TokenHash: utils.HashToken(id),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}).Error)
+}
+
+func loadUser(t *testing.T, ident string) models.User {
+	var user models.User
+	require.NoError(t, database.DB.Where("username = ?", ident).First(&user).Error)
+	return user
+}
+
+func TestCLIUserCommands(t *testing.T) {
+	run := useCLI(t)
+
+	// 未提供密码时生成随机密码并输出
+	result := run("user", "create", "--username", "alice", "--email", "alice@example.com", "--verified")
+	require.Equal(t, 0, result.code, result.stderr)
+	assert.Contains(t, result.stdout, "with role user")
+	lines := strings.Split(strings.TrimSpace(result.stdout), "\n")
+	generated := strings.TrimPrefix(lines[len(lines)-1], "Generated password: ")
+	alice := loadUser(t, "alice")
+	assert.True(t, utils.CheckPassword(generated, alice.Password))
+	assert.True(t, alice.EmailVerified)
+	assert.True(t, alice.IsActive)
+	assert.Equal(t, models.RoleUser, alice.Role)
+
+	// 与管理接口共用唯一性检查
+	result = run("user", "create", "--username", "alice", "--email", "other@example.com")
+	assert.Equal(t, 1, result.code)
+	assert.Contains(t, result.stderr, "username already exists")
+	result = run("user", "create", "--username", "bob", "--email", "alice@example.com")
+	assert.Equal(t, 1, result.code)
+	assert.Contains(t, result.stderr, "email already exists")
+	assert.Contains(t, run("user", "create", "--username", "bob", "--email", "bob@example.com", "--role", "root").stderr, `role "root" does not exist`)
+
+	// 设置密码后吊销全部会话，并记录password_changed_at
+	createTestSession(t, alice.ID, "alice-session")
+	cli.Stdin = strings.NewReader("new-password\n")
+	result = run("user", "set-password", "--password-stdin", "alice@example.com")
+	require.Equal(t, 0, result.code, result.stderr)
+	assert.Contains(t, result.stdout, "1 session(s) revoked")
+	alice = loadUser(t, "alice")
+	assert.True(t, utils.CheckPassword("new-password", alice.Password))
+	require.NotNil(t, alice.PasswordChangedAt)
+	assert.WithinDuration(t, time.Now(), *alice.PasswordChangedAt, time.Minute)
+	var session models.Session
+	require.NoError(t, database.DB.First(&session, "id = ?", "alice-session").Error)
+	assert.NotNil(t, session.RevokedAt)
+	var refresh models.RefreshToken
+	require.NoError(t, database.DB.First(&refresh, "family_id = ?", "alice-session").Error)
+	assert.NotNil(t, refresh.RevokedAt)
+
+	cli.Stdin = strings.NewReader("short\n")
+	assert.Equal(t, 1, run("user", "set-password", "--password-stdin", "alice").code)
+
+	// 修改主角色，角色必须存在
+	result = run("user", "set-role", "alice", "admin")
+	require.Equal(t, 0, result.code, result.stderr)
+	assert.Equal(t, models.RoleAdmin, loadUser(t, "alice").Role)
+	result = run("user", "set-role", "alice", "root")
+	assert.Equal(t, 1, result.code)
+	assert.Contains(t, result.stderr, `role "root" does not exist`)
+	assert.Equal(t, models.RoleAdmin, loadUser(t, "alice").Role)
+
+	// 停用用户并吊销会话
+	createTestSession(t, alice.ID, "alice-second")
+	result = run("user", "deactivate", "alice")
+	require.Equal(t, 0, result.code, result.stderr)
+	assert.Contains(t, result.stdout, "1 session(s) revoked")
+	assert.False(t, loadUser(t, "alice").IsActive)
+
+	result = run("user", "deactivate", "nobody")
+	assert.Equal(t, 1, result.code)
+	assert.Contains(t, result.stderr, `user "nobody" not found`)
+}
+
+// 纯数字的标识按ID找不到用户时按用户名查找
+func TestCLIFindUserByNumericUsername(t *testing.T) {
+	run := useCLI(t)
+
+	require.Equal(t, 0, run("user", "create", "--username", "alice", "--email", "alice@example.com").code)
+	require.Equal(t, 0, run("user", "create", "--username", "12345", "--email", "numeric@example.com").code)
+	alice := loadUser(t, "alice")
+
+	result := run("user", "set-role", "12345", "admin")
+	require.Equal(t, 0, result.code, result.stderr)
+	assert.Equal(t, models.RoleAdmin, loadUser(t, "12345").Role)
+
+	// ID优先
+	result = run("user", "deactivate", strconv.FormatUint(uint64(alice.ID), 10))
+	require.Equal(t, 0, result.code, result.stderr)
+	assert.False(t, loadUser(t, "alice").IsActive)
+	assert.True(t, loadUser(t, "12345").IsActive)
+
+	assert.Equal(t, 1, run("user", "deactivate", "999").code)
+}
+
+func TestCLITokenIssue(t *testing.T) {
+	run := useCLI(t)
+
+	require.Equal(t, 0, run("user", "create", "--username", "alice", "--email", "alice@example.com").code)
+	require.Equal(t, 0, run("user", "create", "--username", "root", "--email", "root@example.com", "--role", "admin").code)
+
+	// 普通用户不能持有admin范围的令牌
+	result := run("token", "issue", "--name", "ci", "--scopes", "read,admin", "alice")
+	assert.Equal(t, 1, result.code)
+	assert.Contains(t, result.stderr, "admin scope")
+	var count int64
+	require.NoError(t, database.DB.Model(&models.PersonalAccessToken{}).Count(&count).Error)
+	assert.Zero(t, count)
+
+	result = run("token", "issue", "--name", "ci", "--scopes", "read,write", "alice")
+	require.Equal(t, 0, result.code, result.stderr)
+	lines := strings.Split(strings.TrimSpace(result.stdout), "\n")
+	raw := lines[len(lines)-1]
+	assert.True(t, strings.HasPrefix(raw, utils.AccessTokenPrefix))
+
+	var token models.PersonalAccessToken
+	require.NoError(t, database.DB.Where("token_hash = ?", utils.HashToken(raw)).First(&token).Error)
+	assert.Equal(t, loadUser(t, "alice").ID, token.UserID)
+	assert.Equal(t, "read write", token.Scopes)
+
+	result = run("token", "issue", "--name", "admin", "--scopes", "admin", "root")
+	require.Equal(t, 0, result.code, result.stderr)
+
+	// 停用的用户不能签发令牌
+	require.Equal(t, 0, run("user", "deactivate", "alice").code)
+	result = run("token", "issue", "--name", "ci", "alice")
+	assert.Equal(t, 1, result.code)
+	assert.Contains(t, result.stderr, "deactivated")
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gin-auth-project/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// 修改当前配置的副本后替换，与重新加载配置一样不修改已发布的快照
+func updateConfig(update func(cfg *config.Config)) {
+	cfg := *config.Current()
+	update(&cfg)
+	config.Store(&cfg)
+}
+
+// 默认值 < 配置文件 < 环境变量 < 命令行覆盖
+func TestConfigLayers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+  mode: test
+db:
+  driver: sqlite
+log_level: warn
+jwt_expire_hours: 2
+webauthn_rp_origins:
+  - https://a.example.com
+  - https://b.example.com
+`)
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("JWT_EXPIRE_HOURS", "")
+
+	cfg, err := config.Load(config.Options{
+		File:      file,
+		Overrides: map[string]string{"server_port": "9100"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "9100", cfg.ServerPort)
+	assert.Equal(t, "test", cfg.ServerMode)
+	assert.Equal(t, "sqlite", cfg.DBDriver)
+	assert.Equal(t, "error", cfg.LogLevel)
+	// 空的环境变量不覆盖配置文件
+	assert.Equal(t, 2, cfg.JWTExpireHours)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.WebAuthnRPOrigins)
+	assert.Equal(t, config.Defaults().RefreshTokenExpireHours, cfg.RefreshTokenExpireHours)
+
+	// REDIS_HOST设置为空时使用进程内缓存
+	t.Setenv("REDIS_HOST", "")
+	cfg, err = config.Load(config.Options{File: file})
+	require.NoError(t, err)
+	assert.Empty(t, cfg.RedisHost)
+}
+
+func TestConfigSecretFiles(t *testing.T) {
+	secret := writeFile(t, "jwt_secret", "secret-from-file\n")
+	file := writeFile(t, "config.yaml", "jwt_secret: secret-from-config\n")
+
+	// 环境变量中的 _FILE 优先于配置文件中的值，末尾换行被去掉
+	t.Setenv("JWT_SECRET_FILE", secret)
+	cfg, err := config.Load(config.Options{File: file})
+	require.NoError(t, err)
+	assert.Equal(t, "secret-from-file", cfg.JWTSecret)
+
+	// 命令行直接设置的值优先于环境变量中的 _FILE
+	cfg, err = config.Load(config.Options{Overrides: map[string]string{"JWT_SECRET": "from-flag"}})
+	require.NoError(t, err)
+	assert.Equal(t, "from-flag", cfg.JWTSecret)
+
+	// 同一来源中不能同时设置两者
+	t.Setenv("JWT_SECRET", "secret-from-env")
+	_, err = config.Load(config.Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET and JWT_SECRET_FILE are both set")
+
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err = config.Load(config.Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET_FILE")
+}
+
+func TestConfigValidation(t *testing.T) {
+	file := writeFile(t, "config.yaml", "server_prot: 8080\n")
+	_, err := config.Load(config.Options{File: file, Overrides: map[string]string{"JWT_EXPIRE_HOURS": "one"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown setting "server_prot"`)
+	assert.Contains(t, err.Error(), `JWT_EXPIRE_HOURS (from --set): "one" is not an integer`)
+
+	_, err = config.Load(config.Options{Overrides: map[string]string{"SERVER_MODE": "production", "TRACING_SAMPLE_RATIO": "2", "TRUSTED_PROXIES": "10.0.0.0/8,proxy.internal"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `TRUSTED_PROXIES must contain IP addresses or CIDRs, got "proxy.internal"`)
+	assert.Contains(t, err.Error(), "SERVER_MODE must be one of")
+	assert.Contains(t, err.Error(), "TRACING_SAMPLE_RATIO must be between 0 and 1")
+
+	// release模式下拒绝不安全的默认值
+	_, err = config.Load(config.Options{Overrides: map[string]string{"SERVER_MODE": "release"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET must be set in release mode")
+	assert.Contains(t, err.Error(), "DB_PASSWORD must be set in release mode")
+
+	_, err = config.Load(config.Options{Overrides: map[string]string{
+		"SERVER_MODE": "release", "JWT_SECRET": "too-short", "DB_PASSWORD": "secret",
+	}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET must be at least 32 bytes")
+
+	// 只使用非对称密钥时不需要JWT_SECRET
+	_, err = config.Load(config.Options{Overrides: map[string]string{
+		"SERVER_MODE": "release", "JWT_SIGNING_KEYS": "keys/current.pem", "DB_DRIVER": "sqlite",
+	}})
+	assert.NoError(t, err)
+}
+
+// 输出的配置可以作为配置文件重新加载，redacted时隐藏敏感配置项
+func TestConfigPrint(t *testing.T) {
+	overrides := map[string]string{
+		"DB_PASSWORD":          "db-password",
+		"JWT_SECRET":           "jwt-secret",
+		"TRACING_SAMPLE_RATIO": "0.25",
+		"WEBAUTHN_RP_ORIGINS":  "https://a.example.com,https://b.example.com",
+	}
+	cfg, err := config.Load(config.Options{Overrides: overrides})
+	require.NoError(t, err)
+
+	var redacted bytes.Buffer
+	require.NoError(t, cfg.Print(&redacted, true))
+	assert.Contains(t, redacted.String(), "db_password: '[REDACTED]'")
+	assert.Contains(t, redacted.String(), `smtp_password: ""`)
+	assert.NotContains(t, redacted.String(), "db-password")
+	assert.NotContains(t, redacted.String(), "jwt-secret")
+
+	var full bytes.Buffer
+	require.NoError(t, cfg.Print(&full, false))
+	reloaded, err := config.Load(config.Options{File: writeFile(t, "printed.yaml", full.String())})
+	require.NoError(t, err)
+	assert.Equal(t, cfg, reloaded)
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gin-auth-project/database"
+	"gin-auth-project/handlers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readinessResponse struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status  string `json:"status"`
+		Error   string `json:"error"`
+		Version int64  `json:"version"`
+		Pending int    `json:"pending"`
+	} `json:"checks"`
+}
+
+func probe(t *testing.T, path string) (int, readinessResponse) {
+	gin.SetMode(gin.TestMode)
+	h := &handlers.HealthHandler{}
+	r := gin.New()
+	r.GET("/livez", h.Live)
+	r.GET("/readyz", h.Ready)
+
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response readinessResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+func TestReadiness(t *testing.T) {
+	useSQLite(t)
+	server := useMiniredis(t)
+
+	code, response := probe(t, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", response.Status)
+	assert.Equal(t, "ok", response.Checks["database"].Status)
+	assert.Equal(t, "ok", response.Checks["redis"].Status)
+	assert.Equal(t, "ok", response.Checks["migrations"].Status)
+	assert.NotZero(t, response.Checks["migrations"].Version)
+
+	// Redis断开后不再就绪，存活检查不受影响
+	server.Close()
+	code, response = probe(t, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", response.Status)
+	assert.Equal(t, "unavailable", response.Checks["redis"].Status)
+	assert.Equal(t, "unreachable", response.Checks["redis"].Error)
+	assert.Equal(t, "ok", response.Checks["database"].Status)
+
+	code, response = probe(t, "/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", response.Status)
+
+	// 未配置Redis时使用进程内缓存，不影响就绪
+	database.UseRedis(nil)
+	code, response = probe(t, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "disabled", response.Checks["redis"].Status)
+}
+
+func TestReadinessPendingMigrations(t *testing.T) {
+	db := useSQLite(t)
+	_, err := database.MigrateDown(db, 1)
+	require.NoError(t, err)
+
+	code, response := probe(t, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", response.Checks["migrations"].Status)
+	assert.Equal(t, "pending migrations", response.Checks["migrations"].Error)
+	assert.Equal(t, 1, response.Checks["migrations"].Pending)
+	assert.Equal(t, "ok", response.Checks["database"].Status)
+
+	// 数据库关闭后不再就绪
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.Close()
+	code, response = probe(t, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", response.Checks["database"].Status)
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gin-auth-project/config"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 生成PKCS#8格式的私钥文件
+func writeSigningKey(t *testing.T, dir, name string, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name+".pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys, err := utils.LoadSigningKeys([]string{
+		writeSigningKey(t, dir, "rsa-1", rsaKey),
+		writeSigningKey(t, dir, "ec-1", ecKey),
+		writeSigningKey(t, dir, "ed-1", edKey),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "rsa-1", keys.Active().ID)
+	assert.Equal(t, utils.KeyStatusActive, keys.Active().Status)
+
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 3)
+	assert.Equal(t, "RS256", jwks.Keys[0].Algorithm)
+	assert.Equal(t, "ES256", jwks.Keys[1].Algorithm)
+	assert.Equal(t, "P-256", jwks.Keys[1].Curve)
+	assert.Equal(t, "EdDSA", jwks.Keys[2].Algorithm)
+	assert.Equal(t, "OKP", jwks.Keys[2].KeyType)
+
+	ecKeyFromSet, ok := keys.Lookup("ec-1")
+	require.True(t, ok)
+	assert.Equal(t, utils.KeyStatusRetiring, ecKeyFromSet.Status)
+}
+
+// 密钥轮换：新密钥先发布，再切换为签发密钥，旧密钥签发的令牌在移除前仍然有效
+func TestSigningKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	oldPath := writeSigningKey(t, dir, "2026-01", oldKey)
+	newPath := writeSigningKey(t, dir, "2026-02", newKey)
+
+	config.Store(&config.Config{
+		JWTSecret:                    "test_secret",
+		EmailVerificationExpireHours: 1,
+		JWTSigningKeys:               []string{oldPath},
+	})
+	require.NoError(t, utils.InitSigningKeys())
+	defer func() {
+		updateConfig(func(cfg *config.Config) { cfg.JWTSigningKeys = nil })
+		utils.InitSigningKeys()
+	}()
+
+	user := &models.User{ID: 1, Username: "alice", Email: "alice@example.com"}
+	oldToken, err := utils.GenerateEmailVerificationToken(user)
+	require.NoError(t, err)
+	assertTokenKeyID(t, oldToken, "2026-01")
+
+	// 新密钥成为签发密钥，旧密钥进入待退役状态
+	updateConfig(func(cfg *config.Config) { cfg.JWTSigningKeys = []string{newPath, oldPath} })
+	require.NoError(t, utils.InitSigningKeys())
+
+	newToken, err := utils.GenerateEmailVerificationToken(user)
+	require.NoError(t, err)
+	assertTokenKeyID(t, newToken, "2026-02")
+
+	_, err = utils.ValidateToken(oldToken)
+	assert.NoError(t, err)
+	_, err = utils.ValidateToken(newToken)
+	assert.NoError(t, err)
+
+	// 移除旧密钥后，旧令牌不再有效
+	updateConfig(func(cfg *config.Config) { cfg.JWTSigningKeys = []string{newPath} })
+	require.NoError(t, utils.InitSigningKeys())
+
+	_, err = utils.ValidateToken(oldToken)
+	assert.Error(t, err)
+	_, err = utils.ValidateToken(newToken)
+	assert.NoError(t, err)
+}
+
+func TestHS256RejectedAfterSwitchingToAsymmetricKeys(t *testing.T) {
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	config.Store(&config.Config{JWTSecret: "test_secret", EmailVerificationExpireHours: 1})
+	require.NoError(t, utils.InitSigningKeys())
+
+	user := &models.User{ID: 1, Username: "alice", Email: "alice@example.com"}
+	hsToken, err := utils.GenerateEmailVerificationToken(user)
+	require.NoError(t, err)
+
+	updateConfig(func(cfg *config.Config) { cfg.JWTSigningKeys = []string{writeSigningKey(t, dir, "ed", key)} })
+	require.NoError(t, utils.InitSigningKeys())
+	defer func() {
+		updateConfig(func(cfg *config.Config) { cfg.JWTSigningKeys = nil })
+		utils.InitSigningKeys()
+	}()
+
+	_, err = utils.ValidateToken(hsToken)
+	assert.Error(t, err)
+
+	// 过渡期内仍接受HS256令牌
+	updateConfig(func(cfg *config.Config) { cfg.JWTAcceptHS256 = true })
+	_, err = utils.ValidateToken(hsToken)
+	assert.NoError(t, err)
+}
+
+func assertTokenKeyID(t *testing.T, tokenString, kid string) {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &utils.Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, kid, token.Header["kid"])
+}
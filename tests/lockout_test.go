@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/handlers"
+	"gin-auth-project/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 使用内存中的Redis替身，测试结束后恢复为未初始化状态
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	database.UseRedis(client)
+	t.Cleanup(func() {
+		client.Close()
+		database.UseRedis(nil)
+	})
+	return server
+}
+
+func TestLoginLockout(t *testing.T) {
+	server := useMiniredis(t)
+	ctx := context.Background()
+	key := "user:1"
+
+	for i := 1; i < 5; i++ {
+		locked, err := database.RecordLoginFailure(ctx, key, 5, 15*time.Minute, 15*time.Minute)
+		require.NoError(t, err)
+		assert.False(t, locked)
+	}
+	failures, err := database.LoginFailures(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, 4, failures)
+	// 计数器在创建时就带有窗口的过期时间
+	assert.Equal(t, 15*time.Minute, server.TTL("login_fail:"+key))
+
+	locked, err := database.RecordLoginFailure(ctx, key, 5, 15*time.Minute, 15*time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	remaining, err := database.LoginLockRemaining(ctx, key)
+	require.NoError(t, err)
+	assert.InDelta(t, 15*time.Minute, remaining, float64(time.Second))
+
+	// 锁定后计数清零，解锁后可以重新登录
+	failures, err = database.LoginFailures(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, 0, failures)
+
+	require.NoError(t, database.UnlockLogin(ctx, key))
+	remaining, err = database.LoginLockRemaining(ctx, key)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
+
+func TestLoginFailureWindow(t *testing.T) {
+	server := useMiniredis(t)
+	ctx := context.Background()
+	key := "name:mallory"
+
+	for i := 0; i < 3; i++ {
+		_, err := database.RecordLoginFailure(ctx, key, 5, time.Minute, time.Minute)
+		require.NoError(t, err)
+	}
+
+	// 窗口过期后重新计数
+	server.FastForward(time.Minute)
+	failures, err := database.LoginFailures(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, 0, failures)
+
+	// 锁定到期后自动解除
+	for i := 0; i < 5; i++ {
+		database.RecordLoginFailure(ctx, key, 5, time.Minute, time.Minute)
+	}
+	server.FastForward(time.Minute)
+	remaining, err := database.LoginLockRemaining(ctx, key)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
+
+// 从指定地址提交登录请求
+func loginFrom(r http.Handler, remoteIP, forwardedFor, username, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(models.LoginRequest{Username: username, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteIP + ":1234"
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 锁定后的响应与密码错误完全相同，管理员解锁后可以登录，锁定时通知用户
+func TestLoginLockoutHandler(t *testing.T) {
+	r := newTestRouter(t)
+	updateConfig(func(cfg *config.Config) {
+		cfg.LoginMaxFailures = 2
+		cfg.LoginIPMaxFailures = 100
+	})
+	createTestUser(t, "admin", "admin-password", models.RoleAdmin)
+	alice := createTestUser(t, "alice", "alice-password", models.RoleUser)
+
+	notified := make(chan uint, 1)
+	previous := handlers.OnAccountLocked
+	handlers.OnAccountLocked = func(ctx context.Context, user *models.User, ip string, until time.Time) {
+		notified <- user.ID
+	}
+	t.Cleanup(func() { handlers.OnAccountLocked = previous })
+
+	wrongPassword := loginFrom(r, "192.0.2.1", "", "alice", "wrong")
+	require.Equal(t, http.StatusUnauthorized, wrongPassword.Code)
+	loginFrom(r, "192.0.2.1", "", "alice", "wrong")
+
+	select {
+	case userID := <-notified:
+		assert.Equal(t, alice.ID, userID)
+	case <-time.After(time.Second):
+		t.Fatal("OnAccountLocked was not called")
+	}
+
+	// 锁定期间正确的密码也被拒绝，响应与密码错误逐字节相同
+	locked := loginFrom(r, "192.0.2.1", "", "alice", "alice-password")
+	assert.Equal(t, http.StatusUnauthorized, locked.Code)
+	assert.Equal(t, wrongPassword.Body.Bytes(), locked.Body.Bytes())
+
+	// 不存在的用户名同样被计数和锁定，响应相同
+	unknown := loginFrom(r, "192.0.2.1", "", "nobody", "wrong")
+	assert.Equal(t, wrongPassword.Body.Bytes(), unknown.Body.Bytes())
+	loginFrom(r, "192.0.2.1", "", "nobody", "wrong")
+	unknown = loginFrom(r, "192.0.2.1", "", "nobody", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, wrongPassword.Body.Bytes(), unknown.Body.Bytes())
+
+	// 管理员解锁后可以登录
+	adminToken, _ := loginAs(t, r, "admin", "admin-password")
+	w := authRequest(r, http.MethodPost, fmt.Sprintf("/api/users/%d/unlock", alice.ID), adminToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusOK, loginFrom(r, "192.0.2.1", "", "alice", "alice-password").Code)
+
+	w = authRequest(r, http.MethodPost, "/api/users/9999/unlock", adminToken, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// 默认不信任代理，伪造X-Forwarded-For不能绕过IP锁定
+func TestLoginIPLockoutIgnoresForwardedFor(t *testing.T) {
+	r := newTestRouter(t)
+	updateConfig(func(cfg *config.Config) {
+		cfg.LoginMaxFailures = 100
+		cfg.LoginIPMaxFailures = 2
+	})
+	createTestUser(t, "alice", "alice-password", models.RoleUser)
+
+	loginFrom(r, "192.0.2.1", "198.51.100.1", "bob", "wrong")
+	loginFrom(r, "192.0.2.1", "198.51.100.2", "carol", "wrong")
+
+	assert.Equal(t, http.StatusUnauthorized, loginFrom(r, "192.0.2.1", "198.51.100.3", "alice", "alice-password").Code)
+	assert.Equal(t, http.StatusOK, loginFrom(r, "192.0.2.2", "", "alice", "alice-password").Code)
+}
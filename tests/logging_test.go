@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gin-auth-project/logging"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 将全局日志输出到缓冲区，测试结束后恢复
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, level, "json"))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// 按行解析JSON日志
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLoggerRedactsAndCorrelates(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, "info", "json")
+
+	ctx := logging.WithRequestID(context.Background(), "req-123")
+	logger.InfoContext(ctx, "login",
+		"username", "alice",
+		"password", "hunter2",
+		"refresh_token", "opaque",
+		"Authorization", "Bearer abc",
+		"token_id", "jti-1",
+	)
+	logger.DebugContext(ctx, "hidden")
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, "req-123", entry["request_id"])
+	assert.Equal(t, "alice", entry["username"])
+	assert.Equal(t, "[REDACTED]", entry["password"])
+	assert.Equal(t, "[REDACTED]", entry["refresh_token"])
+	assert.Equal(t, "[REDACTED]", entry["Authorization"])
+	assert.Equal(t, "jti-1", entry["token_id"])
+	assert.NotContains(t, buf.String(), "hunter2")
+
+	assert.Equal(t, slog.LevelDebug, logging.ParseLevel("debug"))
+	assert.Equal(t, slog.LevelInfo, logging.ParseLevel("verbose"))
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := captureLogs(t, "info")
+
+	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware(), middleware.RequestLoggerMiddleware())
+	r.GET("/ping", func(c *gin.Context) {
+		slog.InfoContext(c.Request.Context(), "handling ping")
+		c.String(http.StatusOK, middleware.GetRequestID(c))
+	})
+
+	// 沿用客户端提供的请求ID
+	req, _ := http.NewRequest("GET", "/ping?token=secret-value", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Header().Get(middleware.RequestIDHeader))
+	assert.Equal(t, "abc-123", w.Body.String())
+
+	entries := logEntries(t, buf)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.Equal(t, "abc-123", entry["request_id"])
+	}
+	assert.Equal(t, "HTTP request", entries[1]["msg"])
+	assert.Equal(t, "/ping", entries[1]["path"])
+	assert.EqualValues(t, http.StatusOK, entries[1]["status"])
+	assert.NotContains(t, buf.String(), "secret-value")
+
+	// 不合法的请求ID被替换为新生成的ID
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id\nwith newline")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	generated := w.Header().Get(middleware.RequestIDHeader)
+	assert.Len(t, generated, 32)
+	assert.NotEqual(t, "bad id\nwith newline", generated)
+}
+
+func TestGormLoggerOmitsParameters(t *testing.T) {
+	db := useSQLite(t)
+	buf := captureLogs(t, "debug")
+
+	var user models.User
+	db.WithContext(logging.WithRequestID(context.Background(), "req-sql")).
+		Where("password = ?", "super-secret-hash").First(&user)
+
+	assert.NotContains(t, buf.String(), "super-secret-hash")
+	entries := logEntries(t, buf)
+	require.NotEmpty(t, entries)
+	last := entries[len(entries)-1]
+	assert.Equal(t, "gorm", last["component"])
+	assert.Equal(t, "req-sql", last["request_id"])
+	assert.Contains(t, last["sql"], "password = ?")
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/mailer"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	outbox := mailer.NewMemoryMailer()
+	mailer.Default = outbox
+
+	err := mailer.Send(&mailer.Message{To: "alice@example.com", Subject: "Hello", Body: "Hi Alice"})
+	require.NoError(t, err)
+
+	messages := outbox.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "alice@example.com", messages[0].To)
+	assert.Equal(t, "Hi Alice", messages[0].Body)
+
+	outbox.Reset()
+	assert.Empty(t, outbox.Messages())
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	outbox := mailer.NewFileMailer(dir, "no-reply@example.com")
+
+	err := outbox.Send(&mailer.Message{To: "bob@example.com", Subject: "验证邮箱", Body: "Hi Bob"})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "From: no-reply@example.com\r\n")
+	assert.Contains(t, string(content), "To: bob@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: =?utf-8?q?")
+	assert.True(t, strings.HasSuffix(string(content), "\r\n\r\nHi Bob"))
+}
+
+func TestEmailVerificationToken(t *testing.T) {
+	config.Store(&config.Config{JWTSecret: "test_secret", EmailVerificationExpireHours: 24})
+	user := &models.User{ID: 7, Username: "carol", Email: "carol@example.com"}
+
+	token, err := utils.GenerateEmailVerificationToken(user)
+	require.NoError(t, err)
+
+	claims, err := utils.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, utils.PurposeEmailVerification, claims.Purpose)
+	assert.Equal(t, "carol@example.com", claims.Email)
+	assert.Equal(t, uint(7), claims.UserID)
+	assert.NotEmpty(t, claims.ID)
+}
+
+// 重新发送验证邮件在后台完成，无论邮箱是否存在、是否已验证都返回相同的结果
+func TestResendVerificationEmail(t *testing.T) {
+	r := newTestRouter(t)
+	outbox := mailer.Default.(*mailer.MemoryMailer)
+	createTestUser(t, "verified", "password123", models.RoleUser)
+	pending := createTestUser(t, "pending", "password123", models.RoleUser)
+	require.NoError(t, database.DB.Model(pending).Update("email_verified", false).Error)
+
+	for _, email := range []string{"nobody@example.com", "verified@example.com", "Pending@Example.com"} {
+		w := postJSON(r, "/api/auth/verify-email/resend", models.ResendVerificationRequest{Email: email})
+		assert.Equal(t, http.StatusOK, w.Code, email)
+		assert.Contains(t, w.Body.String(), "If the address belongs to an unverified account", email)
+	}
+
+	require.Eventually(t, func() bool { return len(outbox.Messages()) == 1 }, 2*time.Second, 10*time.Millisecond)
+	msg := outbox.Messages()[0]
+	assert.Equal(t, "pending@example.com", msg.To)
+	assert.Equal(t, "Verify your email address", msg.Subject)
+
+	// 同一邮箱每分钟只能发送一次
+	w := postJSON(r, "/api/auth/verify-email/resend", models.ResendVerificationRequest{Email: "pending@example.com"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, outbox.Messages(), 1)
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/metrics"
+	"gin-auth-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 指标的当前值，计数器返回计数，直方图返回样本数；不存在时返回0
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	next:
+		for _, metric := range family.GetMetric() {
+			matched := 0
+			for _, pair := range metric.GetLabel() {
+				if value, ok := labels[pair.GetName()]; ok {
+					if value != pair.GetValue() {
+						continue next
+					}
+					matched++
+				}
+			}
+			if matched != len(labels) {
+				continue
+			}
+			switch {
+			case metric.Counter != nil:
+				return metric.Counter.GetValue()
+			case metric.Histogram != nil:
+				return float64(metric.Histogram.GetSampleCount())
+			case metric.Gauge != nil:
+				return metric.Gauge.GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(metrics.Middleware())
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/api/users/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	route := map[string]string{"method": "GET", "route": "/api/users/:id", "status": "200"}
+	unmatched := map[string]string{"method": "GET", "route": "unmatched", "status": "404"}
+	before := metricValue(t, "http_request_duration_seconds", route)
+	beforeUnmatched := metricValue(t, "http_request_duration_seconds", unmatched)
+
+	for _, path := range []string{"/api/users/1", "/api/users/2", "/no/such/path"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// 按路由模板聚合，不按实际路径
+	assert.Equal(t, before+2, metricValue(t, "http_request_duration_seconds", route))
+	assert.Equal(t, beforeUnmatched+1, metricValue(t, "http_request_duration_seconds", unmatched))
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `http_request_duration_seconds_count{method="GET",route="/api/users/:id",status="200"}`)
+	assert.Contains(t, w.Body.String(), "auth_logins_total")
+}
+
+func TestAuthMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authHandler, _ := newTestAuthHandler(t)
+	useMemoryCache(t)
+	updateConfig(func(cfg *config.Config) {
+		cfg.LoginMaxFailures = 2
+		cfg.LoginIPMaxFailures = 100
+		cfg.LoginFailureWindowMinutes = 15
+		cfg.LoginLockoutMinutes = 15
+	})
+
+	r := gin.New()
+	r.POST("/register", authHandler.Register)
+	r.POST("/login", authHandler.Login)
+
+	count := func(name, label, value string) float64 {
+		return metricValue(t, name, map[string]string{label: value})
+	}
+	registrations := metricValue(t, "auth_registrations_total", nil)
+	successes := count("auth_logins_total", "result", "success")
+	failures := count("auth_logins_total", "result", "failure")
+	blocked := count("auth_logins_total", "result", "blocked")
+	lockouts := count("auth_lockouts_total", "scope", "account")
+	accessIssued := count("auth_tokens_issued_total", "type", "access")
+	refreshIssued := count("auth_tokens_issued_total", "type", "refresh")
+
+	w := postJSON(r, "/register", models.RegisterRequest{Username: "metrics", Email: "metrics@example.com", Password: "password123"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = postJSON(r, "/login", models.LoginRequest{Username: "metrics", Password: "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// 第二次失败时锁定账号，之后的尝试被拦截
+	for i := 0; i < 2; i++ {
+		w = postJSON(r, "/login", models.LoginRequest{Username: "metrics", Password: "wrong"})
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	w = postJSON(r, "/login", models.LoginRequest{Username: "metrics", Password: "password123"})
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Equal(t, registrations+1, metricValue(t, "auth_registrations_total", nil))
+	assert.Equal(t, successes+1, count("auth_logins_total", "result", "success"))
+	assert.Equal(t, failures+2, count("auth_logins_total", "result", "failure"))
+	assert.Equal(t, blocked+1, count("auth_logins_total", "result", "blocked"))
+	assert.Equal(t, lockouts+1, count("auth_lockouts_total", "scope", "account"))
+	assert.Equal(t, accessIssued+1, count("auth_tokens_issued_total", "type", "access"))
+	assert.Equal(t, refreshIssued+1, count("auth_tokens_issued_total", "type", "refresh"))
+}
+
+func TestStorageMetrics(t *testing.T) {
+	db := useSQLite(t)
+	require.NoError(t, metrics.InstrumentDB(db))
+	// 重复调用不会重复注册
+	require.NoError(t, metrics.InstrumentDB(db))
+
+	queries := metricValue(t, "db_query_duration_seconds", map[string]string{"operation": "query"})
+	var users []models.User
+	require.NoError(t, db.Find(&users).Error)
+	assert.Equal(t, queries+1, metricValue(t, "db_query_duration_seconds", map[string]string{"operation": "query"}))
+	assert.Equal(t, float64(1), metricValue(t, "go_sql_max_open_connections", map[string]string{"db_name": "sqlite"}))
+
+	useMiniredis(t)
+	metrics.InstrumentRedis(database.RedisClient)
+	commands := metricValue(t, "redis_command_duration_seconds", map[string]string{"command": "set"})
+	require.NoError(t, database.SetCache("key", "value", 0))
+	assert.Equal(t, commands+1, metricValue(t, "redis_command_duration_seconds", map[string]string{"command": "set"}))
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 通过接口启用TOTP，返回密钥和恢复码
+func enableTOTP(t *testing.T, r *gin.Engine, token string) (string, []string) {
+	w := authRequest(r, http.MethodPost, "/api/auth/2fa/setup", token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &setup))
+
+	code, err := utils.GenerateTOTPCode(setup.Secret, time.Now())
+	require.NoError(t, err)
+	w = authRequest(r, http.MethodPost, "/api/auth/2fa/confirm", token, models.TOTPCodeRequest{Code: code})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var confirm struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &confirm))
+	require.Len(t, confirm.RecoveryCodes, 10)
+	return setup.Secret, confirm.RecoveryCodes
+}
+
+// 密码登录的第一步，返回待验证令牌
+func loginMFAPending(t *testing.T, r *gin.Engine, username, password string) string {
+	w := postJSON(r, "/api/auth/login", models.LoginRequest{Username: username, Password: password})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+		Token       string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.True(t, response.MFARequired)
+	require.NotEmpty(t, response.MFAToken)
+	assert.Empty(t, response.Token)
+	return response.MFAToken
+}
+
+// 提交第二因素，成功时返回访问令牌
+func verifyMFA(r *gin.Engine, mfaToken string, req models.MFAVerifyRequest) (*httptest.ResponseRecorder, string) {
+	w := authRequest(r, http.MethodPost, "/api/auth/2fa/verify", mfaToken, req)
+	var response struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response.Token
+}
+
+// 下一个时间窗口的验证码，确认启用时已经使用了当前窗口
+func nextTOTPCode(t *testing.T, secret string, steps int) string {
+	code, err := utils.GenerateTOTPCode(secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	require.NoError(t, err)
+	return code
+}
+
+func TestMFALoginFlow(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "alice", "password123", models.RoleUser)
+	token, _ := loginAs(t, r, "alice", "password123")
+	secret, recoveryCodes := enableTOTP(t, r, token)
+
+	// 待验证令牌不能访问其他接口
+	mfaToken := loginMFAPending(t, r, "alice", "password123")
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", mfaToken, nil).Code)
+
+	code := nextTOTPCode(t, secret, 1)
+	resp, access := verifyMFA(r, mfaToken, models.MFAVerifyRequest{Code: code})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", access, nil).Code)
+
+	// 待验证令牌只能使用一次
+	resp, _ = verifyMFA(r, mfaToken, models.MFAVerifyRequest{Code: nextTOTPCode(t, secret, 1)})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	// 同一验证码不能重复使用
+	resp, _ = verifyMFA(r, loginMFAPending(t, r, "alice", "password123"), models.MFAVerifyRequest{Code: code})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Invalid verification code")
+
+	// 恢复码只能使用一次
+	resp, _ = verifyMFA(r, loginMFAPending(t, r, "alice", "password123"), models.MFAVerifyRequest{RecoveryCode: recoveryCodes[0]})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	resp, _ = verifyMFA(r, loginMFAPending(t, r, "alice", "password123"), models.MFAVerifyRequest{RecoveryCode: recoveryCodes[0]})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp, _ = verifyMFA(r, loginMFAPending(t, r, "alice", "password123"), models.MFAVerifyRequest{RecoveryCode: recoveryCodes[1]})
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+// 每个待验证令牌最多尝试5次，缓存不可用时同样限制
+func TestMFAAttemptLimit(t *testing.T) {
+	for _, cacheAvailable := range []bool{true, false} {
+		t.Run("cache="+strconv.FormatBool(cacheAvailable), func(t *testing.T) {
+			r := newTestRouter(t)
+			updateConfig(func(cfg *config.Config) { cfg.LoginMaxFailures = 100 })
+			createTestUser(t, "alice", "password123", models.RoleUser)
+			token, _ := loginAs(t, r, "alice", "password123")
+			secret, _ := enableTOTP(t, r, token)
+
+			mfaToken := loginMFAPending(t, r, "alice", "password123")
+			if !cacheAvailable {
+				database.UseCache(nil)
+			}
+
+			for i := 0; i < 5; i++ {
+				resp, _ := verifyMFA(r, mfaToken, models.MFAVerifyRequest{Code: "000000"})
+				require.Equal(t, http.StatusUnauthorized, resp.Code)
+				assert.Contains(t, resp.Body.String(), "Invalid verification code")
+			}
+			resp, _ := verifyMFA(r, mfaToken, models.MFAVerifyRequest{Code: nextTOTPCode(t, secret, 1)})
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+			assert.Contains(t, resp.Body.String(), "Too many failed attempts")
+
+			// 超过次数后令牌被吊销，正确的验证码也不再接受
+			resp, _ = verifyMFA(r, mfaToken, models.MFAVerifyRequest{Code: nextTOTPCode(t, secret, 1)})
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		})
+	}
+}
+
+// 管理员可以通过角色要求两步验证：未通过两步验证登录时不能使用管理类权限，也不能关闭两步验证
+func TestRoleRequiresMFA(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "admin", "admin-password", models.RoleAdmin)
+	admin, _ := loginAs(t, r, "admin", "admin-password")
+
+	var adminRole models.RoleDefinition
+	require.NoError(t, database.DB.Where("name = ?", models.RoleAdmin).First(&adminRole).Error)
+	requireMFA := true
+	w := authRequest(r, http.MethodPut, "/api/roles/"+strconv.Itoa(int(adminRole.ID)), admin, models.UpdateRoleRequest{RequireMFA: &requireMFA})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"require_mfa":true`)
+
+	w = authRequest(r, http.MethodGet, "/api/users", admin, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Two-factor authentication is required")
+
+	// 登录时提示启用两步验证
+	w = postJSON(r, "/api/auth/login", models.LoginRequest{Username: "admin", Password: "admin-password"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"mfa_enrollment_required":true`)
+
+	secret, _ := enableTOTP(t, r, admin)
+	w = authRequest(r, http.MethodPost, "/api/auth/2fa/disable", admin, models.TOTPDisableRequest{Password: "admin-password", Code: nextTOTPCode(t, secret, 1)})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	resp, mfaAdmin := verifyMFA(r, loginMFAPending(t, r, "admin", "admin-password"), models.MFAVerifyRequest{Code: nextTOTPCode(t, secret, 1)})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/users", mfaAdmin, nil).Code)
+
+	// 不要求两步验证的角色不受影响
+	createTestUser(t, "alice", "password123", models.RoleUser)
+	w = postJSON(r, "/api/auth/login", models.LoginRequest{Username: "alice", Password: "password123"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "mfa_enrollment_required")
+}
+
+// 角色的两步验证要求与权限一起缓存，角色变更使权限缓存失效时一同失效
+func TestRoleRequiresMFACache(t *testing.T) {
+	newTestRouter(t)
+	admin := createTestUser(t, "admin", "admin-password", models.RoleAdmin)
+
+	required, err := database.UserRequiresMFA(admin)
+	require.NoError(t, err)
+	assert.False(t, required)
+
+	// 直接修改数据库时缓存仍然有效
+	require.NoError(t, database.DB.Model(&models.RoleDefinition{}).Where("name = ?", models.RoleAdmin).Update("require_mfa", true).Error)
+	required, err = database.UserRequiresMFA(admin)
+	require.NoError(t, err)
+	assert.False(t, required)
+
+	database.InvalidatePermissions()
+	required, err = database.UserRequiresMFA(admin)
+	require.NoError(t, err)
+	assert.True(t, required)
+}
@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	postgresMigrations, err := database.Migrations(database.DriverPostgres)
+	require.NoError(t, err)
+	sqliteMigrations, err := database.Migrations(database.DriverSQLite)
+	require.NoError(t, err)
+
+	// 每种数据库的迁移版本必须一一对应
+	require.Len(t, sqliteMigrations, len(postgresMigrations))
+	for i := range postgresMigrations {
+		assert.Equal(t, postgresMigrations[i].Version, sqliteMigrations[i].Version)
+		assert.Equal(t, postgresMigrations[i].Name, sqliteMigrations[i].Name)
+	}
+
+	for _, migrations := range [][]database.Migration{postgresMigrations, sqliteMigrations} {
+		checkMigrationsCoverModels(t, migrations)
+	}
+
+	_, err = database.Migrations("mysql")
+	assert.Error(t, err)
+}
+
+// 迁移需要覆盖的全部模型
+var migratedModels = []interface{}{
+	&models.User{},
+	&models.UserProfile{},
+	&models.RefreshToken{},
+	&models.Session{},
+	&models.RecoveryCode{},
+	&models.WebAuthnCredential{},
+	&models.PasswordResetToken{},
+	&models.OAuthClient{},
+	&models.OAuthAuthorizationCode{},
+	&models.OAuthConsent{},
+	&models.PersonalAccessToken{},
+	&models.Permission{},
+	&models.RoleDefinition{},
+	&models.Organization{},
+	&models.Membership{},
+	&models.OrganizationInvitation{},
+}
+
+func checkMigrationsCoverModels(t *testing.T, migrations []database.Migration) {
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		if i > 0 {
+			assert.Greater(t, migration.Version, migrations[i-1].Version)
+		}
+		assert.NotEmpty(t, strings.TrimSpace(migration.Up))
+		assert.NotEmpty(t, strings.TrimSpace(migration.Down))
+	}
+
+	// 迁移创建的表必须覆盖全部模型，避免模型新增字段或表后忘记写迁移
+	var all strings.Builder
+	for _, migration := range migrations {
+		all.WriteString(migration.Up)
+	}
+	schemaSQL := all.String()
+
+	cache := &sync.Map{}
+	for _, model := range migratedModels {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		require.NoError(t, err)
+		assert.Contains(t, schemaSQL, "CREATE TABLE IF NOT EXISTS "+s.Table+" (", s.Name)
+		for _, field := range s.Fields {
+			if field.DBName != "" {
+				assert.Regexp(t, `\n\s+(ADD COLUMN\s+(IF NOT EXISTS\s+)?)?`+field.DBName+`\s`, schemaSQL, s.Table+"."+field.DBName)
+			}
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := database.LoadMigrations(fstest.MapFS{
+		"0002_add_column.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN nickname TEXT;")},
+		"0002_add_column.down.sql": {Data: []byte("ALTER TABLE users DROP COLUMN nickname;")},
+		"0001_init.up.sql":         {Data: []byte("CREATE TABLE users (id BIGSERIAL);")},
+		"0001_init.down.sql":       {Data: []byte("DROP TABLE users;")},
+		"README.md":                {Data: []byte("ignored")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "ALTER TABLE users DROP COLUMN nickname;", migrations[1].Down)
+
+	// 缺少回滚文件
+	_, err = database.LoadMigrations(fstest.MapFS{
+		"0001_init.up.sql": {Data: []byte("CREATE TABLE users (id BIGSERIAL);")},
+	})
+	assert.Error(t, err)
+
+	// 文件名不符合格式
+	_, err = database.LoadMigrations(fstest.MapFS{
+		"init.sql": {Data: []byte("CREATE TABLE users (id BIGSERIAL);")},
+	})
+	assert.Error(t, err)
+}
+
+// 最初由AutoMigrate创建的users和user_profiles表
+type baselineUser struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"uniqueIndex;not null"`
+	Email     string `gorm:"uniqueIndex;not null"`
+	Password  string `gorm:"not null"`
+	Role      string `gorm:"default:'user'"`
+	IsActive  bool   `gorm:"default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+type baselineUserProfile struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    uint
+	FirstName string
+	LastName  string
+	Phone     string
+	Avatar    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	User      baselineUser   `gorm:"foreignKey:UserID"`
+}
+
+func (baselineUserProfile) TableName() string {
+	return "user_profiles"
+}
+
+// 使用只有AutoMigrate最初创建的表的内存数据库，并写入一个已有用户
+func useBaselineSQLite(t *testing.T) (*gorm.DB, *baselineUser) {
+	db, err := database.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	require.NoError(t, db.AutoMigrate(&baselineUser{}, &baselineUserProfile{}))
+	legacy := &baselineUser{Username: "legacy", Email: "legacy@example.com", Password: "x", Role: "user", IsActive: true}
+	require.NoError(t, db.Create(legacy).Error)
+	return db, legacy
+}
+
+// 已由AutoMigrate建表的数据库执行迁移后补齐模型的全部列
+func TestMigrateUpFromAutoMigrateBaseline(t *testing.T) {
+	db, legacy := useBaselineSQLite(t)
+	_, err := database.MigrateUp(db)
+	require.NoError(t, err)
+
+	cache := &sync.Map{}
+	for _, model := range migratedModels {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		require.NoError(t, err)
+		for _, field := range s.Fields {
+			if field.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(s.Table, field.DBName), s.Table+"."+field.DBName)
+			}
+		}
+	}
+
+	var user models.User
+	require.NoError(t, db.First(&user, legacy.ID).Error)
+	assert.Equal(t, "legacy", user.Username)
+	assert.False(t, user.TOTPEnabled)
+	assert.Nil(t, user.PasswordChangedAt)
+}
+
+// 升级前已存在的用户被标记为已验证，升级后注册的用户不受影响，重新执行该迁移也一样
+func TestVerifyExistingUsersMigration(t *testing.T) {
+	db, legacy := useBaselineSQLite(t)
+	_, err := database.MigrateUp(db)
+	require.NoError(t, err)
+
+	fresh := &models.User{Username: "fresh", Email: "fresh@example.com", Password: "x", Role: models.RoleUser, IsActive: true}
+	require.NoError(t, db.Create(fresh).Error)
+
+	check := func() {
+		var verified, unverified models.User
+		require.NoError(t, db.First(&verified, legacy.ID).Error)
+		assert.True(t, verified.EmailVerified)
+		require.NoError(t, db.First(&unverified, fresh.ID).Error)
+		assert.False(t, unverified.EmailVerified)
+	}
+	check()
+
+	reverted, err := database.MigrateDown(db, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, reverted)
+	_, err = database.MigrateUp(db)
+	require.NoError(t, err)
+	check()
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 7636 附录B的示例
+func TestVerifyPKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	assert.True(t, utils.VerifyPKCE(verifier, challenge))
+	assert.False(t, utils.VerifyPKCE(verifier+"x", challenge))
+	assert.False(t, utils.VerifyPKCE("too-short", challenge))
+}
+
+func TestOAuthClientValidation(t *testing.T) {
+	client := models.OAuthClient{
+		RedirectURIs: "https://app.example.com/callback http://localhost:3000/callback",
+		Scopes:       "openid profile",
+	}
+
+	assert.True(t, client.AllowsRedirectURI("https://app.example.com/callback"))
+	assert.False(t, client.AllowsRedirectURI("https://app.example.com/callback/../evil"))
+	assert.False(t, client.AllowsRedirectURI("https://app.example.com"))
+
+	assert.True(t, client.AllowsScopes([]string{"openid", "profile"}))
+	assert.False(t, client.AllowsScopes([]string{"openid", "email"}))
+
+	consent := models.OAuthConsent{Scope: "openid profile"}
+	assert.True(t, consent.Covers([]string{"openid"}))
+	assert.False(t, consent.Covers([]string{"openid", "email"}))
+}
+
+func TestGenerateIDToken(t *testing.T) {
+	config.Store(&config.Config{JWTSecret: "test_secret", JWTExpireHours: 1, OIDCIssuer: "https://id.example.com"})
+	require.NoError(t, utils.InitSigningKeys())
+
+	user := &models.User{ID: 9, Username: "dave", Email: "dave@example.com", Role: models.RoleUser, EmailVerified: true}
+	session := &models.Session{ID: "sid-1", ClientID: "client-1", Scope: "openid profile", AuthMethods: "pwd"}
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	raw, err := utils.GenerateIDToken(user, session, authTime, "nonce-1")
+	require.NoError(t, err)
+
+	claims := &utils.IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test_secret"), nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://id.example.com", claims.Issuer)
+	assert.Equal(t, "9", claims.Subject)
+	assert.Equal(t, jwt.ClaimStrings{"client-1"}, claims.Audience)
+	assert.Equal(t, "nonce-1", claims.Nonce)
+	assert.Equal(t, authTime.Unix(), claims.AuthTime)
+	assert.Equal(t, "dave", claims.PreferredUsername)
+	// 未授权email scope时不包含邮箱
+	assert.Empty(t, claims.Email)
+	assert.Nil(t, claims.EmailVerified)
+
+	info := utils.UserInfo(user, []string{"openid", "email"})
+	assert.Equal(t, "9", info["sub"])
+	assert.Equal(t, "dave@example.com", info["email"])
+	assert.Equal(t, true, info["email_verified"])
+	assert.NotContains(t, info, "preferred_username")
+}
+
+// OAuth会话签发的访问令牌以客户端为受众并携带scope
+func TestOAuthAccessTokenClaims(t *testing.T) {
+	config.Store(&config.Config{JWTSecret: "test_secret", JWTExpireHours: 1, OIDCIssuer: "https://id.example.com"})
+	require.NoError(t, utils.InitSigningKeys())
+
+	user := &models.User{ID: 9, Username: "dave", Role: models.RoleUser}
+	token, err := utils.GenerateToken(user, &models.Session{ID: "sid-2", ClientID: "client-1", Scope: "openid email"})
+	require.NoError(t, err)
+
+	claims, err := utils.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "sid-2", claims.SessionID)
+	assert.Equal(t, "openid email", claims.Scope)
+	assert.Equal(t, jwt.ClaimStrings{"client-1"}, claims.Audience)
+	assert.Equal(t, utils.PurposeOAuthAccess, claims.Purpose)
+}
+
+// OAuth访问令牌只能访问userinfo，不能以用户身份调用 /api 接口；普通访问令牌不能访问userinfo
+func TestOAuthAccessTokenRestrictedToUserInfo(t *testing.T) {
+	r := newTestRouter(t)
+	user := createTestUser(t, "erin", "password123", models.RoleAdmin)
+	sessionToken, _ := loginAs(t, r, "erin", "password123")
+
+	session := &models.Session{
+		ID:         "oauth-session",
+		UserID:     user.ID,
+		ClientID:   "client-1",
+		Scope:      "openid",
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	require.NoError(t, database.DB.Create(session).Error)
+	oauthToken, err := utils.GenerateToken(user, session)
+	require.NoError(t, err)
+
+	w := authRequest(r, "GET", "/oauth/userinfo", oauthToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"sub"`)
+
+	for _, path := range []string{"/api/auth/profile", "/api/users", "/api/auth/sessions", "/api/auth/tokens"} {
+		w = authRequest(r, "GET", path, oauthToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+	w = authRequest(r, "POST", "/api/auth/tokens", oauthToken, gin.H{"name": "stolen"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = authRequest(r, "GET", "/api/auth/profile", sessionToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = authRequest(r, "GET", "/oauth/userinfo", sessionToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrgScopedPermissions(t *testing.T) {
+	assert.True(t, models.IsOrgScopedPermission(models.PermissionUsersRead))
+	assert.True(t, models.IsOrgScopedPermission(models.PermissionOrgMembersManage))
+
+	// 全局资源的权限不能通过组织成员角色获得
+	assert.False(t, models.IsOrgScopedPermission(models.PermissionRolesManage))
+	assert.False(t, models.IsOrgScopedPermission(models.PermissionOAuthClientsManage))
+	assert.False(t, models.IsOrgScopedPermission(models.PermissionUsersDelete))
+	assert.False(t, models.IsOrgScopedPermission(models.PermissionUsersAssignRoles))
+}
+
+func TestTokenCarriesActiveOrganization(t *testing.T) {
+	config.Store(&config.Config{JWTSecret: "test_secret", JWTExpireHours: 1})
+
+	user := &models.User{ID: 1, Username: "alice", Role: models.RoleUser}
+	token, err := utils.GenerateToken(user, &models.Session{ID: "s1", OrgID: 7})
+	require.NoError(t, err)
+
+	claims, err := utils.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), claims.OrgID)
+}
+
+// 组织管理员只通过成员角色获得users:read，权限范围限定在当前组织
+func TestPermissionOrgScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Store(&config.Config{})
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		orgID, _ := strconv.Atoi(c.GetHeader("X-Test-Org"))
+		c.Set("user_id", uint(1))
+		c.Set("claims", &utils.Claims{UserID: 1, OrgID: uint(orgID)})
+		c.Set("permissions", map[string]bool{models.PermissionProtectedRead: true})
+		c.Set("org_permissions", map[string]bool{models.PermissionUsersRead: true})
+		if c.GetHeader("X-Test-Global") != "" {
+			c.Set("permissions", map[string]bool{models.PermissionUsersRead: true})
+		}
+		c.Next()
+	})
+	r.GET("/users", middleware.RequirePermission(models.PermissionUsersRead), func(c *gin.Context) {
+		c.String(http.StatusOK, "%d", middleware.PermissionOrgScope(c, models.PermissionUsersRead))
+	})
+
+	request := func(org string, global bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("X-Test-Org", org)
+		if global {
+			req.Header.Set("X-Test-Global", "1")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("7", false)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "7", w.Body.String())
+
+	// 未选择组织时组织内的权限不生效
+	assert.Equal(t, http.StatusForbidden, request("0", false).Code)
+
+	// 拥有全局权限时不受组织限制
+	w = request("7", true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Body.String())
+}
+
+func TestInvitationIsPending(t *testing.T) {
+	now := time.Now()
+	assert.True(t, (&models.OrganizationInvitation{ExpiresAt: now.Add(time.Hour)}).IsPending())
+	assert.False(t, (&models.OrganizationInvitation{ExpiresAt: now.Add(-time.Hour)}).IsPending())
+	assert.False(t, (&models.OrganizationInvitation{ExpiresAt: now.Add(time.Hour), AcceptedAt: &now}).IsPending())
+}
+
+// 创建组织和组织管理员角色，返回组织
+func setupOrganization(t *testing.T, r *gin.Engine, adminToken string) *models.Organization {
+	w := authRequest(r, http.MethodPost, "/api/roles", adminToken, models.CreateRoleRequest{
+		Name:        "org_admin",
+		Permissions: []string{models.PermissionOrgMembersManage, models.PermissionUsersRead},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	org := &models.Organization{Name: "Acme", Slug: "acme"}
+	require.NoError(t, database.DB.Create(org).Error)
+	return org
+}
+
+func addMember(t *testing.T, org *models.Organization, user *models.User, role models.Role) {
+	require.NoError(t, database.DB.Create(&models.Membership{OrganizationID: org.ID, UserID: user.ID, Role: role}).Error)
+}
+
+// 切换到组织，返回带有组织的访问令牌
+func switchOrganization(t *testing.T, r *gin.Engine, token string, orgID uint) string {
+	w := authRequest(r, http.MethodPost, "/api/auth/orgs/switch", token, models.SwitchOrganizationRequest{OrgID: orgID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Token
+}
+
+func TestMemberChangeGuards(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "admin", "admin-password", models.RoleAdmin)
+	owner := createTestUser(t, "owner", "password123", models.RoleUser)
+	bob := createTestUser(t, "bob", "password123", models.RoleUser)
+	platformAdmin := createTestUser(t, "root", "password123", models.RoleAdmin)
+	admin, _ := loginAs(t, r, "admin", "admin-password")
+
+	org := setupOrganization(t, r, admin)
+	addMember(t, org, owner, "org_admin")
+	addMember(t, org, bob, models.RoleUser)
+	addMember(t, org, platformAdmin, models.RoleUser)
+
+	ownerToken, _ := loginAs(t, r, "owner", "password123")
+	ownerToken = switchOrganization(t, r, ownerToken, org.ID)
+	member := func(user *models.User) string {
+		return fmt.Sprintf("/api/orgs/%d/members/%d", org.ID, user.ID)
+	}
+
+	// 不能修改或移除自己
+	w := authRequest(r, http.MethodPut, member(owner), ownerToken, models.UpdateMembershipRequest{Role: models.RoleUser})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusBadRequest, authRequest(r, http.MethodDelete, member(owner), ownerToken, nil).Code)
+
+	// 响应中是修改后的角色
+	w = authRequest(r, http.MethodPut, member(bob), ownerToken, models.UpdateMembershipRequest{Role: "org_admin"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"role":"org_admin"`)
+
+	// 组织管理员不能修改拥有全局管理类权限的用户
+	w = authRequest(r, http.MethodPut, member(platformAdmin), ownerToken, models.UpdateMembershipRequest{Role: "org_admin"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, http.StatusForbidden, authRequest(r, http.MethodDelete, member(platformAdmin), ownerToken, nil).Code)
+
+	// 平台管理员可以修改，但组织必须保留至少一个组织管理员
+	w = authRequest(r, http.MethodPut, member(bob), admin, models.UpdateMembershipRequest{Role: models.RoleUser})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = authRequest(r, http.MethodPut, member(owner), admin, models.UpdateMembershipRequest{Role: models.RoleUser})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, http.StatusConflict, authRequest(r, http.MethodDelete, member(owner), admin, nil).Code)
+
+	// 修改为同样可以管理成员的角色不受影响
+	w = authRequest(r, http.MethodPut, member(owner), admin, models.UpdateMembershipRequest{Role: "org_admin"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodDelete, member(bob), ownerToken, nil).Code)
+}
+
+func TestRevokeInvitationID(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "admin", "admin-password", models.RoleAdmin)
+	admin, _ := loginAs(t, r, "admin", "admin-password")
+	org := setupOrganization(t, r, admin)
+
+	path := fmt.Sprintf("/api/orgs/%d/invitations", org.ID)
+	w := authRequest(r, http.MethodDelete, path+"/1%20OR%201=1", admin, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusNotFound, authRequest(r, http.MethodDelete, path+"/999", admin, nil).Code)
+
+	w = authRequest(r, http.MethodPost, path, admin, models.InviteMemberRequest{Email: "carol@example.com"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response struct {
+		Invitation models.OrganizationInvitation `json:"invitation"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	w = authRequest(r, http.MethodDelete, path+"/"+strconv.Itoa(int(response.Invitation.ID)), admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/mailer"
+	"gin-auth-project/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var resetLinkPattern = regexp.MustCompile(`https?://\S+`)
+
+// 等待第n封重置邮件并取出其中的令牌
+func waitForResetToken(t *testing.T, outbox *mailer.MemoryMailer, n int) string {
+	require.Eventually(t, func() bool { return len(outbox.Messages()) >= n }, 2*time.Second, 10*time.Millisecond)
+	msg := outbox.Messages()[n-1]
+	assert.Equal(t, "Reset your password", msg.Subject)
+
+	link, err := url.Parse(resetLinkPattern.FindString(msg.Body))
+	require.NoError(t, err)
+	return link.Query().Get("token")
+}
+
+// 忘记密码 → 邮件中的令牌 → 重置密码，之前的访问令牌和刷新令牌失效，重置令牌只能使用一次
+func TestPasswordResetFlow(t *testing.T) {
+	r := newTestRouter(t)
+	outbox := mailer.Default.(*mailer.MemoryMailer)
+	createTestUser(t, "alice", "old-password", models.RoleUser)
+	accessToken, refreshToken := loginAs(t, r, "alice", "old-password")
+
+	// 邮箱不区分大小写，链接指向前端的重置页面
+	w := postJSON(r, "/api/auth/password/forgot", models.ForgotPasswordRequest{Email: "Alice@Example.com"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	token := waitForResetToken(t, outbox, 1)
+	require.NotEmpty(t, token)
+	assert.Contains(t, outbox.Messages()[0].Body, "http://localhost:8080/reset-password?token=")
+
+	// 同一邮箱一分钟内只能申请一次
+	w = postJSON(r, "/api/auth/password/forgot", models.ForgotPasswordRequest{Email: "alice@example.com"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = postJSON(r, "/api/auth/password/reset", models.ResetPasswordRequest{Token: token, Password: "new-password"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", accessToken, nil).Code)
+	w = postJSON(r, "/api/auth/refresh", models.RefreshTokenRequest{RefreshToken: refreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// 令牌不能重复使用
+	w = postJSON(r, "/api/auth/password/reset", models.ResetPasswordRequest{Token: token, Password: "other-password"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, postJSON(r, "/api/auth/login", models.LoginRequest{Username: "alice", Password: "old-password"}).Code)
+	loginAs(t, r, "alice", "new-password")
+}
+
+func TestPasswordResetExpiredToken(t *testing.T) {
+	r := newTestRouter(t)
+	outbox := mailer.Default.(*mailer.MemoryMailer)
+	createTestUser(t, "alice", "old-password", models.RoleUser)
+
+	postJSON(r, "/api/auth/password/forgot", models.ForgotPasswordRequest{Email: "alice@example.com"})
+	token := waitForResetToken(t, outbox, 1)
+	require.NoError(t, database.DB.Model(&models.PasswordResetToken{}).Where("1 = 1").
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	w := postJSON(r, "/api/auth/password/reset", models.ResetPasswordRequest{Token: token, Password: "new-password"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	loginAs(t, r, "alice", "old-password")
+}
+
+// 不存在的邮箱返回相同的结果但不发送邮件；配置了前端页面时链接指向该页面
+func TestForgotPasswordUnknownEmailAndResetURL(t *testing.T) {
+	r := newTestRouter(t)
+	outbox := mailer.Default.(*mailer.MemoryMailer)
+	updateConfig(func(cfg *config.Config) { cfg.PasswordResetURL = "https://app.example.com/account/reset?lang=en" })
+	createTestUser(t, "alice", "old-password", models.RoleUser)
+
+	unknown := postJSON(r, "/api/auth/password/forgot", models.ForgotPasswordRequest{Email: "nobody@example.com"})
+	known := postJSON(r, "/api/auth/password/forgot", models.ForgotPasswordRequest{Email: "alice@example.com"})
+	assert.Equal(t, http.StatusOK, unknown.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+	waitForResetToken(t, outbox, 1)
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, outbox.Messages(), 1)
+	assert.Equal(t, "alice@example.com", outbox.Messages()[0].To)
+	assert.Contains(t, outbox.Messages()[0].Body, "https://app.example.com/account/reset?lang=en&token=")
+}
+
+// iat精度为秒，与修改密码在同一秒内签发的令牌也被拒绝
+func TestTokenIssuedInSameSecondAsPasswordChange(t *testing.T) {
+	r := newTestRouter(t)
+	user := createTestUser(t, "alice", "old-password", models.RoleUser)
+	accessToken, _ := loginAs(t, r, "alice", "old-password")
+	require.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", accessToken, nil).Code)
+
+	// 只修改password_changed_at，会话仍然有效
+	changedAt := time.Now().Truncate(time.Second).Add(999 * time.Millisecond)
+	require.NoError(t, database.DB.Model(user).Update("password_changed_at", changedAt).Error)
+	database.DeleteCache("user:" + strconv.Itoa(int(user.ID)))
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", accessToken, nil).Code)
+}
+
+// 通过个人资料或用户管理接口修改密码后，全部会话和此前签发的访问令牌失效
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "admin", "admin-password", models.RoleAdmin)
+	alice := createTestUser(t, "alice", "old-password", models.RoleUser)
+	admin, _ := loginAs(t, r, "admin", "admin-password")
+
+	access, refresh := loginAs(t, r, "alice", "old-password")
+	otherAccess, otherRefresh := loginAs(t, r, "alice", "old-password")
+	w := authRequest(r, http.MethodPut, "/api/auth/profile", access, models.UpdateUserRequest{Password: "new-password"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var user models.User
+	require.NoError(t, database.DB.First(&user, alice.ID).Error)
+	require.NotNil(t, user.PasswordChangedAt)
+	for _, token := range []string{access, otherAccess} {
+		assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", token, nil).Code)
+	}
+	for _, token := range []string{refresh, otherRefresh} {
+		w, _, _ := refreshTokens(r, token)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	// 管理员修改密码
+	access, refresh = loginAs(t, r, "alice", "new-password")
+	w = authRequest(r, http.MethodPut, "/api/users/"+strconv.Itoa(int(alice.ID)), admin, models.UpdateUserRequest{Password: "admin-set-password"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", access, nil).Code)
+	w, _, _ = refreshTokens(r, refresh)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	loginAs(t, r, "alice", "admin-set-password")
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/middleware"
+	"gin-auth-project/routes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowRateWithRedis(t *testing.T) {
+	server := useMiniredis(t)
+	now := time.Now()
+	server.SetTime(now)
+
+	for i := 0; i < 3; i++ {
+		result := database.AllowRate("test:redis", 3, time.Minute)
+		require.True(t, result.Allowed)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	result := database.AllowRate("test:redis", 3, time.Minute)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.InDelta(t, 20*time.Second, result.RetryAfter, float64(10*time.Millisecond))
+
+	// 每20秒恢复一个请求的配额
+	server.SetTime(now.Add(20 * time.Second))
+	assert.True(t, database.AllowRate("test:redis", 3, time.Minute).Allowed)
+	assert.False(t, database.AllowRate("test:redis", 3, time.Minute).Allowed)
+
+	// 不同的键分别计数
+	assert.True(t, database.AllowRate("test:other", 3, time.Minute).Allowed)
+}
+
+// Redis不可用时回退到进程内限流
+func TestAllowRateFallsBackToMemory(t *testing.T) {
+	server := useMiniredis(t)
+	server.Close()
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, database.AllowRate("test:fallback", 2, time.Minute).Allowed)
+	}
+	assert.False(t, database.AllowRate("test:fallback", 2, time.Minute).Allowed)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useMiniredis(t)
+	config.Store(&config.Config{RateLimitEnabled: true})
+
+	r := gin.New()
+	r.POST("/login", middleware.RateLimitMiddleware("login", func() int { return 2 }, time.Minute, middleware.KeyByIP), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("192.0.2.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	assert.Equal(t, http.StatusOK, request("192.0.2.1").Code)
+
+	w = request("192.0.2.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	// 其他IP不受影响
+	assert.Equal(t, http.StatusOK, request("192.0.2.2").Code)
+}
+
+// 默认不信任代理，伪造X-Forwarded-For不能获得新的限流配额；只有来自信任代理的请求按X-Forwarded-For计数
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	login := func(r http.Handler, remoteIP, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		req.RemoteAddr = remoteIP + ":1234"
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	newTestRouter(t)
+	updateConfig(func(cfg *config.Config) {
+		cfg.RateLimitEnabled = true
+		cfg.RateLimitAuthPerMinute = 2
+	})
+	r := routes.SetupRoutes()
+
+	assert.NotEqual(t, http.StatusTooManyRequests, login(r, "192.0.2.1", ""))
+	assert.NotEqual(t, http.StatusTooManyRequests, login(r, "192.0.2.1", ""))
+	assert.Equal(t, http.StatusTooManyRequests, login(r, "192.0.2.1", "198.51.100.7"))
+	assert.Equal(t, http.StatusTooManyRequests, login(r, "192.0.2.1", "198.51.100.8, 192.0.2.1"))
+
+	// 信任的代理转发的请求按原始客户端IP计数
+	updateConfig(func(cfg *config.Config) { cfg.TrustedProxies = []string{"10.0.0.0/8"} })
+	r = routes.SetupRoutes()
+	assert.Equal(t, http.StatusTooManyRequests, login(r, "10.0.0.1", "192.0.2.1"))
+	assert.NotEqual(t, http.StatusTooManyRequests, login(r, "10.0.0.1", "198.51.100.7"))
+}
+
+// 刷新令牌接口与登录接口一样按IP限流
+func TestRateLimitRefreshToken(t *testing.T) {
+	newTestRouter(t)
+	updateConfig(func(cfg *config.Config) {
+		cfg.RateLimitEnabled = true
+		cfg.RateLimitAuthPerMinute = 2
+	})
+	r := routes.SetupRoutes()
+
+	refresh := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/refresh", strings.NewReader(`{"refresh_token":"guess"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.50:1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, refresh())
+	assert.Equal(t, http.StatusUnauthorized, refresh())
+	assert.Equal(t, http.StatusTooManyRequests, refresh())
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gin-auth-project/config"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRolePermissions(t *testing.T) {
+	assert.ElementsMatch(t, models.AllPermissions(), models.DefaultRolePermissions[models.RoleAdmin])
+	assert.Equal(t, []string{models.PermissionProtectedRead}, models.DefaultRolePermissions[models.RoleUser])
+
+	assert.True(t, models.IsPrivilegedPermission(models.PermissionUsersDelete))
+	assert.False(t, models.IsPrivilegedPermission(models.PermissionProtectedRead))
+	assert.False(t, models.IsKnownPermission("users:explode"))
+}
+
+func TestUserRoleNames(t *testing.T) {
+	user := models.User{
+		Role:  models.RoleUser,
+		Roles: []models.RoleDefinition{{Name: "support"}, {Name: models.RoleUser}},
+	}
+	assert.Equal(t, []models.Role{models.RoleUser, "support"}, user.RoleNames())
+}
+
+// 支持人员可以停用用户但不能删除用户
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Store(&config.Config{})
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("claims", &utils.Claims{UserID: 1, AuthMethods: []string{"pwd"}})
+		c.Set("permissions", map[string]bool{
+			models.PermissionUsersRead:       true,
+			models.PermissionUsersDeactivate: true,
+		})
+		if scopes := c.GetHeader("X-Test-PAT"); scopes != "" {
+			c.Set("access_token", &models.PersonalAccessToken{Scopes: scopes})
+		}
+		c.Next()
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.PATCH("/users/:id/status", middleware.RequirePermission(models.PermissionUsersDeactivate), ok)
+	r.DELETE("/users/:id", middleware.RequirePermission(models.PermissionUsersDelete), ok)
+
+	request := func(method, path, pat string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if pat != "" {
+			req.Header.Set("X-Test-PAT", pat)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request(http.MethodPatch, "/users/2/status", ""))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodDelete, "/users/2", ""))
+
+	// 管理类权限要求个人访问令牌具有admin权限范围
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPatch, "/users/2/status", "write"))
+	assert.Equal(t, http.StatusOK, request(http.MethodPatch, "/users/2/status", "admin"))
+
+	// 开启REQUIRE_ADMIN_MFA时需要通过两步验证
+	updateConfig(func(cfg *config.Config) { cfg.RequireAdminMFA = true })
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPatch, "/users/2/status", ""))
+}
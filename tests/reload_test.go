@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 只有可重新加载的配置项取新值，需要重启的配置项保持不变
+func TestConfigReload(t *testing.T) {
+	previous := config.Current()
+	t.Cleanup(func() { config.Store(previous) })
+
+	file := writeFile(t, "config.yaml", "jwt_expire_hours: 1\nserver_port: 8080\ndb_driver: sqlite\n")
+	require.NoError(t, config.Init(config.Options{File: file}))
+	loaded := config.Current()
+
+	require.NoError(t, os.WriteFile(file, []byte("jwt_expire_hours: 2\nserver_port: 9090\ndb_driver: sqlite\nlog_level: debug\n"), 0600))
+	next, changed, restart, err := config.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.JWTExpireHours)
+	assert.Equal(t, "debug", next.LogLevel)
+	assert.Equal(t, "8080", next.ServerPort)
+	assert.ElementsMatch(t, []string{"JWT_EXPIRE_HOURS", "LOG_LEVEL"}, changed)
+	assert.Equal(t, []string{"SERVER_PORT"}, restart)
+
+	// 新的快照在替换之前不影响当前配置
+	assert.Same(t, loaded, config.Current())
+	assert.Equal(t, 1, loaded.JWTExpireHours)
+
+	// 校验失败时返回错误
+	require.NoError(t, os.WriteFile(file, []byte("jwt_expire_hours: 0\ndb_driver: sqlite\n"), 0600))
+	_, _, _, err = config.Reload()
+	assert.ErrorContains(t, err, "JWT_EXPIRE_HOURS must be positive")
+}
+
+// 合并后的快照需要通过校验：去掉签名密钥后需要使用JWT_SECRET，而修改后的JWT_SECRET要重启才生效
+func TestConfigReloadValidatesMergedSnapshot(t *testing.T) {
+	previous := config.Current()
+	t.Cleanup(func() { config.Store(previous) })
+
+	file := writeFile(t, "config.yaml", "server_mode: release\ndb_driver: sqlite\njwt_signing_keys: [keys/current.pem]\n")
+	require.NoError(t, config.Init(config.Options{File: file}))
+	require.Equal(t, "default_jwt_secret", config.Current().JWTSecret)
+
+	// 单独加载新的配置文件可以通过校验
+	content := "server_mode: release\ndb_driver: sqlite\njwt_secret: " + strings.Repeat("s", 32) + "\n"
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	_, err := config.Load(config.Options{File: file})
+	require.NoError(t, err)
+
+	_, _, _, err = config.Reload()
+	assert.ErrorContains(t, err, "JWT_SECRET must be set in release mode")
+}
+
+// 运行中修改配置文件或发送SIGHUP后重新加载，无效的配置被拒绝
+func TestServeReloadsConfig(t *testing.T) {
+	t.Cleanup(func() { utils.UseSigningKeys(nil) })
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyPath := writeSigningKey(t, t.TempDir(), "reloaded", key)
+
+	file := writeFile(t, "config.yaml", "cors_allowed_origins: [https://a.example.com]\n")
+	baseURL, exitCode := startServer(t, "--config", file)
+	defer stopServer(t, exitCode)
+
+	allowedOrigin := func(origin string) string {
+		req, _ := http.NewRequest("GET", baseURL+"/livez", nil)
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.Header.Get("Access-Control-Allow-Origin")
+	}
+	assert.Equal(t, "https://a.example.com", allowedOrigin("https://a.example.com"))
+	assert.Empty(t, allowedOrigin("https://b.example.com"))
+	assert.Nil(t, utils.CurrentSigningKeys())
+
+	// 修改配置文件后自动重新加载，包括签名密钥
+	require.NoError(t, os.WriteFile(file, []byte(
+		"cors_allowed_origins: [https://a.example.com, https://b.example.com]\n"+
+			"jwt_expire_hours: 2\n"+
+			"jwt_signing_keys: ["+keyPath+"]\n"), 0600))
+	require.Eventually(t, func() bool {
+		return allowedOrigin("https://b.example.com") == "https://b.example.com"
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, 2, config.Current().JWTExpireHours)
+	require.NotNil(t, utils.CurrentSigningKeys())
+	assert.Equal(t, "reloaded", utils.CurrentSigningKeys().Active().ID)
+
+	// 无效的配置和无法加载的密钥都被拒绝，保留当前配置；SIGHUP不会终止进程
+	loaded := config.Current()
+	require.NoError(t, os.WriteFile(file, []byte("jwt_expire_hours: 0\n"), 0600))
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	time.Sleep(500 * time.Millisecond)
+	assert.Same(t, loaded, config.Current())
+
+	require.NoError(t, os.WriteFile(file, []byte("jwt_signing_keys: [/nonexistent.pem]\n"), 0600))
+	time.Sleep(500 * time.Millisecond)
+	assert.Same(t, loaded, config.Current())
+	assert.Equal(t, "reloaded", utils.CurrentSigningKeys().Active().ID)
+	assert.Equal(t, "https://b.example.com", allowedOrigin("https://b.example.com"))
+}
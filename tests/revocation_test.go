@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeTokenWithoutRedis(t *testing.T) {
+	// Redis不可用时使用进程内黑名单
+	database.UseRedis(nil)
+
+	assert.False(t, database.IsTokenRevoked("jti-active"))
+
+	database.RevokeToken("jti-active", time.Now().Add(time.Hour))
+	assert.True(t, database.IsTokenRevoked("jti-active"))
+	assert.False(t, database.IsTokenRevoked("jti-other"))
+}
+
+func TestRevokeExpiredToken(t *testing.T) {
+	database.UseRedis(nil)
+
+	// 已过期的令牌无需加入黑名单
+	err := database.RevokeToken("jti-expired", time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, database.IsTokenRevoked("jti-expired"))
+}
+
+// 一个实例吊销的令牌对其他实例同样生效；Redis查询失败时默认视为已吊销
+func TestRevokeTokenAcrossInstances(t *testing.T) {
+	t.Cleanup(database.ResetLocalRevocations)
+	server := useMiniredis(t)
+
+	require.NoError(t, database.RevokeToken("jti-shared", time.Now().Add(time.Hour)))
+	assert.True(t, server.Exists("blacklist:jti-shared"))
+
+	// 新实例的进程内黑名单为空，只能从Redis得知吊销
+	database.ResetLocalRevocations()
+	assert.True(t, database.IsTokenRevoked("jti-shared"))
+	assert.False(t, database.IsTokenRevoked("jti-other"))
+
+	database.ResetLocalRevocations()
+	server.Close()
+	assert.True(t, database.IsTokenRevoked("jti-shared"))
+	assert.True(t, database.IsTokenRevoked("jti-other"))
+
+	// 设置REVOCATION_FAIL_OPEN后只检查进程内黑名单
+	previous := config.Current()
+	t.Cleanup(func() { config.Store(previous) })
+	updateConfig(func(c *config.Config) { c.RevocationFailOpen = true })
+	assert.False(t, database.IsTokenRevoked("jti-other"))
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// 仍是用户主角色的角色不能删除，查询失败时返回500而不是直接删除
+func TestDeleteRole(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "admin", "password123", models.RoleAdmin)
+	admin, _ := loginAs(t, r, "admin", "password123")
+
+	w := authRequest(r, http.MethodPost, "/api/roles", admin, models.CreateRoleRequest{
+		Name:        "auditor",
+		Permissions: []string{models.PermissionUsersRead},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Role models.RoleResponse `json:"role"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	role := created.Role
+	path := "/api/roles/" + strconv.Itoa(int(role.ID))
+
+	auditor := createTestUser(t, "auditor", "password123", "auditor")
+	w = authRequest(r, http.MethodDelete, path, admin, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// 统计使用该角色的用户失败
+	failCount := func(db *gorm.DB) {
+		if _, ok := db.Statement.Dest.(*int64); ok && db.Statement.Table == "users" {
+			db.AddError(errors.New("count failed"))
+		}
+	}
+	require.NoError(t, database.DB.Callback().Query().Before("gorm:query").Register("test:fail_user_count", failCount))
+	require.NoError(t, database.DB.Model(auditor).Update("role", models.RoleUser).Error)
+	w = authRequest(r, http.MethodDelete, path, admin, nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var count int64
+	require.NoError(t, database.DB.Model(&models.RoleDefinition{}).Where("id = ?", role.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	require.NoError(t, database.DB.Callback().Query().Remove("test:fail_user_count"))
+	w = authRequest(r, http.MethodDelete, path, admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gin-auth-project/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 查询会话列表
+func listSessions(t *testing.T, r *gin.Engine, path, token string) []models.SessionResponse {
+	w := authRequest(r, http.MethodGet, path, token, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Sessions []models.SessionResponse `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Sessions
+}
+
+// 当前会话的ID
+func currentSessionID(t *testing.T, sessions []models.SessionResponse) string {
+	for _, session := range sessions {
+		if session.Current {
+			return session.ID
+		}
+	}
+	t.Fatal("no current session")
+	return ""
+}
+
+func TestMySessions(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "alice", "password123", models.RoleUser)
+	laptop, _ := loginAs(t, r, "alice", "password123")
+	phone, _ := loginAs(t, r, "alice", "password123")
+	tablet, _ := loginAs(t, r, "alice", "password123")
+
+	sessions := listSessions(t, r, "/api/auth/sessions", laptop)
+	require.Len(t, sessions, 3)
+	laptopID := currentSessionID(t, sessions)
+	phoneID := currentSessionID(t, listSessions(t, r, "/api/auth/sessions", phone))
+	assert.NotEqual(t, laptopID, phoneID)
+
+	// 吊销单个会话后其访问令牌立即失效
+	w := authRequest(r, http.MethodDelete, "/api/auth/sessions/"+phoneID, laptop, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", phone, nil).Code)
+	assert.Len(t, listSessions(t, r, "/api/auth/sessions", laptop), 2)
+
+	w = authRequest(r, http.MethodDelete, "/api/auth/sessions/unknown", laptop, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 吊销其他会话，保留当前会话
+	w = authRequest(r, http.MethodDelete, "/api/auth/sessions", laptop, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"message":"Other sessions revoked successfully","revoked":1}`, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", tablet, nil).Code)
+
+	sessions = listSessions(t, r, "/api/auth/sessions", laptop)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, laptopID, sessions[0].ID)
+}
+
+// 不能吊销其他用户的会话
+func TestRevokeOtherUsersSession(t *testing.T) {
+	r := newTestRouter(t)
+	createTestUser(t, "alice", "password123", models.RoleUser)
+	createTestUser(t, "bob", "password123", models.RoleUser)
+	alice, _ := loginAs(t, r, "alice", "password123")
+	bob, _ := loginAs(t, r, "bob", "password123")
+	bobSession := currentSessionID(t, listSessions(t, r, "/api/auth/sessions", bob))
+
+	w := authRequest(r, http.MethodDelete, "/api/auth/sessions/"+bobSession, alice, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", bob, nil).Code)
+}
+
+func TestAdminUserSessions(t *testing.T) {
+	r := newTestRouter(t)
+	adminUser := createTestUser(t, "admin", "admin-password", models.RoleAdmin)
+	alice := createTestUser(t, "alice", "password123", models.RoleUser)
+	admin, _ := loginAs(t, r, "admin", "admin-password")
+	first, _ := loginAs(t, r, "alice", "password123")
+	second, _ := loginAs(t, r, "alice", "password123")
+
+	path := fmt.Sprintf("/api/users/%d/sessions", alice.ID)
+	sessions := listSessions(t, r, path, admin)
+	require.Len(t, sessions, 2)
+	for _, session := range sessions {
+		assert.False(t, session.Current)
+	}
+
+	// 普通用户不能管理其他用户的会话
+	assert.Equal(t, http.StatusForbidden, authRequest(r, http.MethodGet, path, first, nil).Code)
+
+	firstID := currentSessionID(t, listSessions(t, r, "/api/auth/sessions", first))
+	w := authRequest(r, http.MethodDelete, path+"/"+firstID, admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", first, nil).Code)
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", second, nil).Code)
+
+	w = authRequest(r, http.MethodDelete, path, admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"message":"Sessions revoked successfully","revoked":1}`, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/auth/profile", second, nil).Code)
+	assert.Empty(t, listSessions(t, r, path, admin))
+
+	// 管理员吊销自己的所有会话时保留当前会话
+	w = authRequest(r, http.MethodDelete, fmt.Sprintf("/api/users/%d/sessions", adminUser.ID), admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", admin, nil).Code)
+
+	assert.Equal(t, http.StatusBadRequest, authRequest(r, http.MethodGet, "/api/users/abc/sessions", admin, nil).Code)
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// 使用内存中的SQLite数据库并执行迁移，测试结束后恢复
+func useSQLite(t *testing.T) *gorm.DB {
+	db, err := database.OpenSQLite(":memory:")
+	require.NoError(t, err)
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		database.DB = previous
+	})
+
+	_, err = database.MigrateUp(db)
+	require.NoError(t, err)
+	return db
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	db := useSQLite(t)
+	assert.True(t, db.Migrator().HasTable("users"))
+
+	// 重复执行不会再次迁移
+	applied, err := database.MigrateUp(db)
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+
+	statuses, err := database.MigrationStatuses(db)
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, status.Name)
+	}
+
+	reverted, err := database.MigrateDown(db, len(statuses))
+	require.NoError(t, err)
+	assert.Equal(t, len(statuses), reverted)
+	assert.False(t, db.Migrator().HasTable("users"))
+
+	applied, err = database.MigrateUp(db)
+	require.NoError(t, err)
+	assert.Equal(t, len(statuses), applied)
+}
+
+func TestSQLiteRepositories(t *testing.T) {
+	db := useSQLite(t)
+	require.NoError(t, database.SeedRBAC())
+	ctx := context.Background()
+	config.Store(&config.Config{JWTSecret: "test_secret", JWTExpireHours: 1, RefreshTokenExpireHours: 24})
+
+	userRepo := repository.NewUserRepository(db)
+	tokenStore := repository.NewTokenStore(db)
+	users := services.NewUserService(userRepo, tokenStore)
+	auth := services.NewAuthService(userRepo, tokenStore)
+
+	dave, err := auth.Register(ctx, "dave", "dave@example.com", "secret123")
+	require.NoError(t, err)
+	_, err = auth.Register(ctx, "dave", "other@example.com", "secret123")
+	assert.ErrorIs(t, err, services.ErrUsernameTaken)
+
+	// 角色必须存在于roles表中
+	_, err = users.Update(ctx, dave, services.UpdateUserInput{Role: "ghost"})
+	assert.ErrorIs(t, err, services.ErrUnknownRole)
+	_, err = users.Update(ctx, dave, services.UpdateUserInput{Role: models.RoleAdmin})
+	require.NoError(t, err)
+
+	found, err := users.FindByLogin(ctx, "dave@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, found.Role)
+
+	permissions, err := users.Permissions(ctx, found)
+	require.NoError(t, err)
+	assert.Contains(t, permissions, models.PermissionRolesManage)
+
+	list, total, err := users.List(ctx, 0, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, list, 1)
+
+	// 刷新令牌轮换在SQLite中同样是原子的
+	session, err := auth.StartSession(ctx, found, services.SessionInfo{Device: "cli"}, nil)
+	require.NoError(t, err)
+	tokens, err := auth.IssueTokens(ctx, found, session)
+	require.NoError(t, err)
+	_, _, _, err = auth.RefreshTokens(ctx, tokens.RefreshToken)
+	require.NoError(t, err)
+	_, _, _, err = auth.RefreshTokens(ctx, tokens.RefreshToken)
+	assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+
+	stored, err := tokenStore.FindSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.IsActive())
+}
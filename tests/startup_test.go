@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"gin-auth-project/cli"
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 本机当前未被占用的端口
+func freePort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func useRedisConfig(t *testing.T, port string) {
+	previous := config.Current()
+	config.Store(&config.Config{RedisHost: "127.0.0.1", RedisPort: port})
+	t.Cleanup(func() {
+		if database.RedisClient != nil {
+			database.RedisClient.Close()
+		}
+		database.UseRedis(nil)
+		config.Store(previous)
+	})
+}
+
+// Redis晚于应用启动时重试直到连接成功
+func TestInitCacheWaitsForRedis(t *testing.T) {
+	port := freePort(t)
+	useRedisConfig(t, port)
+
+	server := miniredis.NewMiniRedis()
+	t.Cleanup(server.Close)
+	go func() {
+		time.Sleep(700 * time.Millisecond)
+		server.StartAddr("127.0.0.1:" + port)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, database.InitCache(ctx))
+	assert.NotNil(t, database.RedisClient)
+	require.NoError(t, database.SetCache("key", "value", time.Minute))
+}
+
+// 超过启动期限时返回错误而不是退出进程
+func TestInitCacheDeadline(t *testing.T) {
+	useRedisConfig(t, freePort(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := database.InitCache(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "redis not ready")
+	assert.Nil(t, database.RedisClient)
+}
+
+// 数据库未就绪时重试到ctx结束，失败的连接不会替换当前连接
+func TestInitDatabaseDeadline(t *testing.T) {
+	previous, previousDB := config.Current(), database.DB
+	t.Cleanup(func() {
+		config.Store(previous)
+		database.DB = previousDB
+	})
+	database.DB = nil
+	config.Store(&config.Config{DBDriver: "postgres", DBHost: "127.0.0.1", DBPort: freePort(t), DBUser: "postgres", DBName: "app", DBSSLMode: "disable"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	err := database.InitDatabase(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database not ready")
+	assert.Less(t, time.Since(start), 3*time.Second)
+	assert.Nil(t, database.DB)
+
+	// 已取消的ctx不再尝试连接
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	config.Store(&config.Config{DBDriver: "sqlite", SQLitePath: filepath.Join(t.TempDir(), "app.db")})
+	assert.ErrorIs(t, database.Connect(cancelled), context.Canceled)
+	assert.Nil(t, database.DB)
+}
+
+// 使用临时的SQLite数据库和进程内缓存运行serve命令，返回服务地址和退出码
+func startServer(t *testing.T, args ...string) (string, <-chan int) {
+	previousConfig, previousDB, previousLogger := config.Current(), database.DB, slog.Default()
+	t.Cleanup(func() {
+		config.Store(previousConfig)
+		database.DB = previousDB
+		slog.SetDefault(previousLogger)
+		database.UseCache(nil)
+	})
+
+	port := freePort(t)
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "serve.db"))
+	t.Setenv("REDIS_HOST", "")
+	t.Setenv("SERVER_PORT", port)
+	t.Setenv("SERVER_MODE", "test")
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("MAIL_TRANSPORT", "memory")
+
+	exitCode := make(chan int, 1)
+	go func() { exitCode <- cli.Run(append(args, "serve")) }()
+
+	// 等待服务开始接收请求
+	baseURL := "http://127.0.0.1:" + port
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(baseURL + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 50*time.Millisecond)
+	return baseURL, exitCode
+}
+
+// 发送SIGTERM并等待服务退出
+func stopServer(t *testing.T, exitCode <-chan int) {
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	select {
+	case code := <-exitCode:
+		assert.Equal(t, 0, code)
+	case <-time.After(10 * time.Second):
+		t.Fatal("server did not stop after SIGTERM")
+	}
+}
+
+// 收到SIGTERM后停止服务并关闭数据库连接，退出码为0
+func TestServeGracefulShutdown(t *testing.T) {
+	baseURL, exitCode := startServer(t)
+	stopServer(t, exitCode)
+
+	_, err := http.Get(baseURL + "/readyz")
+	assert.Error(t, err)
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	assert.Error(t, sqlDB.Ping())
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gin-auth-project/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 附录B的测试密钥 "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range cases {
+		code, err := utils.GenerateTOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := utils.ValidateTOTPCode(rfc6238Secret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111109/30), step)
+
+	// 允许一个时间窗口的时钟偏差
+	_, ok = utils.ValidateTOTPCode(rfc6238Secret, "081804", now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	_, ok = utils.ValidateTOTPCode(rfc6238Secret, "081804", now.Add(2*time.Minute))
+	assert.False(t, ok)
+
+	_, ok = utils.ValidateTOTPCode(rfc6238Secret, "000000", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := utils.TOTPURI("Gin Auth", "alice", rfc6238Secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Gin%20Auth:alice?"))
+	assert.Contains(t, uri, "secret="+rfc6238Secret)
+	assert.Contains(t, uri, "issuer=Gin+Auth")
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := utils.GenerateRecoveryCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, 11)
+	assert.Equal(t, code, utils.NormalizeRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))))
+}
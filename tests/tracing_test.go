@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/middleware"
+	"gin-auth-project/models"
+	"gin-auth-project/services"
+	"gin-auth-project/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// 将span记录在内存中，测试结束后恢复全局TracerProvider
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+	return recorder
+}
+
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) string {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracingLoginSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := useSpanRecorder(t)
+	authHandler, users := newTestAuthHandler(t)
+	_, err := users.Create(context.Background(), services.CreateUserInput{Username: "tracy", Email: "tracy@example.com", Password: "s3cret-pass"})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(middleware.RequestIDMiddleware(), tracing.Middleware(), tracing.HandlerMiddleware())
+	r.POST("/api/auth/login", authHandler.Login)
+
+	// 上游网关传入的链路上下文
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID := "00f067aa0ba902b7"
+	login := func(password string) int {
+		req, _ := http.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"username":"tracy","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, login("s3cret-pass"))
+	spans := recorder.Ended()
+	server := findSpan(spans, "POST /api/auth/login")
+	require.NotNil(t, server)
+	assert.Equal(t, traceID, server.SpanContext().TraceID().String())
+	assert.Equal(t, parentID, server.Parent().SpanID().String())
+	assert.Equal(t, "200", spanAttribute(server, "http.response.status_code"))
+	assert.NotEmpty(t, spanAttribute(server, "request_id"))
+
+	handler := findSpan(spans, "AuthHandler.Login")
+	require.NotNil(t, handler)
+	assert.Equal(t, server.SpanContext().SpanID(), handler.Parent().SpanID())
+	assert.Equal(t, tracing.LoginSuccess, spanAttribute(handler, "auth.login.outcome"))
+
+	require.Equal(t, http.StatusUnauthorized, login("wrong-pass"))
+	spans = recorder.Ended()
+	assert.Equal(t, tracing.LoginFailure, spanAttribute(spans[len(spans)-2], "auth.login.outcome"))
+
+	// 登录名和密码不会出现在任何span中
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			value := attr.Value.Emit()
+			assert.NotContains(t, value, "s3cret-pass")
+			assert.NotContains(t, value, "wrong-pass")
+			assert.NotContains(t, value, "tracy")
+		}
+	}
+}
+
+func TestTracingStorageSpans(t *testing.T) {
+	recorder := useSpanRecorder(t)
+	db := useSQLite(t)
+	require.NoError(t, tracing.InstrumentDB(db))
+	useMiniredis(t)
+	tracing.InstrumentRedis(database.RedisClient)
+
+	// 没有请求上下文的语句不记录span
+	var users []models.User
+	require.NoError(t, db.Find(&users).Error)
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	require.NoError(t, db.WithContext(ctx).Where("username = ?", "secret-name").Find(&users).Error)
+	_, err := database.RecordLoginFailure(ctx, "user:1", 5, time.Minute, time.Minute)
+	require.NoError(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	query := findSpan(spans, "gorm.query")
+	require.NotNil(t, query)
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Equal(t, "users", spanAttribute(query, "db.collection.name"))
+	assert.Contains(t, spanAttribute(query, "db.query.text"), "username = ?")
+	assert.NotContains(t, spanAttribute(query, "db.query.text"), "secret-name")
+
+	script := findSpan(spans, "redis.evalsha")
+	require.NotNil(t, script)
+	assert.Equal(t, parent.SpanContext().SpanID(), script.Parent().SpanID())
+	assert.Equal(t, "redis", spanAttribute(script, "db.system"))
+}
+
+func TestTracingFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := tracing.Init(&config.Config{
+		TracingExporter:    tracing.ExporterFile,
+		TracingFile:        path,
+		TracingSampleRatio: 1,
+		TracingServiceName: "gin-auth-test",
+	})
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "exported-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "exported-span")
+	assert.Contains(t, string(data), "gin-auth-test")
+
+	_, err = tracing.Init(&config.Config{TracingExporter: "zipkin"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/models"
+	"gin-auth-project/repository"
+	"gin-auth-project/services"
+	"gin-auth-project/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserServiceCreateAndUpdate(t *testing.T) {
+	users := services.NewUserService(repository.NewMemoryUserRepository(), repository.NewMemoryTokenStore())
+	ctx := context.Background()
+
+	alice, err := users.Create(ctx, services.CreateUserInput{Username: "alice", Email: "alice@example.com", Password: "secret123"})
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUser, alice.Role)
+	assert.True(t, alice.IsActive)
+	assert.NotEqual(t, "secret123", alice.Password)
+	assert.True(t, utils.CheckPassword("secret123", alice.Password))
+
+	_, err = users.Create(ctx, services.CreateUserInput{Username: "alice", Email: "other@example.com", Password: "secret123"})
+	assert.ErrorIs(t, err, services.ErrUsernameTaken)
+	_, err = users.Create(ctx, services.CreateUserInput{Username: "bob", Email: "alice@example.com", Password: "secret123"})
+	assert.ErrorIs(t, err, services.ErrEmailTaken)
+	_, err = users.Create(ctx, services.CreateUserInput{Username: "bob", Email: "bob@example.com", Password: "secret123", Role: "ghost"})
+	assert.ErrorIs(t, err, services.ErrUnknownRole)
+
+	bob, err := users.Create(ctx, services.CreateUserInput{Username: "bob", Email: "bob@example.com", Password: "secret123"})
+	require.NoError(t, err)
+
+	// 邮箱不能与其他用户重复，修改邮箱后需要重新验证
+	_, err = users.Update(ctx, bob, services.UpdateUserInput{Email: "alice@example.com"})
+	assert.ErrorIs(t, err, services.ErrEmailTaken)
+
+	result, err := users.Update(ctx, bob, services.UpdateUserInput{Email: "robert@example.com", Role: models.RoleAdmin})
+	require.NoError(t, err)
+	assert.True(t, result.EmailChanged)
+	assert.False(t, bob.EmailVerified)
+	assert.Equal(t, models.RoleAdmin, bob.Role)
+
+	_, err = users.Update(ctx, bob, services.UpdateUserInput{Role: "ghost"})
+	assert.ErrorIs(t, err, services.ErrUnknownRole)
+
+	require.NoError(t, users.SetActive(ctx, bob, false))
+	stored, err := users.Get(ctx, bob.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.IsActive)
+
+	require.NoError(t, users.Delete(ctx, bob))
+	_, err = users.Get(ctx, bob.ID)
+	assert.ErrorIs(t, err, services.ErrUserNotFound)
+}
+
+func TestAuthServiceRefreshReuse(t *testing.T) {
+	config.Store(&config.Config{JWTSecret: "test_secret", JWTExpireHours: 1, RefreshTokenExpireHours: 24})
+
+	ctx := context.Background()
+	userRepo := repository.NewMemoryUserRepository()
+	tokenStore := repository.NewMemoryTokenStore()
+	auth := services.NewAuthService(userRepo, tokenStore)
+
+	user, err := auth.Register(ctx, "carol", "carol@example.com", "secret123")
+	require.NoError(t, err)
+	assert.ErrorIs(t, auth.Authenticate(user, "wrong"), services.ErrInvalidCredentials)
+	assert.ErrorIs(t, auth.Authenticate(nil, "secret123"), services.ErrInvalidCredentials)
+	require.NoError(t, auth.Authenticate(user, "secret123"))
+
+	session, err := auth.StartSession(ctx, user, services.SessionInfo{Device: "laptop"}, nil)
+	require.NoError(t, err)
+	first, err := auth.IssueTokens(ctx, user, session)
+	require.NoError(t, err)
+
+	_, _, second, err := auth.RefreshTokens(ctx, first.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	// 重放已轮换的刷新令牌会吊销整个会话
+	_, _, _, err = auth.RefreshTokens(ctx, first.RefreshToken)
+	assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+	_, _, _, err = auth.RefreshTokens(ctx, second.RefreshToken)
+	assert.Error(t, err)
+
+	sessions := tokenStore.Sessions(user.ID)
+	require.Len(t, sessions, 1)
+	assert.NotNil(t, sessions[0].RevokedAt)
+
+	_, _, _, err = auth.RefreshTokens(ctx, "unknown")
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+}
+
+// 用户不存在时同样执行一次bcrypt比较，耗时与密码错误时相当
+func TestAuthenticateUnknownUserTiming(t *testing.T) {
+	auth := services.NewAuthService(repository.NewMemoryUserRepository(), repository.NewMemoryTokenStore())
+	hash, err := utils.HashPassword("secret123")
+	require.NoError(t, err)
+	user := &models.User{Password: hash, IsActive: true}
+
+	// 第一次调用时生成固定哈希，不计入耗时
+	auth.Authenticate(nil, "warmup")
+
+	measure := func(user *models.User) time.Duration {
+		start := time.Now()
+		assert.ErrorIs(t, auth.Authenticate(user, "wrong"), services.ErrInvalidCredentials)
+		return time.Since(start)
+	}
+	wrongPassword, unknownUser := measure(user), measure(nil)
+	assert.Greater(t, unknownUser, wrongPassword/3, "unknown user %v, wrong password %v", unknownUser, wrongPassword)
+}
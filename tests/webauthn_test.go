@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gin-auth-project/config"
+	"gin-auth-project/database"
+	"gin-auth-project/models"
+	"gin-auth-project/utils"
+
+	"github.com/descope/virtualwebauthn"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 使用软件认证器完成注册和登录仪式
+func TestWebAuthnSoftwareAuthenticator(t *testing.T) {
+	config.Store(&config.Config{
+		WebAuthnRPID:          "localhost",
+		WebAuthnRPDisplayName: "Gin Auth Test",
+		WebAuthnRPOrigins:     []string{"http://localhost:8080"},
+	})
+
+	rp := virtualwebauthn.RelyingParty{Name: "Gin Auth Test", ID: "localhost", Origin: "http://localhost:8080"}
+	authenticator := virtualwebauthn.NewAuthenticator()
+	credential := virtualwebauthn.NewCredential(virtualwebauthn.KeyTypeEC2)
+
+	web, err := utils.NewWebAuthn()
+	require.NoError(t, err)
+
+	webUser := &utils.WebAuthnUser{User: &models.User{ID: 42, Username: "alice"}}
+
+	// 注册
+	creation, session, err := web.BeginRegistration(webUser)
+	require.NoError(t, err)
+
+	creationJSON, err := json.Marshal(creation)
+	require.NoError(t, err)
+	attestationOptions, err := virtualwebauthn.ParseAttestationOptions(string(creationJSON))
+	require.NoError(t, err)
+	assert.Equal(t, string(utils.WebAuthnUserHandle(42)), attestationOptions.UserID)
+
+	attestation := virtualwebauthn.CreateAttestationResponse(rp, authenticator, credential, *attestationOptions)
+	parsedCreation, err := protocol.ParseCredentialCreationResponseBytes([]byte(attestation))
+	require.NoError(t, err)
+
+	created, err := web.CreateCredential(webUser, *session, parsedCreation)
+	require.NoError(t, err)
+
+	record := utils.NewWebAuthnCredential(42, "test key", created)
+	webUser.Credentials = append(webUser.Credentials, record)
+	authenticator.Options.UserHandle = utils.WebAuthnUserHandle(42)
+	authenticator.AddCredential(credential)
+
+	// 使用可发现凭证登录
+	login := func(counter uint32) (uint32, bool, error) {
+		assertion, session, err := web.BeginDiscoverableLogin(
+			webauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+		require.NoError(t, err)
+
+		assertionJSON, err := json.Marshal(assertion)
+		require.NoError(t, err)
+		assertionOptions, err := virtualwebauthn.ParseAssertionOptions(string(assertionJSON))
+		require.NoError(t, err)
+
+		credential.Counter = counter
+		response := virtualwebauthn.CreateAssertionResponse(rp, authenticator, credential, *assertionOptions)
+		parsed, err := protocol.ParseCredentialRequestResponseBytes([]byte(response))
+		require.NoError(t, err)
+
+		validated, err := web.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			userID, ok := utils.UserIDFromWebAuthnHandle(userHandle)
+			require.True(t, ok)
+			assert.Equal(t, uint(42), userID)
+			return webUser, nil
+		}, *session, parsed)
+		if err != nil {
+			return 0, false, err
+		}
+		return validated.Authenticator.SignCount, validated.Authenticator.CloneWarning, nil
+	}
+
+	signCount, cloned, err := login(1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), signCount)
+	assert.False(t, cloned)
+	webUser.Credentials[0].SignCount = signCount
+
+	// 签名计数器没有递增，判定为疑似克隆
+	_, cloned, err = login(1)
+	require.NoError(t, err)
+	assert.True(t, cloned)
+}
+
+// 从begin接口的响应中取出challenge_id和options
+func webAuthnBegin(t *testing.T, w *httptest.ResponseRecorder) (string, string) {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		ChallengeID string          `json:"challenge_id"`
+		Options     json.RawMessage `json:"options"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.ChallengeID, string(response.Options)
+}
+
+// 通过接口注册通行密钥，再通过接口使用可发现凭证登录
+func TestWebAuthnHandlers(t *testing.T) {
+	r := newTestRouter(t)
+	alice := createTestUser(t, "alice", "password123", models.RoleUser)
+	token, _ := loginAs(t, r, "alice", "password123")
+
+	cfg := config.Current()
+	rp := virtualwebauthn.RelyingParty{Name: cfg.WebAuthnRPDisplayName, ID: cfg.WebAuthnRPID, Origin: cfg.WebAuthnRPOrigins[0]}
+	authenticator := virtualwebauthn.NewAuthenticator()
+	credential := virtualwebauthn.NewCredential(virtualwebauthn.KeyTypeEC2)
+
+	challengeID, options := webAuthnBegin(t, authRequest(r, http.MethodPost, "/api/auth/webauthn/register/begin", token, nil))
+	attestationOptions, err := virtualwebauthn.ParseAttestationOptions(options)
+	require.NoError(t, err)
+	attestation := virtualwebauthn.CreateAttestationResponse(rp, authenticator, credential, *attestationOptions)
+	w := authRequest(r, http.MethodPost, "/api/auth/webauthn/register/finish", token, gin.H{
+		"challenge_id": challengeID,
+		"name":         "test key",
+		"credential":   json.RawMessage(attestation),
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	authenticator.Options.UserHandle = utils.WebAuthnUserHandle(alice.ID)
+	authenticator.AddCredential(credential)
+
+	login := func(body interface{}) *httptest.ResponseRecorder {
+		challengeID, options := webAuthnBegin(t, postJSON(r, "/api/auth/webauthn/login/begin", body))
+		// 不返回可用凭证列表，无法据此判断账号是否存在
+		assert.NotContains(t, options, "allowCredentials")
+		assertionOptions, err := virtualwebauthn.ParseAssertionOptions(options)
+		require.NoError(t, err)
+
+		credential.Counter++
+		assertion := virtualwebauthn.CreateAssertionResponse(rp, authenticator, credential, *assertionOptions)
+		return postJSON(r, "/api/auth/webauthn/login/finish", gin.H{
+			"challenge_id": challengeID,
+			"credential":   json.RawMessage(assertion),
+		})
+	}
+	signCount := func() uint32 {
+		var stored models.WebAuthnCredential
+		require.NoError(t, database.DB.Where("user_id = ?", alice.ID).First(&stored).Error)
+		return stored.SignCount
+	}
+
+	w = login(nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/auth/profile", response.Token, nil).Code)
+	assert.Equal(t, credential.Counter, signCount())
+
+	// 提交的用户名被忽略，响应与不存在的用户名相同
+	_, existing := webAuthnBegin(t, postJSON(r, "/api/auth/webauthn/login/begin", gin.H{"username": "alice"}))
+	_, unknown := webAuthnBegin(t, postJSON(r, "/api/auth/webauthn/login/begin", gin.H{"username": "nobody"}))
+	assert.Equal(t, len(existing), len(unknown))
+	assert.NotContains(t, existing, "allowCredentials")
+
+	// 停用的账号不能登录，也不更新凭证
+	before := signCount()
+	require.NoError(t, database.DB.Model(alice).Update("is_active", false).Error)
+	w = login(gin.H{"username": "alice"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "User account is deactivated")
+	assert.Equal(t, before, signCount())
+
+	// challenge只能使用一次
+	w = postJSON(r, "/api/auth/webauthn/login/finish", gin.H{"challenge_id": "unknown", "credential": json.RawMessage(`{}`)})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
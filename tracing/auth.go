@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 登录结果
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginBlocked = "blocked"
+)
+
+// 在当前span上记录登录结果；只记录结果和用户ID，不记录登录名、密码或验证码
+func RecordLogin(ctx context.Context, outcome string, userID uint) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("auth.login.outcome", outcome))
+	if userID != 0 {
+		span.SetAttributes(attribute.Int64("enduser.id", int64(userID)))
+	}
+}
+
+// 在当前span上记录登录锁定事件，scope为account或ip
+func RecordLockout(ctx context.Context, scope string) {
+	trace.SpanFromContext(ctx).AddEvent("auth.lockout", trace.WithAttributes(attribute.String("auth.lockout.scope", scope)))
+}
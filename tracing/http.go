@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"gin-auth-project/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 为每个请求创建服务端span，父span来自请求头中的traceparent
+// 只记录路径和路由模板，不记录查询参数和请求头，避免令牌等敏感信息进入链路数据
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx, span := tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("user_agent.original", c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+		if requestID := logging.RequestID(ctx); requestID != "" {
+			span.SetAttributes(attribute.String("request_id", requestID))
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if userID, ok := c.Get("user_id"); ok {
+			if id, ok := userID.(uint); ok {
+				span.SetAttributes(attribute.Int64("enduser.id", int64(id)))
+			}
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// 为处理器创建子span，名称取自处理器方法（如AuthHandler.Login），包含路由上的中间件
+func HandlerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler := c.HandlerName()
+		ctx, span := tracer().Start(c.Request.Context(), handlerSpanName(handler),
+			trace.WithAttributes(attribute.String("code.function", handler)),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if status := c.Writer.Status(); status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// gin-auth-project/handlers.(*AuthHandler).Login-fm -> AuthHandler.Login
+func handlerSpanName(handler string) string {
+	name := handler[strings.LastIndex(handler, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, "-fm")
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
@@ -0,0 +1,109 @@
+// Package tracing 基于OpenTelemetry的链路追踪：Gin中间件按W3C traceparent传播上下文，
+// 处理器、GORM语句和Redis命令分别记录span，通过OTLP或标准输出/文件导出
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"gin-auth-project/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 导出方式
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+const instrumentationName = "gin-auth-project"
+
+func init() {
+	// 未启用导出时同样解析和传递traceparent，保证上游的链路不中断
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// 按配置设置全局TracerProvider，返回的函数在退出前调用，导出尚未发送的span
+func Init(cfg *config.Config) (func(context.Context) error, error) {
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.TracingServiceName))),
+		// 上游已经决定采样时沿用其决定
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(cfg *config.Config) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(cfg.TracingExporter) {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterOTLP:
+		// 未配置地址时使用OTEL_EXPORTER_OTLP_ENDPOINT等标准环境变量，默认为localhost:4318
+		var options []otlptracehttp.Option
+		if cfg.TracingOTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(cfg.TracingOTLPEndpoint))
+		}
+		return otlptracehttp.New(context.Background(), options...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		file, err := os.OpenFile(cfg.TracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return fileExporter{SpanExporter: exporter, file: file}, nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.TracingExporter)
+	}
+}
+
+// 每行一个span的JSON文件，关闭时同时关闭文件
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// 每次调用时从全局TracerProvider获取，Init之前创建的中间件同样生效
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// ctx中是否有正在记录的span；GORM和Redis只在请求链路中记录span，避免后台任务产生大量单独的链路
+func recording(ctx context.Context) bool {
+	return ctx != nil && trace.SpanFromContext(ctx).IsRecording()
+}
@@ -1,51 +1,169 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"gin-auth-project/config"
+	"gin-auth-project/events"
 	"gin-auth-project/models"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// 令牌用途，访问令牌不设置该字段
+const (
+	PurposeMFAPending        = "mfa_pending"
+	PurposeEmailVerification = "email_verification"
+	// 通过OAuth授权签发给客户端的访问令牌，只能访问userinfo
+	PurposeOAuthAccess = "oauth_access"
+)
+
+// 认证方式（RFC 8176 amr声明）
+const (
+	AuthMethodPassword     = "pwd"
+	AuthMethodOTP          = "otp"
+	AuthMethodRecoveryCode = "rec"
+	AuthMethodHardwareKey  = "hwk"
+	AuthMethodMFA          = "mfa"
+)
+
+// 两步验证待完成令牌的有效期
+const mfaPendingTokenTTL = 5 * time.Minute
+
 type Claims struct {
-	UserID   uint        `json:"user_id"`
-	Username string      `json:"username"`
-	Role     models.Role `json:"role"`
+	UserID      uint        `json:"user_id"`
+	Username    string      `json:"username"`
+	Role        models.Role `json:"role"`
+	SessionID   string      `json:"sid,omitempty"`
+	Purpose     string      `json:"purpose,omitempty"`
+	AuthMethods []string    `json:"amr,omitempty"`
+	Email       string      `json:"email,omitempty"`
+	Scope       string      `json:"scope,omitempty"`
+	OrgID       uint        `json:"org_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// 生成JWT令牌
-func GenerateToken(user *models.User) (string, error) {
-	cfg := config.AppConfig
+// 是否通过了多因素认证
+func (c *Claims) IsMultiFactor() bool {
+	for _, method := range c.AuthMethods {
+		if method == AuthMethodMFA {
+			return true
+		}
+	}
+	return false
+}
+
+// 生成JWT访问令牌，令牌关联到登录会话并携带会话的认证方式
+func GenerateToken(user *models.User, session *models.Session) (string, error) {
+	cfg := config.Current()
+	ttl := time.Duration(cfg.JWTExpireHours) * time.Hour
+	token, err := generateClaims(user, ttl, func(claims *Claims) {
+		claims.SessionID = session.ID
+		claims.AuthMethods = session.AuthMethodList()
+		claims.OrgID = session.OrgID
+
+		// 通过OAuth授权的会话，令牌的受众为客户端并携带授权的scope，不能用于本服务的其他接口
+		if session.ClientID != "" {
+			claims.Purpose = PurposeOAuthAccess
+			claims.Issuer = cfg.OIDCIssuer
+			claims.Audience = jwt.ClaimStrings{session.ClientID}
+			claims.Scope = session.Scope
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	events.Publish(events.Event{Type: events.TokenIssued, Token: events.TokenAccess})
+	return token, nil
+}
+
+// 生成两步验证待完成令牌，只能用于提交验证码
+func GenerateMFAPendingToken(user *models.User) (string, error) {
+	return generateClaims(user, mfaPendingTokenTTL, func(claims *Claims) {
+		claims.Purpose = PurposeMFAPending
+		claims.AuthMethods = []string{AuthMethodPassword}
+	})
+}
+
+// 生成邮箱验证令牌，令牌绑定到签发时的邮箱地址
+func GenerateEmailVerificationToken(user *models.User) (string, error) {
+	ttl := time.Duration(config.Current().EmailVerificationExpireHours) * time.Hour
+	return generateClaims(user, ttl, func(claims *Claims) {
+		claims.Purpose = PurposeEmailVerification
+		claims.Email = user.Email
+	})
+}
+
+func generateClaims(user *models.User, ttl time.Duration, customize func(*Claims)) (string, error) {
+	tokenID, err := GenerateID()
+	if err != nil {
+		return "", err
+	}
 
+	now := time.Now()
 	claims := Claims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.JWTExpireHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
+	customize(&claims)
+
+	return signClaims(claims)
+}
+
+// 签名令牌：配置了非对称密钥时使用当前签发密钥并写入kid，否则使用HS256
+func signClaims(claims jwt.Claims) (string, error) {
+	if keys := CurrentSigningKeys(); keys != nil {
+		key := keys.Active()
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+		return token.SignedString(key.PrivateKey)
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWTSecret))
+	return token.SignedString([]byte(config.Current().JWTSecret))
 }
 
-// 验证JWT令牌
-func ValidateToken(tokenString string) (*Claims, error) {
-	cfg := config.AppConfig
+// 根据令牌头选择验证密钥，签发密钥和待退役密钥都可以验证
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	keys := CurrentSigningKeys()
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		// 切换到非对称密钥后，只在过渡期内接受HS256令牌
+		cfg := config.Current()
+		if keys != nil && !cfg.JWTAcceptHS256 {
 			return nil, errors.New("unexpected signing method")
 		}
 		return []byte(cfg.JWTSecret), nil
-	})
+	}
+
+	if keys == nil {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys.Lookup(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.PrivateKey.Public(), nil
+}
+
+// 验证JWT令牌
+func ValidateToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -58,6 +176,23 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	return nil, errors.New("invalid token")
 }
 
+// 令牌在黑名单中的标识：优先使用jti，旧令牌没有jti时使用令牌本身
+func TokenRevocationID(claims *Claims, tokenString string) string {
+	if claims != nil && claims.ID != "" {
+		return claims.ID
+	}
+	return tokenString
+}
+
+// 生成随机唯一标识（jti、会话ID等）
+func GenerateID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // 从令牌中提取用户ID
 func ExtractUserIDFromToken(tokenString string) (uint, error) {
 	claims, err := ValidateToken(tokenString)
@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"gin-auth-project/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 签名密钥状态：active用于签发新令牌，retiring只用于验证尚未过期的旧令牌
+type KeyStatus string
+
+const (
+	KeyStatusActive   KeyStatus = "active"
+	KeyStatusRetiring KeyStatus = "retiring"
+)
+
+// 非对称签名密钥
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	Status     KeyStatus
+}
+
+// 一组签名密钥，第一个为当前签发密钥，其余为待退役密钥
+type KeySet struct {
+	keys []*SigningKey
+	byID map[string]*SigningKey
+}
+
+// 当前使用的签名密钥集合，为nil时使用HS256和JWTSecret
+var signingKeys atomic.Pointer[KeySet]
+
+// 根据配置加载签名密钥，重复调用可在不重启的情况下轮换密钥
+func InitSigningKeys() error {
+	return UseSigningKeys(config.Current().JWTSigningKeys)
+}
+
+// 加载并切换到指定的签名密钥，为空时使用HS256；加载失败时保留当前的密钥
+func UseSigningKeys(paths []string) error {
+	if len(paths) == 0 {
+		signingKeys.Store(nil)
+		return nil
+	}
+
+	keys, err := LoadSigningKeys(paths)
+	if err != nil {
+		return err
+	}
+
+	signingKeys.Store(keys)
+	return nil
+}
+
+// 当前的签名密钥集合，未配置非对称密钥时返回nil
+func CurrentSigningKeys() *KeySet {
+	return signingKeys.Load()
+}
+
+// 从PEM文件加载签名密钥，密钥ID（kid）取文件名（不含扩展名）
+func LoadSigningKeys(paths []string) (*KeySet, error) {
+	set := &KeySet{byID: make(map[string]*SigningKey)}
+
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read signing key %s: %w", path, err)
+		}
+
+		privateKey, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse signing key %s: %w", path, err)
+		}
+
+		method, err := signingMethodFor(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", path, err)
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if _, exists := set.byID[id]; exists {
+			return nil, fmt.Errorf("duplicate signing key id %q", id)
+		}
+
+		status := KeyStatusRetiring
+		if i == 0 {
+			status = KeyStatusActive
+		}
+
+		key := &SigningKey{ID: id, Method: method, PrivateKey: privateKey, Status: status}
+		set.keys = append(set.keys, key)
+		set.byID[id] = key
+	}
+
+	return set, nil
+}
+
+// 解析PEM格式的私钥（PKCS#8、PKCS#1或SEC 1）
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+// 根据密钥类型选择签名算法
+func signingMethodFor(key crypto.Signer) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, errors.New("unsupported elliptic curve")
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, errors.New("unsupported private key type")
+}
+
+// 当前签发密钥
+func (s *KeySet) Active() *SigningKey {
+	return s.keys[0]
+}
+
+// 按kid查找密钥
+func (s *KeySet) Lookup(id string) (*SigningKey, bool) {
+	key, ok := s.byID[id]
+	return key, ok
+}
+
+// 全部密钥
+func (s *KeySet) Keys() []*SigningKey {
+	return s.keys
+}
+
+// JSON Web Key（RFC 7517），只包含公钥参数
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWK集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// 导出全部公钥，供其他服务验证令牌
+func (s *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+// 导出公钥
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Method.Alg()}
+
+	switch pub := k.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
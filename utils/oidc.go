@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"gin-auth-project/config"
+	"gin-auth-project/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OpenID Connect 支持的scope
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// ID令牌声明
+type IDTokenClaims struct {
+	AuthTime          int64       `json:"auth_time"`
+	Nonce             string      `json:"nonce,omitempty"`
+	AuthMethods       []string    `json:"amr,omitempty"`
+	SessionID         string      `json:"sid,omitempty"`
+	PreferredUsername string      `json:"preferred_username,omitempty"`
+	Role              models.Role `json:"role,omitempty"`
+	Email             string      `json:"email,omitempty"`
+	EmailVerified     *bool       `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// 生成ID令牌，authTime为用户实际登录的时间，用户信息按授权的scope填充
+func GenerateIDToken(user *models.User, session *models.Session, authTime time.Time, nonce string) (string, error) {
+	cfg := config.Current()
+	now := time.Now()
+	claims := IDTokenClaims{
+		AuthTime:    authTime.Unix(),
+		Nonce:       nonce,
+		AuthMethods: session.AuthMethodList(),
+		SessionID:   session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.OIDCIssuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  jwt.ClaimStrings{session.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(cfg.JWTExpireHours) * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	fillUserInfo(&claims, user, session.ScopeList())
+
+	return signClaims(claims)
+}
+
+// 按scope返回用户信息（userinfo接口）
+func UserInfo(user *models.User, scopes []string) map[string]interface{} {
+	var claims IDTokenClaims
+	fillUserInfo(&claims, user, scopes)
+
+	info := map[string]interface{}{
+		"sub": strconv.FormatUint(uint64(user.ID), 10),
+	}
+	if claims.PreferredUsername != "" {
+		info["preferred_username"] = claims.PreferredUsername
+		info["role"] = claims.Role
+	}
+	if claims.Email != "" {
+		info["email"] = claims.Email
+		info["email_verified"] = *claims.EmailVerified
+	}
+	return info
+}
+
+func fillUserInfo(claims *IDTokenClaims, user *models.User, scopes []string) {
+	if ContainsScope(scopes, ScopeProfile) {
+		claims.PreferredUsername = user.Username
+		claims.Role = user.Role
+	}
+	if ContainsScope(scopes, ScopeEmail) {
+		verified := user.EmailVerified
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+}
+
+// scope列表中是否包含指定scope
+func ContainsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 校验PKCE（RFC 7636，只支持S256）
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
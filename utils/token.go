@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// 生成不透明的随机令牌（用于刷新令牌等）
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 个人访问令牌的前缀，便于识别和密钥扫描
+const AccessTokenPrefix = "pat_"
+
+// 生成个人访问令牌
+func GenerateAccessToken() (string, error) {
+	raw, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return AccessTokenPrefix + raw, nil
+}
+
+// 计算令牌哈希，数据库中只保存哈希值
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
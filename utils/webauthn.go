@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"gin-auth-project/config"
+	"gin-auth-project/models"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// 根据配置创建WebAuthn依赖方（Relying Party）
+func NewWebAuthn() (*webauthn.WebAuthn, error) {
+	cfg := config.Current()
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPDisplayName,
+		RPOrigins:     cfg.WebAuthnRPOrigins,
+	})
+}
+
+// WebAuthn用户适配器，实现 webauthn.User 接口
+type WebAuthnUser struct {
+	User        *models.User
+	Credentials []models.WebAuthnCredential
+}
+
+func (u *WebAuthnUser) WebAuthnID() []byte {
+	return WebAuthnUserHandle(u.User.ID)
+}
+
+func (u *WebAuthnUser) WebAuthnName() string {
+	return u.User.Username
+}
+
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	return u.User.Username
+}
+
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Credentials))
+	for _, credential := range u.Credentials {
+		credentials = append(credentials, ToWebAuthnCredential(credential))
+	}
+	return credentials
+}
+
+// 用户句柄（user handle），使用用户ID的8字节大端编码，不包含个人信息
+func WebAuthnUserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// 从用户句柄解析用户ID
+func UserIDFromWebAuthnHandle(handle []byte) (uint, bool) {
+	if len(handle) != 8 {
+		return 0, false
+	}
+	return uint(binary.BigEndian.Uint64(handle)), true
+}
+
+// 将库的凭证转换为数据库模型
+func NewWebAuthnCredential(userID uint, name string, credential *webauthn.Credential) models.WebAuthnCredential {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return models.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Flags:           uint8(credential.Flags.ProtocolValue()),
+		Transports:      strings.Join(transports, ","),
+	}
+}
+
+// 将数据库模型转换为库的凭证
+func ToWebAuthnCredential(m models.WebAuthnCredential) webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	if m.Transports != "" {
+		for _, transport := range strings.Split(m.Transports, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              m.CredentialID,
+		PublicKey:       m.PublicKey,
+		AttestationType: m.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(m.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    m.AAGUID,
+			SignCount: m.SignCount,
+		},
+	}
+}